@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"orderservice/config"
+	"orderservice/internal/export"
+	"orderservice/internal/logger"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"orderservice/internal/service"
+)
+
+const exportUsage = `usage: orderservice export [-format csv|ndjson|parquet] [-o FILE] [-batch N] [filters]
+
+Writes orders matching the filters to FILE (stdout by default), ordered by date_created.
+csv and parquet have one row per item with order, delivery and payment columns; ndjson has one order per line
+in the Kafka message format and can be loaded back with "orderservice replay". Orders are read from a DB cursor
+N at a time, so memory use does not depend on the size of the export. Personal data is decrypted.
+
+Filters: -date_from, -date_to (YYYY-MM-DD, date_to inclusive, or RFC3339), -customer_id, -track_number,
+-delivery_service, -provider, -bank, -brand, -nm_id.`
+
+// runExport implements "export" subcommand
+func runExport(args []string) {
+	if _, err := logger.SetupWriter(os.Stderr, "info", "text"); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	cfg := config.GetConfig()
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, exportUsage) }
+	format := flags.String("format", export.FormatCSV, "csv, ndjson or parquet")
+	out := flags.String("o", "-", "output file, - for stdout")
+	batchSize := flags.Int("batch", cfg.ExportBatchSize, "orders per DB fetch")
+	dateFrom := flags.String("date_from", "", "created at or after")
+	dateTo := flags.String("date_to", "", "created before the end of the day or before the moment")
+	var filter model.OrderFilter
+	flags.StringVar(&filter.CustomerID, "customer_id", "", "")
+	flags.StringVar(&filter.TrackNumber, "track_number", "", "")
+	flags.StringVar(&filter.DeliveryService, "delivery_service", "", "")
+	flags.StringVar(&filter.Provider, "provider", "", "payment provider")
+	flags.StringVar(&filter.Bank, "bank", "", "payment bank")
+	flags.StringVar(&filter.Brand, "brand", "", "at least one item of the brand")
+	flags.UintVar(&filter.NMID, "nm_id", 0, "at least one item with the nm_id")
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 || *batchSize <= 0 || !slices.Contains(export.Formats, *format) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var err error
+	if filter.DateFrom, err = parseExportDate(*dateFrom, false); err != nil {
+		logger.Fatal("Invalid -date_from", logger.Err(err))
+	}
+	if filter.DateTo, err = parseExportDate(*dateTo, true); err != nil {
+		logger.Fatal("Invalid -date_to", logger.Err(err))
+	}
+
+	var file *os.File
+	var dst io.Writer = os.Stdout
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			logger.Fatal("Failed to create file", logger.Err(err))
+		}
+		dst = file
+	}
+	writer, err := export.NewWriter(*format, dst)
+	if err != nil {
+		logger.Fatal("Failed to create export writer", logger.Err(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, closeRepo := newRepository(cfg, newPIICodec(cfg))
+	defer closeRepo()
+	exportRepo, ok := repo.(repository.ExportRepository)
+	if !ok {
+		logger.Fatal("Storage does not support export", "storage", cfg.Storage)
+	}
+
+	start := time.Now()
+	n, err := service.NewExportService(exportRepo, *batchSize).ExportOrders(ctx, filter, writer)
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(*out) //недописанный файл не должен выглядеть как готовая выгрузка
+		}
+	}
+	if err != nil {
+		closeRepo()
+		logger.Fatal("Export failed", "orders", n, logger.Err(err))
+	}
+	slog.Info("Export finished", "format", *format, "orders", n, "output", *out, "duration", time.Since(start))
+}
+
+// parseExportDate accepts RFC3339 or YYYY-MM-DD; a date without time as the end of range includes the whole day
+func parseExportDate(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err == nil && end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
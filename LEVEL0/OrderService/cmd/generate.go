@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"orderservice/config"
+	"orderservice/internal/generator"
+	"orderservice/internal/kafka"
+	"orderservice/internal/logger"
+)
+
+const generateUsage = `usage: orderservice generate [flags]
+
+Generates random valid orders and a fraction of deliberately broken ones (every validation rule in turn)
+and sends them to Kafka, stdout (NDJSON, can be loaded with "replay") or HTTP ingest endpoint.
+Defaults are taken from MOCK_*, KAFKA_BROKER, KAFKA_TOPIC and APP_PORT variables.
+
+flags:`
+
+// runGenerate implements "generate" subcommand
+func runGenerate(args []string) {
+	// stdout может быть занят сгенерированными сообщениями, поэтому логи пишутся в stderr
+	if _, err := logger.SetupWriter(os.Stderr, "info", "text"); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	defaults := config.GetGeneratorConfig()
+	port := os.Getenv("APP_PORT")
+	if port == "" {
+		port = "8081"
+	}
+
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, generateUsage)
+		flags.PrintDefaults()
+	}
+	sinkName := flags.String("sink", "stdout", "destination: kafka, stdout or http")
+	count := flags.Int("count", 0, "number of messages, 0 - until interrupted")
+	rate := flags.Float64("rate", defaults.Rate, "messages per second, 0 - unlimited")
+	items := flags.String("items", fmt.Sprintf("%d-%d", defaults.MinItems, defaults.MaxItems), "items per order: N or MIN-MAX")
+	locales := flags.String("locales", strings.Join(defaults.Locales, ","), "comma-separated locales")
+	currencies := flags.String("currencies", strings.Join(defaults.Currencies, ","), "comma-separated ISO 4217 currencies")
+	broken := flags.Float64("broken", defaults.BrokenFraction, "fraction of broken messages, 0..1")
+	seed := flags.Uint64("seed", 0, "random seed, 0 - random")
+	broker := flags.String("broker", os.Getenv("KAFKA_BROKER"), "Kafka broker for -sink kafka")
+	topic := flags.String("topic", os.Getenv("KAFKA_TOPIC"), "Kafka topic for -sink kafka")
+	url := flags.String("url", "http://localhost:"+port+"/api/v1/orders", "ingest endpoint for -sink http")
+	_ = flags.Parse(args)
+
+	cfg := defaults
+	var err error
+	if cfg.MinItems, cfg.MaxItems, err = config.ParseRange(*items); err != nil {
+		logger.Fatal("Invalid -items", logger.Err(err))
+	}
+	cfg.Locales = strings.Split(*locales, ",")
+	cfg.Currencies = strings.Split(*currencies, ",")
+	cfg.BrokenFraction = *broken
+	gen, err := newGenerator(cfg, *seed)
+	if err != nil {
+		logger.Fatal("Invalid generator config", logger.Err(err))
+	}
+
+	var sink generator.Sink
+	switch *sinkName {
+	case "stdout":
+		sink = &generator.WriterSink{W: os.Stdout}
+	case "http":
+		sink = &generator.HTTPSink{URL: *url}
+	case "kafka":
+		if *broker == "" || *topic == "" {
+			logger.Fatal("Kafka broker and topic are required for -sink kafka")
+		}
+		producer := kafka.NewProducer(*broker, *topic)
+		defer producer.Close()
+		sink = producer
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stats := generator.Run(ctx, gen, sink, *rate, *count)
+	slog.Info("Generation finished", "sent", stats.Sent, "failed", stats.Failed, "broken", stats.Broken)
+}
+
+// newGenerator converts config to generator settings
+func newGenerator(cfg config.GeneratorConfig, seed uint64) (*generator.Generator, error) {
+	return generator.New(generator.Config{
+		MinItems:       cfg.MinItems,
+		MaxItems:       cfg.MaxItems,
+		Locales:        cfg.Locales,
+		Currencies:     cfg.Currencies,
+		BrokenFraction: cfg.BrokenFraction,
+		Seed:           seed,
+	})
+}
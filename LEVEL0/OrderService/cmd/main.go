@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,40 +14,191 @@ import (
 
 	"orderservice/config"
 	handler "orderservice/internal/api"
+	"orderservice/internal/auth"
 	"orderservice/internal/cache"
 	"orderservice/internal/db"
+	"orderservice/internal/generator"
+	"orderservice/internal/grpcapi"
+	"orderservice/internal/health"
+	"orderservice/internal/ingest"
 	"orderservice/internal/kafka"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/migrate"
+	"orderservice/internal/outbox"
+	"orderservice/internal/pii"
+	"orderservice/internal/ratelimit"
 	"orderservice/internal/repository"
 	"orderservice/internal/service"
 	"orderservice/internal/web"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"gorm.io/gorm"
 )
 
 func main() {
-	startConfig := config.GetConfig()
-	fmt.Println(startConfig)
-	db := db.ConnectPostgres(startConfig.DSN)
-
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatalf("Failed to retrieve sql.DB: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "generate":
+			runGenerate(os.Args[2:])
+			return
+		case "reencrypt":
+			runReencrypt(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		}
 	}
-	defer sqlDB.Close()
 
-	repo := repository.NewOrderRepository(db, startConfig.DSN)
-	orderMap, err := cache.CreateAndWarmUpOrderCache(repo)
+	startConfig := config.GetConfig()
+	if _, err := logger.Setup(startConfig.LogLevel, startConfig.LogFormat); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	slog.Info("Configuration loaded",
+		"app_port", startConfig.AppPort,
+		"grpc_port", startConfig.GRPCPort,
+		"kafka_broker", startConfig.KafkaBroker,
+		"topic", startConfig.Topic,
+		"dlq_topic", startConfig.DLQTopic,
+		"outbox_topic", startConfig.OutboxTopic,
+		"cache_policy", startConfig.CachePolicy,
+		"cache_warmup", startConfig.CacheWarmUp,
+		"log_level", startConfig.LogLevel,
+		"storage", startConfig.Storage,
+		"read_replicas", len(startConfig.ReplicaDSNs),
+		"auth_enabled", startConfig.AuthEnabled,
+		"pii_fields", startConfig.PIIPolicy.Fields(),
+		"http_request_timeout", startConfig.HTTPRequestTimeout,
+		"rate_limit", startConfig.RateLimit.String(),
+		"auth_failure_limit", startConfig.AuthFailureLimit.String(),
+	)
+	codec := newPIICodec(startConfig)
+	baseRepo, closeRepo := newRepository(startConfig, codec)
+	defer closeRepo()
+	// повторы и circuit breaker внутри, чтобы метрики репозитория учитывали полное время вызова
+	repo := repository.NewInstrumentedRepository(repository.NewResilientRepository(baseRepo, resilienceConfig(startConfig)))
+	orderCache, err := cache.New(cache.Config{
+		Policy:     startConfig.CachePolicy,
+		MaxEntries: startConfig.CacheMaxEntries,
+		MaxBytes:   startConfig.CacheMaxBytes,
+		TTL:        startConfig.CacheTTL,
+	})
 	if err != nil {
-		log.Fatalf("Failed to load cache: %v", err)
+		logger.Fatal("Failed to create cache", logger.Err(err))
 	}
-	svc := service.NewOrderService(repo, orderMap)
+	cache.RegisterMetrics(orderCache)
+	// без Kafka отклоненные сообщения сохраняются только в InvalidRequests
+	var dlq service.DeadLetterPublisher
+	if startConfig.KafkaEnabled() {
+		publisher := kafka.NewDLQPublisher(startConfig.KafkaBroker, startConfig.DLQTopic)
+		defer publisher.Close()
+		dlq = publisher
+	} else {
+		slog.Warn("Kafka is disabled, orders are accepted only via HTTP ingest")
+	}
+
+	svc := service.NewOrderService(repo, orderCache, dlq)
 	orderHandler := handler.OrderHandler{
 		Service: svc,
+		ShowPII: startConfig.PIIShowInHTML,
+	}
+	analyticsHandler := handler.AnalyticsHandler{
+		Service: service.NewAnalyticsService(repo),
+	}
+	adminHandler := handler.AdminHandler{
+		Service: service.NewDeadLetterService(svc),
+	}
+	// выгрузка читает базовый репозиторий: повторить поток, часть которого уже отдана клиенту, нельзя
+	var exportHandler *handler.ExportHandler
+	if exportRepo, ok := baseRepo.(repository.ExportRepository); ok {
+		exportHandler = &handler.ExportHandler{
+			Service: service.NewExportService(exportRepo, startConfig.ExportBatchSize),
+		}
+	}
+
+	// Readiness: зависимости проверяются при каждом запросе /readyz, шаги запуска отмечаются флагами.
+	// Прогрев кеша не входит в readiness: пока он идет, заказы читаются из БД
+	var templatesReady health.Flag
+	checker := health.NewChecker(2 * time.Second)
+	if startConfig.Storage == config.StoragePostgres {
+		checker.Add("postgres", repo.Ping)
+	}
+	if startConfig.KafkaEnabled() {
+		checker.Add("kafka", kafka.BrokerCheck(startConfig.KafkaBroker))
+		//ребалансировка группы - штатная ситуация, снимать из-за нее все экземпляры с балансировки нельзя
+		checker.AddStartup("kafka_consumer_group", kafka.ConsumerGroupCheck(startConfig.KafkaBroker))
 	}
+	checker.Add("templates", templatesReady.Check)
+	healthHandler := handler.HealthHandler{
+		Checker: checker,
+	}
+
+	authn := newAuthenticator(startConfig)
+
+	limiter := ratelimit.New(ratelimit.Config{Default: startConfig.RateLimit, Routes: startConfig.RateLimitRoutes})
+	authFailureLimiter := ratelimit.New(ratelimit.Config{Default: startConfig.AuthFailureLimit})
 
 	r := chi.NewRouter()
-	r.Get("/order/{uid}", orderHandler.GetOrderInfo)
-	r.Get("/order/", orderHandler.GetOrderInfo)
+	r.Use(logger.HTTPMiddleware)
+	r.Use(metrics.HTTPMiddleware)
+	r.Use(handler.Recoverer)
+	if startConfig.HTTPCompression {
+		r.Use(middleware.Compress(5))
+	}
+	// пробы и метрики доступны без аутентификации
+	r.Group(func(r chi.Router) {
+		r.Use(handler.Timeout(startConfig.HTTPRequestTimeout))
+		r.Handle("/metrics", metrics.Handler())
+		r.Get("/healthz", healthHandler.Liveness)
+		r.Get("/readyz", healthHandler.Readiness)
+	})
+	r.Group(func(r chi.Router) {
+		// запросы с неверными ключами не доходят до RateLimit, поэтому неудачные аутентификации ограничиваются по IP отдельно
+		r.Use(handler.ThrottleAuthFailures(authFailureLimiter))
+		r.Use(authn.Middleware)
+		// лимит считается по subject клиента, поэтому после аутентификации
+		r.Use(handler.RateLimit(limiter))
+		r.Group(func(r chi.Router) {
+			r.Use(handler.Timeout(startConfig.HTTPRequestTimeout))
+			r.Group(func(r chi.Router) {
+				// analytics видит заказы с замаскированными Delivery и Payment
+				r.Use(authn.Require(auth.RoleSupport, auth.RoleAnalytics, auth.RoleAdmin))
+				r.Get("/order/{uid}", orderHandler.GetOrderInfo)
+				r.Get("/order/", orderHandler.GetOrderInfo)
+				r.Get("/orders", orderHandler.ListOrdersPage)
+				r.Get("/api/v1/orders", orderHandler.ListOrdersJSON)
+				r.Get("/api/v1/orders/{uid}", orderHandler.GetOrderJSON)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(authn.Require(auth.RoleAnalytics, auth.RoleAdmin))
+				r.Get("/analytics", analyticsHandler.AnalyticsPage)
+				r.Get("/api/v1/analytics", analyticsHandler.GetAnalyticsJSON)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(authn.Require(auth.RoleAdmin))
+				r.Post("/api/v1/orders", orderHandler.CreateOrder)
+				r.Route("/admin/v1/invalid-requests", func(r chi.Router) {
+					r.Get("/", adminHandler.ListInvalidRequests)
+					r.Get("/{id}", adminHandler.GetInvalidRequest)
+					r.Post("/{id}/replay", adminHandler.ReplayInvalidRequest)
+					r.Post("/{id}/discard", adminHandler.DiscardInvalidRequest)
+				})
+			})
+		})
+		if exportHandler != nil {
+			// без дедлайна запроса: выгрузка передается столько, сколько клиент ее читает
+			r.With(authn.Require(auth.RoleSupport, auth.RoleAnalytics, auth.RoleAdmin)).
+				Get("/api/v1/orders/export", exportHandler.ExportOrders)
+		}
+	})
 	srv := http.Server{
 		Addr:         ":" + startConfig.AppPort,
 		Handler:      r,
@@ -60,26 +212,32 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		fmt.Printf("Server running on http://localhost:%s\n", startConfig.AppPort)
+		slog.Info("Server running", "addr", "http://localhost:"+startConfig.AppPort)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server stopped: %v", err)
+			logger.Fatal("Server stopped", logger.Err(err))
 		}
-		log.Println("Server gracefully stopping...")
+		slog.Info("Server gracefully stopping...")
 	}()
 
-	web.LoadTemplates()
-
-	kafka.WaitKafkaReady(startConfig.KafkaBroker)
-
-	ctx, kafkaCancel := context.WithCancel(context.Background())
-	wg.Add(1)
-	go kafka.StartConsumer(ctx, orderHandler.Service, startConfig.KafkaBroker, startConfig.Topic, &wg)
-	time.Sleep(3 * time.Second)
-
-	if startConfig.LaunchMockGenerator {
-		go kafka.EmulateMsgSending(startConfig.KafkaBroker, startConfig.Topic)
+	var grpcServer *grpcapi.Server
+	if startConfig.GRPCPort != "" {
+		lis, err := net.Listen("tcp", ":"+startConfig.GRPCPort)
+		if err != nil {
+			logger.Fatal("Failed to listen gRPC port", logger.Err(err))
+		}
+		grpcServer = grpcapi.NewServer(svc, authn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info("gRPC server running", "addr", lis.Addr().String())
+			if err := grpcServer.Serve(lis); err != nil {
+				logger.Fatal("gRPC server stopped", logger.Err(err))
+			}
+		}()
 	}
 
+	ctx, stop := context.WithCancel(context.Background())
+
 	// Starting shutdown signal listener
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
@@ -87,21 +245,312 @@ func main() {
 
 	wg.Add(1)
 	go func() {
-		log.Print("Interruption listener is running...")
+		slog.Info("Interruption listener is running...")
 		defer wg.Done()
 		<-sig
-		log.Println("Interrupt received!!! Starting shutdown sequence...")
-		// stop Kafka consumer:
-		kafkaCancel()
-		log.Println("Kafka consumer stopping...")
-		// 5 seconds to stop HTTP-server:
+		slog.Info("Interrupt received, starting shutdown sequence...")
+		// stop startup sequence and Kafka consumer:
+		stop()
+		slog.Info("Kafka consumer stopping...")
+		// 5 seconds to stop HTTP and gRPC servers:
 		ctx, httpCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer httpCancel()
+		if grpcServer != nil {
+			// потоки WatchOrders завершаются сразу, поэтому gRPC останавливается быстро и не съедает время HTTP
+			if err := grpcServer.Shutdown(ctx); err != nil {
+				slog.Error("gRPC server shutdown error", logger.Err(err))
+			}
+			slog.Info("gRPC server stopped")
+		}
 		if err := srv.Shutdown(ctx); err != nil {
-			log.Printf("Server shutdown error: %v", err)
+			slog.Error("Server shutdown error", logger.Err(err))
 		}
-		log.Println("HTTP server stopped")
+		slog.Info("HTTP server stopped")
 	}()
+
+	web.LoadTemplates()
+	templatesReady.Set()
+
+	warmUpConfig := cache.WarmUpConfig{
+		Strategy:       startConfig.CacheWarmUp,
+		Count:          startConfig.CacheWarmUpCount,
+		MaxAge:         startConfig.CacheWarmUpAge,
+		PageSize:       startConfig.CacheWarmUpPageSize,
+		SnapshotPath:   startConfig.CacheSnapshotPath,
+		SnapshotMaxAge: startConfig.CacheSnapshotMaxAge,
+		PII:            codec,
+	}
+	var listening <-chan struct{}
+	if startConfig.CacheInvalidation {
+		invalidator := &cache.Invalidator{Repo: repo, Cache: orderCache}
+		listener := db.NewListener(db.ListenerConfig{DSN: startConfig.DSN, Channel: cache.ChangesChannel}, invalidator.HandleNotification)
+		listener.OnReconnect = invalidator.Resync
+		listening = listener.Listening()
+		//снимок не знает об изменениях, сделанных, пока сервис был остановлен
+		warmUpConfig.VerifySnapshot = invalidator.Reconcile
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listener.Run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// прогрев начинается после подписки, чтобы не пропустить изменения, сделанные другими экземплярами во время прогрева
+		if listening != nil {
+			select {
+			case <-listening:
+			case <-ctx.Done():
+				return
+			}
+		}
+		err := cache.WarmUp(ctx, repo, orderCache, warmUpConfig)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Cache warm-up failed, orders will be cached on demand", logger.Err(err))
+		}
+	}()
+
+	if codec != nil && startConfig.PIIReencrypt {
+		startReencrypt(ctx, baseRepo, startConfig.PIIReencryptBatchSize, &wg)
+	}
+
+	if startConfig.KafkaEnabled() {
+		startConsumer(ctx, checker, svc, startConfig, &wg)
+	}
+
+	if startConfig.KafkaEnabled() {
+		startOutboxRelay(ctx, checker, baseRepo, startConfig, &wg)
+	}
+
+	if startConfig.LaunchMockGenerator {
+		gen, err := newGenerator(startConfig.Generator, 0)
+		if err != nil {
+			logger.Fatal("Invalid mock generator config", logger.Err(err))
+		}
+		go func() {
+			// генератор запускается только после того, как консьюмер вошел в группу, иначе первые сообщения ждут ребалансировки
+			if err := checker.Wait(ctx, "kafka_consumer_group", time.Second); err != nil {
+				slog.Error("Mock generator is not started", logger.Err(err))
+				return
+			}
+			producer := kafka.NewProducer(startConfig.KafkaBroker, startConfig.Topic)
+			defer producer.Close()
+			stats := generator.Run(ctx, gen, producer, startConfig.Generator.Rate, 0)
+			slog.Info("Mock generator stopped", "sent", stats.Sent, "failed", stats.Failed)
+		}()
+	}
+
 	wg.Wait()
-	log.Println("Exiting application...")
+	// снимок сохраняется после остановки консьюмера и HTTP-сервера, когда кеш уже не меняется
+	if startConfig.CacheSnapshotPath != "" {
+		if n, err := cache.SaveSnapshot(startConfig.CacheSnapshotPath, orderCache, codec); err != nil {
+			slog.Error("Failed to save cache snapshot", logger.Err(err))
+		} else {
+			slog.Info("Cache snapshot saved", "orders", n, "path", startConfig.CacheSnapshotPath)
+		}
+	}
+	slog.Info("Exiting application...")
+}
+
+// startConsumer waits for the broker and starts Kafka consumer in background
+func startConsumer(ctx context.Context, checker *health.Checker, h ingest.Handler, cfg config.Config, wg *sync.WaitGroup) {
+	// вместо фиксированных пауз ждем, пока брокер начнет отвечать
+	if err := checker.Wait(ctx, "kafka", 5*time.Second); err != nil {
+		slog.Error("Kafka consumer is not started", logger.Err(err))
+		return
+	}
+	var source ingest.Source = kafka.NewConsumer(kafka.ConsumerConfig{
+		Broker:          cfg.KafkaBroker,
+		Topic:           cfg.Topic,
+		Workers:         cfg.ConsumerWorkers,
+		BatchSize:       cfg.ConsumerBatchSize,
+		BatchTimeout:    cfg.ConsumerBatchTimeout,
+		RetryBackoff:    cfg.ConsumerRetryBackoff,
+		MaxRetryBackoff: cfg.ConsumerMaxRetryBackoff,
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := source.Run(ctx, h); err != nil {
+			slog.Error("Kafka consumer failed", logger.Err(err))
+		}
+	}()
+}
+
+// startOutboxRelay publishes order.persisted events from the outbox to Kafka in background.
+// Relay works with the base repository, so its queries do not affect repository metrics
+func startOutboxRelay(ctx context.Context, checker *health.Checker, repo repository.OrderRepository, cfg config.Config, wg *sync.WaitGroup) {
+	outboxRepo, ok := repo.(repository.OutboxRepository)
+	if !ok {
+		slog.Warn("Storage does not support outbox, order.persisted events are not published")
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := checker.Wait(ctx, "kafka", 5*time.Second); err != nil {
+			slog.Error("Outbox relay is not started", logger.Err(err))
+			return
+		}
+		publisher := kafka.NewOutboxPublisher(cfg.KafkaBroker, cfg.OutboxTopic)
+		defer publisher.Close()
+		outbox.NewRelay(outboxRepo, publisher, outbox.Config{
+			BatchSize:       cfg.OutboxBatchSize,
+			PollInterval:    cfg.OutboxPollInterval,
+			RetryBackoff:    cfg.ConsumerRetryBackoff,
+			MaxRetryBackoff: cfg.ConsumerMaxRetryBackoff,
+			MaxAttempts:     cfg.OutboxMaxAttempts,
+			Retention:       cfg.OutboxRetention,
+		}).Run(ctx)
+	}()
+}
+
+// startReencrypt re-encrypts personal data stored with old keys or in plaintext with the active key in background
+func startReencrypt(ctx context.Context, repo repository.OrderRepository, batchSize int, wg *sync.WaitGroup) {
+	piiRepo, ok := repo.(repository.PIIRepository)
+	if !ok {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n, err := piiRepo.ReencryptPII(ctx, batchSize)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("PII re-encryption failed", "rows", n, logger.Err(err))
+			return
+		}
+		slog.Info("PII re-encryption finished", "rows", n)
+	}()
+}
+
+// newPIICodec sets masking policy and creates codec of personal data stored in DB and cache snapshots; nil if no keys are configured
+func newPIICodec(cfg config.Config) *pii.Codec {
+	pii.SetPolicy(cfg.PIIPolicy)
+	var keys []pii.Key
+	if cfg.PIIKeys != "" {
+		parsed, err := pii.ParseKeys(cfg.PIIKeys)
+		if err != nil {
+			logger.Fatal("Invalid PII keys", "key", "PII_KEYS", logger.Err(err))
+		}
+		keys = append(keys, parsed...)
+	}
+	if cfg.PIIKeyFile != "" {
+		loaded, err := pii.LoadKeyFile(cfg.PIIKeyFile)
+		if err != nil {
+			logger.Fatal("Invalid PII key file", "key", "PII_KEY_FILE", logger.Err(err))
+		}
+		keys = append(keys, loaded...)
+	}
+	if len(keys) == 0 {
+		slog.Warn("PII encryption keys are not configured, personal data is stored unencrypted")
+		return nil
+	}
+	keyring, err := pii.NewKeyring(keys, cfg.PIIActiveKey)
+	if err != nil {
+		logger.Fatal("Invalid PII keys", "key", "PII_ACTIVE_KEY", logger.Err(err))
+	}
+	slog.Info("PII encryption enabled", "active_key", keyring.Active(), "keys", len(keys))
+	return pii.NewCodec(keyring, cfg.PIIPolicy)
+}
+
+// newAuthenticator creates authenticator of HTTP and gRPC API clients; nil if auth is disabled
+func newAuthenticator(cfg config.Config) *auth.Authenticator {
+	if !cfg.AuthEnabled {
+		slog.Warn("Auth is disabled, every client sees orders with personal data")
+		return nil
+	}
+	authn := &auth.Authenticator{}
+	if cfg.AuthAPIKeys != "" {
+		keys, err := auth.ParseAPIKeys(cfg.AuthAPIKeys)
+		if err != nil {
+			logger.Fatal("Invalid API keys", "key", "AUTH_API_KEYS", logger.Err(err))
+		}
+		authn.Keys = keys
+	}
+	if cfg.AuthJWTSecret != "" || len(cfg.AuthJWTPublicKeys) > 0 {
+		verifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+			HMACSecret:     cfg.AuthJWTSecret,
+			PublicKeyFiles: cfg.AuthJWTPublicKeys,
+			Issuer:         cfg.AuthJWTIssuer,
+			Audience:       cfg.AuthJWTAudience,
+			RoleClaim:      cfg.AuthJWTRoleClaim,
+			Leeway:         cfg.AuthJWTLeeway,
+		})
+		if err != nil {
+			logger.Fatal("Invalid JWT config", logger.Err(err))
+		}
+		authn.JWT = verifier
+	}
+	return authn
+}
+
+// resilienceConfig returns retry and circuit breaker settings of the repository
+func resilienceConfig(cfg config.Config) repository.ResilienceConfig {
+	return repository.ResilienceConfig{
+		MaxAttempts:      cfg.DBRetryAttempts,
+		RetryBackoff:     cfg.DBRetryBackoff,
+		MaxRetryBackoff:  cfg.DBMaxRetryBackoff,
+		RetryJitter:      cfg.DBRetryJitter,
+		FailureThreshold: cfg.DBBreakerFailures,
+		OpenTimeout:      cfg.DBBreakerOpenTimeout,
+	}
+}
+
+// newRepository creates repository for the configured storage; for Postgres the schema is migrated(if enabled) and checked.
+// The returned function closes DB connection
+func newRepository(cfg config.Config, codec *pii.Codec) (repository.OrderRepository, func()) {
+	if cfg.Storage == config.StorageMemory {
+		slog.Warn("Using in-memory storage, all data will be lost on restart")
+		return repository.NewMemoryRepository(), func() {}
+	}
+
+	pool := poolConfig(cfg)
+	primary := db.ConnectPostgres("primary", cfg.DSN, pool)
+	sqlDB, err := primary.DB()
+	if err != nil {
+		logger.Fatal("Failed to retrieve sql.DB", logger.Err(err))
+	}
+
+	// Схема БД: при необходимости применяем миграции, затем отказываемся работать с неизвестной версией
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", logger.Err(err))
+	}
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			logger.Fatal("Failed to migrate", logger.Err(err))
+		}
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		logger.Fatal("Unsupported DB schema version", logger.Err(err))
+	}
+
+	closers := []func() error{sqlDB.Close}
+	replicas := make([]*gorm.DB, 0, len(cfg.ReplicaDSNs))
+	for i, dsn := range cfg.ReplicaDSNs {
+		replica := db.ConnectPostgres(fmt.Sprintf("replica-%d", i+1), dsn, pool)
+		replicaDB, err := replica.DB()
+		if err != nil {
+			logger.Fatal("Failed to retrieve sql.DB", logger.Err(err))
+		}
+		replicas = append(replicas, replica)
+		closers = append(closers, replicaDB.Close)
+	}
+	return repository.NewOrderRepository(primary, codec, replicas...), func() {
+		for _, closeDB := range closers {
+			closeDB()
+		}
+	}
+}
+
+func poolConfig(cfg config.Config) db.PoolConfig {
+	return db.PoolConfig{
+		MaxOpenConns:     cfg.DBMaxOpenConns,
+		MaxIdleConns:     cfg.DBMaxIdleConns,
+		ConnMaxLifetime:  cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.DBConnMaxIdleTime,
+		StatementTimeout: cfg.DBStatementTimeout,
+	}
 }
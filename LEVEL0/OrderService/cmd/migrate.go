@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"orderservice/config"
+	"orderservice/internal/db"
+	"orderservice/internal/logger"
+	"orderservice/internal/migrate"
+)
+
+const migrateUsage = `usage: orderservice migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    roll back N latest migrations (default 1)
+  status      show applied and pending migrations`
+
+// runMigrate implements "migrate" subcommand
+func runMigrate(args []string) {
+	if _, err := logger.Setup("info", "text"); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sqlDB, err := db.ConnectPostgres("primary", config.GetDSN(), db.PoolConfig{}).DB()
+	if err != nil {
+		logger.Fatal("Failed to retrieve sql.DB", logger.Err(err))
+	}
+	defer sqlDB.Close()
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", logger.Err(err))
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			logger.Fatal("Migration failed", logger.Err(err))
+		}
+		slog.Info("Schema is up to date", "applied", n, "version", migrator.Latest())
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				logger.Fatal("Invalid number of steps", "value", args[1])
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			logger.Fatal("Rollback failed", logger.Err(err))
+		}
+		slog.Info("Migrations rolled back", "count", n)
+	case "status":
+		st, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Fatal("Failed to read schema version", logger.Err(err))
+		}
+		printStatus(st)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func printStatus(st migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "VERSION\tNAME\tSTATUS\n")
+	for _, a := range st.Applied {
+		fmt.Fprintf(w, "%04d\t%s\tapplied %s\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+	}
+	for _, m := range st.Pending {
+		fmt.Fprintf(w, "%04d\t%s\tpending\n", m.Version, m.Name)
+	}
+	w.Flush()
+	fmt.Printf("\ncurrent version: %d, latest: %d\n", st.Current, st.Latest)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"orderservice/config"
+	"orderservice/internal/logger"
+	"orderservice/internal/repository"
+)
+
+const reencryptUsage = `usage: orderservice reencrypt [-batch N]
+
+Re-encrypts personal data stored with old keys or in plaintext with the active key (PII_ACTIVE_KEY)
+and decrypts fields removed from PII_FIELDS. Rows are updated in batches, the command can be interrupted
+and started again. Old keys can be removed from PII_KEYS after it finishes.`
+
+// runReencrypt implements "reencrypt" subcommand
+func runReencrypt(args []string) {
+	if _, err := logger.Setup("info", "text"); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	cfg := config.GetConfig()
+	flags := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, reencryptUsage) }
+	batchSize := flags.Int("batch", cfg.PIIReencryptBatchSize, "rows per transaction")
+	_ = flags.Parse(args)
+	if *batchSize <= 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	codec := newPIICodec(cfg)
+	if codec == nil {
+		logger.Fatal("Env variable is not set", "key", "PII_KEYS")
+	}
+	repo, closeRepo := newRepository(cfg, codec)
+	defer closeRepo()
+	piiRepo, ok := repo.(repository.PIIRepository)
+	if !ok {
+		logger.Fatal("Storage does not keep personal data encrypted", "storage", cfg.Storage)
+	}
+
+	n, err := piiRepo.ReencryptPII(ctx, *batchSize)
+	if err != nil {
+		logger.Fatal("PII re-encryption failed", "rows", n, logger.Err(err))
+	}
+	slog.Info("PII re-encryption finished", "rows", n, "active_key", codec.ActiveKey())
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"orderservice/config"
+	"orderservice/internal/cache"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
+	"orderservice/internal/repository"
+	"orderservice/internal/service"
+)
+
+const replayUsage = `usage: orderservice replay [-batch N] <file|->
+
+Loads orders and order events from NDJSON file (one JSON per line, the format of internal/kafka/mocks.json)
+or from stdin ("-") through the same pipeline as Kafka messages. Rejected lines are saved to InvalidRequests,
+Kafka is not used.`
+
+// runReplay implements "replay" subcommand
+func runReplay(args []string) {
+	if _, err := logger.Setup("info", "text"); err != nil {
+		logger.Fatal("Failed to configure logger", logger.Err(err))
+	}
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, replayUsage) }
+	batchSize := flags.Int("batch", 100, "messages per transaction")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	name := flags.Arg(0)
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			logger.Fatal("Failed to open file", logger.Err(err))
+		}
+		defer file.Close()
+		in = file
+	} else {
+		name = "stdin"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.GetConfig()
+	baseRepo, closeRepo := newRepository(cfg, newPIICodec(cfg))
+	defer closeRepo()
+	repo := repository.NewResilientRepository(baseRepo, resilienceConfig(cfg))
+	// кеш нужен только для проверки дубликатов внутри запуска
+	orderCache, err := cache.New(cache.Config{Policy: cfg.CachePolicy, MaxEntries: cfg.CacheMaxEntries})
+	if err != nil {
+		logger.Fatal("Failed to create cache", logger.Err(err))
+	}
+	svc := service.NewOrderService(repo, orderCache, nil)
+
+	source := &ingest.FileSource{Reader: in, Name: name, BatchSize: *batchSize}
+	if err := source.Run(ctx, svc); err != nil {
+		closeRepo()
+		logger.Fatal("Replay failed", logger.Err(err))
+	}
+}
@@ -1,51 +1,534 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/pii"
+	"orderservice/internal/ratelimit"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Storage backends selected by STORAGE env variable
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory" //данные теряются при перезапуске; для локального запуска и тестов без БД
+)
+
 // Config -
 type Config struct {
-	DSN                 string
+	Storage             string
+	DSN                 string   // не требуется для StorageMemory
+	ReplicaDSNs         []string // реплики для чтения; пусто - все запросы идут в DSN
 	AppPort             string
-	KafkaBroker         string
+	GRPCPort            string // пусто - gRPC API отключен
+	KafkaBroker         string // пусто - Kafka отключена, заказы принимаются только через HTTP и replay
 	Topic               string
+	DLQTopic            string
 	LaunchMockGenerator bool
+	Generator           GeneratorConfig
+
+	ConsumerWorkers         int
+	ConsumerBatchSize       int
+	ConsumerBatchTimeout    time.Duration
+	ConsumerRetryBackoff    time.Duration
+	ConsumerMaxRetryBackoff time.Duration
+
+	OutboxTopic        string // топик событий order.persisted
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxRetention    time.Duration // сколько хранить опубликованные сообщения outbox
+	OutboxMaxAttempts  int           // после стольких неудачных публикаций сообщение outbox откладывается
+
+	DBRetryAttempts      int // попыток одного запроса к БД, включая первую
+	DBRetryBackoff       time.Duration
+	DBMaxRetryBackoff    time.Duration
+	DBRetryJitter        float64
+	DBBreakerFailures    int           // ошибок недоступности подряд, после которых запросы к БД не выполняются
+	DBBreakerOpenTimeout time.Duration // сколько ждать перед пробным запросом
+
+	DBMaxOpenConns     int // на каждый пул: primary и каждую реплику
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  time.Duration
+	DBConnMaxIdleTime  time.Duration
+	DBStatementTimeout time.Duration // 0 - без ограничения
+
+	MigrateOnStart bool // применять миграции при запуске; иначе сервис только проверяет версию схемы
+
+	CachePolicy     string        // lru или lfu
+	CacheMaxEntries int           // 0 - без ограничения
+	CacheMaxBytes   int64         // 0 - без ограничения
+	CacheTTL        time.Duration // 0 - без ограничения
+
+	CacheWarmUp         string // none, count, age или snapshot
+	CacheWarmUpCount    int
+	CacheWarmUpAge      time.Duration
+	CacheWarmUpPageSize int
+	CacheSnapshotPath   string        // пусто - снимок кеша при остановке не сохраняется
+	CacheSnapshotMaxAge time.Duration // более старый снимок игнорируется
+	CacheInvalidation   bool          // согласовывать кеш с изменениями других экземпляров через LISTEN/NOTIFY, только для Postgres
+
+	AuthEnabled       bool     // без аутентификации любой клиент видит заказы с персональными данными
+	AuthAPIKeys       string   // subject:role:key через запятую
+	AuthJWTSecret     string   // общий секрет HS256
+	AuthJWTPublicKeys []string // файлы PEM с открытыми ключами RS/ES/EdDSA
+	AuthJWTIssuer     string
+	AuthJWTAudience   string
+	AuthJWTRoleClaim  string
+	AuthJWTLeeway     time.Duration // допустимое расхождение часов при проверке exp и nbf
+
+	PIIKeys               string     // id:base64 через запятую; пусто и без PIIKeyFile - данные хранятся открыто
+	PIIKeyFile            string     // файл ключей в том же формате, по ключу на строку
+	PIIActiveKey          string     // ключ для шифрования новых данных; пусто - последний из списка
+	PIIPolicy             pii.Policy // поля, которые шифруются в БД и маскируются в логах и HTML
+	PIIShowInHTML         bool       // показывать персональные данные на HTML-страницах ролям, которым они доступны
+	PIIReencrypt          bool       // перешифровывать строки активным ключом при запуске
+	PIIReencryptBatchSize int
+
+	HTTPRequestTimeout time.Duration              // дедлайн обработки запроса, включая запросы к БД
+	RateLimit          ratelimit.Limit            // на клиента по всем маршрутам без собственного лимита
+	RateLimitRoutes    map[string]ratelimit.Limit // собственные лимиты маршрутов: "METHOD /pattern"
+	AuthFailureLimit   ratelimit.Limit            // неудачных аутентификаций с одного IP
+	HTTPCompression    bool                       // gzip для HTML и JSON
+
+	ExportBatchSize int // заказов за один FETCH из курсора при выгрузке
+
+	LogLevel  string // debug, info, warn или error
+	LogFormat string // json или text
 }
 
-// GetConfig -
-func GetConfig() Config {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found")
-	}
+// GeneratorConfig configures the mock order generator started by START_MOCK_PRODUCER and the generate subcommand
+type GeneratorConfig struct {
+	Rate           float64 // сообщений в секунду
+	MinItems       int
+	MaxItems       int
+	Locales        []string
+	Currencies     []string
+	BrokenFraction float64 // доля намеренно некорректных сообщений
+}
+
+var loadEnvOnce sync.Once
 
+// loadEnv reads .env once; variables already set in the environment take precedence
+func loadEnv() {
+	loadEnvOnce.Do(func() {
+		if err := godotenv.Load(); err != nil {
+			slog.Warn(".env file not found")
+		}
+	})
+}
+
+// GetDSN returns only DB connection string, used by the migrate subcommand which does not need the rest of config
+func GetDSN() string {
+	loadEnv()
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set in env")
+		logger.Fatal("Env variable is not set", "key", "DATABASE_URL")
+	}
+	return dsn
+}
+
+// GetGeneratorConfig reads MOCK_* variables
+func GetGeneratorConfig() GeneratorConfig {
+	loadEnv()
+	cfg := GeneratorConfig{
+		Rate:           0.2, //как раньше: одно сообщение в 5 секунд
+		MinItems:       1,
+		MaxItems:       3,
+		Locales:        getEnvList("MOCK_LOCALES", "en,ru"),
+		Currencies:     getEnvList("MOCK_CURRENCIES", "USD,RUB,EUR"),
+		BrokenFraction: 0.1,
+	}
+	var err error
+	if v := os.Getenv("MOCK_RATE"); v != "" {
+		if cfg.Rate, err = strconv.ParseFloat(v, 64); err != nil || cfg.Rate < 0 {
+			logger.Fatal("Invalid env variable", "key", "MOCK_RATE")
+		}
+	}
+	if v := os.Getenv("MOCK_ITEMS"); v != "" {
+		if cfg.MinItems, cfg.MaxItems, err = ParseRange(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "MOCK_ITEMS")
+		}
+	}
+	if v := os.Getenv("MOCK_BROKEN_FRACTION"); v != "" {
+		if cfg.BrokenFraction, err = strconv.ParseFloat(v, 64); err != nil || cfg.BrokenFraction < 0 || cfg.BrokenFraction > 1 {
+			logger.Fatal("Invalid env variable", "key", "MOCK_BROKEN_FRACTION")
+		}
+	}
+	return cfg
+}
+
+// ParseRange parses "N" or "MIN-MAX" of positive integers
+func ParseRange(s string) (lo, hi int, err error) {
+	from, to, found := strings.Cut(s, "-")
+	if lo, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	hi = lo
+	if found {
+		if hi, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return 0, 0, err
+		}
+	}
+	if lo < 1 || hi < lo {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	return lo, hi, nil
+}
+
+// GetConfig -
+func GetConfig() Config {
+	loadEnv()
+	storage := os.Getenv("STORAGE")
+	switch storage {
+	case "":
+		storage = StoragePostgres
+	case StoragePostgres, StorageMemory:
+	default:
+		logger.Fatal("Invalid env variable", "key", "STORAGE")
+	}
+	var dsn string
+	var replicaDSNs []string
+	if storage == StoragePostgres {
+		dsn = GetDSN()
+		replicaDSNs = getEnvList("DATABASE_REPLICA_URLS", "")
 	}
 
 	port := os.Getenv("APP_PORT")
 	if port == "" {
-		log.Fatal("APP_PORT is not set in env")
+		logger.Fatal("Env variable is not set", "key", "APP_PORT")
 	}
 
-	broker := os.Getenv("KAFKA_BROKER")
-	if broker == "" {
-		log.Fatal("KAFKA_BROKER is not set in env")
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort != "" && grpcPort == port {
+		logger.Fatal("gRPC and HTTP ports must differ", "key", "GRPC_PORT")
 	}
 
+	broker := os.Getenv("KAFKA_BROKER")
 	topic := os.Getenv("KAFKA_TOPIC")
-	if topic == "" {
-		log.Fatal("KAFKA_TOPIC is not set in env")
+	if broker != "" && topic == "" {
+		logger.Fatal("Env variable is not set", "key", "KAFKA_TOPIC")
+	}
+
+	dlqTopic := os.Getenv("KAFKA_DLQ_TOPIC")
+	if dlqTopic == "" && topic != "" {
+		dlqTopic = topic + ".dlq"
+	}
+
+	outboxTopic := os.Getenv("OUTBOX_TOPIC")
+	if outboxTopic == "" && topic != "" {
+		outboxTopic = topic + ".persisted"
+	}
+
+	var mockStart bool
+	var err error
+	if v := os.Getenv("START_MOCK_PRODUCER"); v != "" || broker != "" {
+		if mockStart, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "START_MOCK_PRODUCER")
+		}
+	}
+	if mockStart && broker == "" {
+		logger.Fatal("Mock producer requires Kafka", "key", "KAFKA_BROKER")
+	}
+	migrateOnStart := true
+	if v := os.Getenv("MIGRATE_ON_START"); v != "" {
+		if migrateOnStart, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "MIGRATE_ON_START")
+		}
+	}
+
+	workers := getEnvInt("KAFKA_WORKERS", 4)
+	batchSize := getEnvInt("KAFKA_BATCH_SIZE", 100)
+	batchTimeout := getEnvDuration("KAFKA_BATCH_TIMEOUT", time.Second)
+	retryBackoff := getEnvDuration("KAFKA_RETRY_BACKOFF", 500*time.Millisecond)
+	maxRetryBackoff := getEnvDuration("KAFKA_MAX_RETRY_BACKOFF", 30*time.Second)
+
+	dbRetryAttempts := getEnvInt("DB_RETRY_ATTEMPTS", 3)
+	dbRetryBackoff := getEnvDuration("DB_RETRY_BACKOFF", 100*time.Millisecond)
+	dbMaxRetryBackoff := getEnvDuration("DB_MAX_RETRY_BACKOFF", time.Second)
+	dbRetryJitter := 0.5
+	if v := os.Getenv("DB_RETRY_JITTER"); v != "" {
+		if dbRetryJitter, err = strconv.ParseFloat(v, 64); err != nil || dbRetryJitter < 0 || dbRetryJitter > 1 {
+			logger.Fatal("Invalid env variable", "key", "DB_RETRY_JITTER")
+		}
+	}
+	dbBreakerFailures := getEnvInt("DB_BREAKER_FAILURES", 5)
+	dbBreakerOpenTimeout := getEnvDuration("DB_BREAKER_OPEN_TIMEOUT", 10*time.Second)
+
+	dbMaxOpenConns := getEnvInt("DB_MAX_OPEN_CONNS", 25)
+	dbMaxIdleConns := getEnvInt("DB_MAX_IDLE_CONNS", 10)
+	if dbMaxIdleConns > dbMaxOpenConns {
+		logger.Fatal("Idle connections limit exceeds open connections limit", "key", "DB_MAX_IDLE_CONNS")
+	}
+	dbConnMaxLifetime := getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	dbConnMaxIdleTime := getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+	var dbStatementTimeout time.Duration
+	if v := os.Getenv("DB_STATEMENT_TIMEOUT"); v != "" {
+		if dbStatementTimeout, err = time.ParseDuration(v); err != nil || dbStatementTimeout < 0 {
+			logger.Fatal("Invalid env variable", "key", "DB_STATEMENT_TIMEOUT")
+		}
+	}
+
+	outboxPollInterval := getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	outboxBatchSize := getEnvInt("OUTBOX_BATCH_SIZE", 100)
+	outboxRetention := getEnvDuration("OUTBOX_RETENTION", 24*time.Hour)
+	outboxMaxAttempts := getEnvInt("OUTBOX_MAX_ATTEMPTS", 100)
+
+	cachePolicy := os.Getenv("CACHE_POLICY")
+	if cachePolicy == "" {
+		cachePolicy = "lru"
 	}
 
-	mockStart, err := strconv.ParseBool(os.Getenv("START_MOCK_PRODUCER"))
+	cacheMaxEntries := 1000
+	if v := os.Getenv("CACHE_MAX_ENTRIES"); v != "" {
+		if cacheMaxEntries, err = strconv.Atoi(v); err != nil || cacheMaxEntries < 0 {
+			logger.Fatal("Invalid env variable", "key", "CACHE_MAX_ENTRIES")
+		}
+	}
+
+	var cacheMaxBytes int64
+	if v := os.Getenv("CACHE_MAX_BYTES"); v != "" {
+		if cacheMaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil || cacheMaxBytes < 0 {
+			logger.Fatal("Invalid env variable", "key", "CACHE_MAX_BYTES")
+		}
+	}
+
+	var cacheTTL time.Duration
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		if cacheTTL, err = time.ParseDuration(v); err != nil || cacheTTL < 0 {
+			logger.Fatal("Invalid env variable", "key", "CACHE_TTL")
+		}
+	}
+
+	cacheWarmUp := os.Getenv("CACHE_WARMUP")
+	switch cacheWarmUp {
+	case "":
+		cacheWarmUp = "count"
+	case "none", "count", "age", "snapshot":
+	default:
+		logger.Fatal("Invalid env variable", "key", "CACHE_WARMUP")
+	}
+	cacheSnapshotPath := os.Getenv("CACHE_SNAPSHOT_PATH")
+	if cacheWarmUp == "snapshot" && cacheSnapshotPath == "" {
+		logger.Fatal("Env variable is not set", "key", "CACHE_SNAPSHOT_PATH")
+	}
+	cacheWarmUpCount := getEnvInt("CACHE_WARMUP_COUNT", 1000)
+	cacheWarmUpAge := getEnvDuration("CACHE_WARMUP_AGE", 7*24*time.Hour)
+	cacheWarmUpPageSize := getEnvInt("CACHE_WARMUP_PAGE_SIZE", 100)
+	cacheSnapshotMaxAge := getEnvDuration("CACHE_SNAPSHOT_MAX_AGE", time.Hour)
+	cacheInvalidation := storage == StoragePostgres
+	if v := os.Getenv("CACHE_INVALIDATION"); v != "" {
+		if cacheInvalidation, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "CACHE_INVALIDATION")
+		}
+	}
+	if cacheInvalidation && storage != StoragePostgres {
+		logger.Fatal("Cache invalidation requires Postgres storage", "key", "CACHE_INVALIDATION")
+	}
+
+	authEnabled := true
+	if v := os.Getenv("AUTH_ENABLED"); v != "" {
+		if authEnabled, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "AUTH_ENABLED")
+		}
+	}
+	authAPIKeys := os.Getenv("AUTH_API_KEYS")
+	authJWTSecret := os.Getenv("AUTH_JWT_HMAC_SECRET")
+	authJWTPublicKeys := getEnvList("AUTH_JWT_PUBLIC_KEYS", "")
+	if authEnabled && authAPIKeys == "" && authJWTSecret == "" && len(authJWTPublicKeys) == 0 {
+		logger.Fatal("Auth is enabled but no API keys or JWT keys are configured", "key", "AUTH_API_KEYS")
+	}
+	authJWTRoleClaim := os.Getenv("AUTH_JWT_ROLE_CLAIM")
+	if authJWTRoleClaim == "" {
+		authJWTRoleClaim = "role"
+	}
+	authJWTLeeway := getEnvDuration("AUTH_JWT_LEEWAY", 30*time.Second)
+
+	piiPolicy, err := pii.NewPolicy(getEnvList("PII_FIELDS", strings.Join(pii.DefaultFields, ","))...)
 	if err != nil {
-		log.Fatal("START_MOCK_PRODUCER has invalid format in env.")
+		logger.Fatal("Invalid env variable", "key", "PII_FIELDS", "err", err)
+	}
+	piiShowInHTML := false
+	if v := os.Getenv("PII_SHOW_IN_HTML"); v != "" {
+		if piiShowInHTML, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "PII_SHOW_IN_HTML")
+		}
+	}
+	piiReencrypt := true
+	if v := os.Getenv("PII_REENCRYPT_ON_START"); v != "" {
+		if piiReencrypt, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "PII_REENCRYPT_ON_START")
+		}
+	}
+	piiReencryptBatchSize := getEnvInt("PII_REENCRYPT_BATCH_SIZE", 500)
+
+	httpRequestTimeout := getEnvDuration("HTTP_REQUEST_TIMEOUT", 5*time.Second)
+	rateLimitSpec := os.Getenv("RATE_LIMIT")
+	if rateLimitSpec == "" {
+		rateLimitSpec = "20:40"
+	}
+	rateLimit, err := ratelimit.ParseLimit(rateLimitSpec)
+	if err != nil {
+		logger.Fatal("Invalid env variable", "key", "RATE_LIMIT", "err", err)
+	}
+	rateLimitRoutes, err := ratelimit.ParseRoutes(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		logger.Fatal("Invalid env variable", "key", "RATE_LIMIT_ROUTES", "err", err)
+	}
+	authFailureLimitSpec := os.Getenv("RATE_LIMIT_AUTH_FAILURES")
+	if authFailureLimitSpec == "" {
+		authFailureLimitSpec = "0.1:10"
+	}
+	authFailureLimit, err := ratelimit.ParseLimit(authFailureLimitSpec)
+	if err != nil {
+		logger.Fatal("Invalid env variable", "key", "RATE_LIMIT_AUTH_FAILURES", "err", err)
+	}
+	httpCompression := true
+	if v := os.Getenv("HTTP_COMPRESSION"); v != "" {
+		if httpCompression, err = strconv.ParseBool(v); err != nil {
+			logger.Fatal("Invalid env variable", "key", "HTTP_COMPRESSION")
+		}
+	}
+	exportBatchSize := getEnvInt("EXPORT_BATCH_SIZE", 500)
+
+	logLevel := os.Getenv("LOG_LEVEL")
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "json"
+	}
+
+	return Config{
+		Storage:             storage,
+		DSN:                 dsn,
+		ReplicaDSNs:         replicaDSNs,
+		AppPort:             port,
+		GRPCPort:            grpcPort,
+		KafkaBroker:         broker,
+		Topic:               topic,
+		DLQTopic:            dlqTopic,
+		LaunchMockGenerator: mockStart,
+		Generator:           GetGeneratorConfig(),
+		MigrateOnStart:      migrateOnStart,
+
+		ConsumerWorkers:         workers,
+		ConsumerBatchSize:       batchSize,
+		ConsumerBatchTimeout:    batchTimeout,
+		ConsumerRetryBackoff:    retryBackoff,
+		ConsumerMaxRetryBackoff: maxRetryBackoff,
+
+		DBRetryAttempts:      dbRetryAttempts,
+		DBRetryBackoff:       dbRetryBackoff,
+		DBMaxRetryBackoff:    dbMaxRetryBackoff,
+		DBRetryJitter:        dbRetryJitter,
+		DBBreakerFailures:    dbBreakerFailures,
+		DBBreakerOpenTimeout: dbBreakerOpenTimeout,
+
+		DBMaxOpenConns:     dbMaxOpenConns,
+		DBMaxIdleConns:     dbMaxIdleConns,
+		DBConnMaxLifetime:  dbConnMaxLifetime,
+		DBConnMaxIdleTime:  dbConnMaxIdleTime,
+		DBStatementTimeout: dbStatementTimeout,
+
+		OutboxTopic:        outboxTopic,
+		OutboxPollInterval: outboxPollInterval,
+		OutboxBatchSize:    outboxBatchSize,
+		OutboxRetention:    outboxRetention,
+		OutboxMaxAttempts:  outboxMaxAttempts,
+
+		CachePolicy:     cachePolicy,
+		CacheMaxEntries: cacheMaxEntries,
+		CacheMaxBytes:   cacheMaxBytes,
+		CacheTTL:        cacheTTL,
+
+		CacheWarmUp:         cacheWarmUp,
+		CacheWarmUpCount:    cacheWarmUpCount,
+		CacheWarmUpAge:      cacheWarmUpAge,
+		CacheWarmUpPageSize: cacheWarmUpPageSize,
+		CacheSnapshotPath:   cacheSnapshotPath,
+		CacheSnapshotMaxAge: cacheSnapshotMaxAge,
+		CacheInvalidation:   cacheInvalidation,
+
+		AuthEnabled:       authEnabled,
+		AuthAPIKeys:       authAPIKeys,
+		AuthJWTSecret:     authJWTSecret,
+		AuthJWTPublicKeys: authJWTPublicKeys,
+		AuthJWTIssuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		AuthJWTAudience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+		AuthJWTRoleClaim:  authJWTRoleClaim,
+		AuthJWTLeeway:     authJWTLeeway,
+
+		PIIKeys:               os.Getenv("PII_KEYS"),
+		PIIKeyFile:            os.Getenv("PII_KEY_FILE"),
+		PIIActiveKey:          os.Getenv("PII_ACTIVE_KEY"),
+		PIIPolicy:             piiPolicy,
+		PIIShowInHTML:         piiShowInHTML,
+		PIIReencrypt:          piiReencrypt,
+		PIIReencryptBatchSize: piiReencryptBatchSize,
+
+		HTTPRequestTimeout: httpRequestTimeout,
+		RateLimit:          rateLimit,
+		RateLimitRoutes:    rateLimitRoutes,
+		AuthFailureLimit:   authFailureLimit,
+		HTTPCompression:    httpCompression,
+
+		ExportBatchSize: exportBatchSize,
+
+		LogLevel:  logLevel,
+		LogFormat: logFormat,
+	}
+}
+
+// KafkaEnabled reports whether orders are consumed from Kafka
+func (c Config) KafkaEnabled() bool {
+	return c.KafkaBroker != ""
+}
+
+// getEnvInt returns positive integer from env or default value if variable is not set
+func getEnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		logger.Fatal("Invalid env variable", "key", key)
+	}
+	return n
+}
+
+// getEnvList returns comma-separated values from env or def
+func getEnvList(key, def string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		v = def
+	}
+	var values []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// getEnvDuration returns positive duration from env or default value if variable is not set
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		logger.Fatal("Invalid env variable", "key", key)
 	}
-	return Config{DSN: dsn, AppPort: port, KafkaBroker: broker, Topic: topic, LaunchMockGenerator: mockStart}
+	return d
 }
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"orderservice/internal/model"
+	"orderservice/internal/service"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxReplayBodySize = 1 << 20
+
+// AdminHandler provides access to dead-letter management in Service layer
+type AdminHandler struct {
+	Service service.DeadLetterService
+}
+
+// replayResult is returned by Replay: updated record and the processing error(if any)
+type replayResult struct {
+	Request *model.InvalidRequest `json:"request"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// ListInvalidRequests returns rejected messages, supports "status", "limit" and "offset" query parameters
+func (AH *AdminHandler) ListInvalidRequests(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, offset := 0, 0
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "некорректный limit")
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "некорректный offset")
+			return
+		}
+	}
+
+	requests, err := AH.Service.ListInvalidRequests(r.Context(), q.Get("status"), limit, offset)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// GetInvalidRequest returns a single rejected message by ID from URL
+func (AH *AdminHandler) GetInvalidRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	req, err := AH.Service.GetInvalidRequest(r.Context(), id)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+// ReplayInvalidRequest sends rejected message through the normal order pipeline again; non-empty request body replaces stored payload
+func (AH *AdminHandler) ReplayInvalidRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	fixed, err := io.ReadAll(io.LimitReader(r.Body, maxReplayBodySize))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	req, err := AH.Service.ReplayInvalidRequest(r.Context(), id, fixed)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, replayResult{Request: req})
+	case req != nil && !errors.Is(err, service.ErrStatusTransition):
+		// запись обновлена, но заказ снова не прошел обработку
+		writeJSON(w, http.StatusUnprocessableEntity, replayResult{Request: req, Error: err.Error()})
+	default:
+		writeDeadLetterError(w, err)
+	}
+}
+
+// DiscardInvalidRequest marks rejected message as discarded
+func (AH *AdminHandler) DiscardInvalidRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	req, err := AH.Service.DiscardInvalidRequest(r.Context(), id)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func parseID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", "некорректный id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRequestNotFound):
+		writeJSONError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, service.ErrStatusTransition):
+		writeJSONError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, service.ErrUnknownStatus):
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		writeJSONError(w, http.StatusGatewayTimeout, "timeout", err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
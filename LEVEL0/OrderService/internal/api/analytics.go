@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"orderservice/internal/model"
+	"orderservice/internal/service"
+	"orderservice/internal/web"
+	"strconv"
+	"strings"
+)
+
+// AnalyticsHandler provides access to order aggregates in Service layer
+type AnalyticsHandler struct {
+	Service service.AnalyticsService
+}
+
+// analyticsPageData is passed to analytics.gohtml
+type analyticsPageData struct {
+	Query      url.Values
+	Analytics  *model.Analytics
+	Revenue    []revenueSeries
+	Deliveries []bar[model.DeliveryStat]
+	Error      string
+}
+
+// revenueSeries is revenue chart of one currency
+type revenueSeries struct {
+	Currency string
+	Orders   int64
+	Revenue  int64
+	Points   []bar[model.RevenuePoint]
+}
+
+// bar is a table row with a bar of Percent width relative to the largest value
+type bar[T any] struct {
+	Row     T
+	Percent int
+}
+
+// GetAnalyticsJSON returns order aggregates matching query parameters as JSON
+func (AH *AnalyticsHandler) GetAnalyticsJSON(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAnalyticsFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	analytics, err := AH.Service.GetAnalytics(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidFilter):
+			writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			writeJSONError(w, http.StatusGatewayTimeout, "timeout", err.Error())
+		case errors.Is(err, service.ErrStorageUnavailable):
+			writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(analytics)
+}
+
+// AnalyticsPage renders HTML page with filter form, revenue charts and top tables
+func (AH *AnalyticsHandler) AnalyticsPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := analyticsPageData{Query: query}
+
+	filter, err := parseAnalyticsFilter(query)
+	if err != nil {
+		data.Error = err.Error()
+		web.Render(w, "analytics", data)
+		return
+	}
+
+	analytics, err := AH.Service.GetAnalytics(r.Context(), filter)
+	if err != nil {
+		data.Error = "Ошибка при расчете статистики: " + err.Error()
+		web.Render(w, "analytics", data)
+		return
+	}
+
+	data.Analytics = analytics
+	data.Revenue = revenueCharts(analytics.Revenue)
+	data.Deliveries = bars(analytics.Deliveries, func(d model.DeliveryStat) int64 { return d.Orders })
+	web.Render(w, "analytics", data)
+}
+
+// revenueCharts splits revenue points by currency, currencies are kept in order of appearance
+func revenueCharts(points []model.RevenuePoint) []revenueSeries {
+	var series []revenueSeries
+	index := make(map[string]int)
+	byCurrency := make(map[string][]model.RevenuePoint)
+	for _, p := range points {
+		if _, ok := index[p.Currency]; !ok {
+			index[p.Currency] = len(series)
+			series = append(series, revenueSeries{Currency: p.Currency})
+		}
+		s := &series[index[p.Currency]]
+		s.Orders += p.Orders
+		s.Revenue += p.Revenue
+		byCurrency[p.Currency] = append(byCurrency[p.Currency], p)
+	}
+	for i := range series {
+		series[i].Points = bars(byCurrency[series[i].Currency], func(p model.RevenuePoint) int64 { return p.Revenue })
+	}
+	return series
+}
+
+// bars returns rows with bar width proportional to value
+func bars[T any](rows []T, value func(T) int64) []bar[T] {
+	var maxValue int64
+	for _, row := range rows {
+		maxValue = max(maxValue, value(row))
+	}
+	result := make([]bar[T], len(rows))
+	for i, row := range rows {
+		result[i].Row = row
+		if maxValue > 0 {
+			result[i].Percent = int(value(row) * 100 / maxValue)
+		}
+	}
+	return result
+}
+
+// parseAnalyticsFilter builds model.AnalyticsFilter from query parameters
+func parseAnalyticsFilter(q url.Values) (model.AnalyticsFilter, error) {
+	filter := model.AnalyticsFilter{
+		Interval: strings.TrimSpace(q.Get("interval")),
+		TopBy:    strings.TrimSpace(q.Get("top_by")),
+	}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("некорректный limit: %q", v)
+		}
+	}
+	if v := q.Get("date_from"); v != "" {
+		if filter.DateFrom, _, err = parseDate(v); err != nil {
+			return filter, fmt.Errorf("некорректная date_from: %q", v)
+		}
+	}
+	if v := q.Get("date_to"); v != "" {
+		dateTo, dayOnly, err := parseDate(v)
+		if err != nil {
+			return filter, fmt.Errorf("некорректная date_to: %q", v)
+		}
+		if dayOnly { // дата без времени - включаем весь день
+			dateTo = dateTo.AddDate(0, 0, 1)
+		}
+		filter.DateTo = dateTo
+	}
+	return filter, nil
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
+	"orderservice/internal/logger"
+	"orderservice/internal/model"
+	"orderservice/internal/service"
+	"time"
+)
+
+// ExportHandler streams bulk exports of orders provided by Service layer
+type ExportHandler struct {
+	Service service.ExportService
+}
+
+// ExportOrders streams orders matching query parameters(the same as of ListOrdersJSON) as a file of the "format" parameter:
+// csv(default), ndjson or parquet. Personal data is masked for roles without access to it.
+// The route must not have a request deadline: the export takes as long as the client reads it
+func (EH *ExportHandler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	body := &responseBody{w: w}
+	writer, err := export.NewWriter(format, body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	//WriteTimeout сервера рассчитан на обычные ответы, выгрузка может передаваться дольше
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(r.Context(), "Failed to clear write deadline of export", logger.Err(err))
+	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.%s"`, time.Now().UTC().Format("20060102-150405"), format))
+
+	start := time.Now()
+	n, err := EH.Service.ExportOrders(r.Context(), filter, &redactingWriter{ctx: r.Context(), next: writer})
+	if err == nil {
+		slog.InfoContext(r.Context(), "Orders exported", "format", format, "orders", n, "bytes", body.n, "duration", time.Since(start))
+		return
+	}
+	if body.n > 0 {
+		//статус уже отправлен: обрываем соединение, чтобы клиент не принял неполный файл за целый
+		slog.ErrorContext(r.Context(), "Export interrupted", "format", format, "orders", n, "bytes", body.n, logger.Err(err))
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Disposition")
+	switch {
+	case errors.Is(err, service.ErrInvalidFilter):
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+	case errors.Is(err, context.Canceled):
+		//клиент ушел, отвечать некому
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+	}
+}
+
+// responseBody counts bytes written to the response, while it is 0 the status can still be changed
+type responseBody struct {
+	w io.Writer
+	n int64
+}
+
+func (RB *responseBody) Write(p []byte) (int, error) {
+	n, err := RB.w.Write(p)
+	RB.n += int64(n)
+	return n, err
+}
+
+// redactingWriter masks personal data of orders for the principal of ctx before writing them
+type redactingWriter struct {
+	ctx  context.Context
+	next export.Writer
+}
+
+func (RW *redactingWriter) Write(order *model.Order) error {
+	return RW.next.Write(auth.Redact(RW.ctx, order))
+}
+
+func (RW *redactingWriter) Close() error {
+	return RW.next.Close()
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"orderservice/internal/auth"
+	"orderservice/internal/model"
 	"orderservice/internal/service"
 	"orderservice/internal/web"
 
@@ -13,6 +15,18 @@ import (
 // OrderHandler provides access to Service layer
 type OrderHandler struct {
 	Service service.OrderService
+	// ShowPII shows personal data on HTML pages to roles allowed to see it; otherwise pages always show it masked
+	// and full data is available only via JSON and gRPC API
+	ShowPII bool
+}
+
+// pageOrder returns order as it is shown on HTML pages
+func (OH *OrderHandler) pageOrder(r *http.Request, order *model.Order) *model.Order {
+	if OH.ShowPII {
+		return auth.Redact(r.Context(), order)
+	}
+	masked := order.Masked()
+	return &masked
 }
 
 // GetOrderInfo provides order info by its ID from URL
@@ -30,7 +44,10 @@ func (OH *OrderHandler) GetOrderInfo(w http.ResponseWriter, r *http.Request) {
 			web.Render(w, "error", "Заказ с таким UID не найден")
 			return
 		case errors.Is(err, context.DeadlineExceeded):
-			http.Error(w, err.Error(), http.StatusRequestTimeout)
+			renderError(w, http.StatusGatewayTimeout, "База данных не ответила вовремя. Повторите попытку позже")
+			return
+		case errors.Is(err, service.ErrStorageUnavailable):
+			web.Render(w, "error", "Заказа нет в кеше, а база данных временно недоступна. Повторите попытку позже")
 			return
 		default:
 			web.Render(w, "error", "Ошибка при поиске заказа: "+err.Error())
@@ -38,5 +55,5 @@ func (OH *OrderHandler) GetOrderInfo(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	// Успех
-	web.Render(w, "order", order)
+	web.Render(w, "order", OH.pageOrder(r, order))
 }
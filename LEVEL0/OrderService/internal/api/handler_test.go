@@ -2,30 +2,52 @@ package handler_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	handler "orderservice/internal/api"
+	"orderservice/internal/auth"
+	"orderservice/internal/export"
+	"orderservice/internal/health"
+	"orderservice/internal/ingest"
 	"orderservice/internal/model"
 	"orderservice/internal/service"
+	"orderservice/internal/validation"
 	"orderservice/internal/web"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/segmentio/kafka-go"
+	"github.com/go-chi/chi/v5"
 )
 
 // MockOrderService реализует интерфейс service.OrderService
 type MockOrderService struct {
 	GetOrderInfoFn func(ctx context.Context, uid string) (*model.Order, error)
+	ListOrdersFn   func(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	IngestFn       func(ctx context.Context, msg *ingest.Message) error
 }
 
 func (m *MockOrderService) GetOrderInfo(ctx context.Context, uid string) (*model.Order, error) {
 	return m.GetOrderInfoFn(ctx, uid)
 }
 
-func (m *MockOrderService) AddNewOrder(msg *kafka.Message) {
-	// просто пустышка
+func (m *MockOrderService) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	return m.ListOrdersFn(ctx, filter)
+}
+
+func (m *MockOrderService) WatchOrders(ctx context.Context, filter model.OrderFilter, send func(update *model.OrderUpdate) error) error {
+	return nil
+}
+
+func (m *MockOrderService) Ingest(ctx context.Context, msg *ingest.Message) error {
+	return m.IngestFn(ctx, msg)
+}
+
+func (m *MockOrderService) ProcessBatch(ctx context.Context, msgs []ingest.Message) error {
+	return nil
 }
 
 func TestGetOrderInfo(t *testing.T) {
@@ -71,8 +93,8 @@ func TestGetOrderInfo(t *testing.T) {
 			serviceFn: func(ctx context.Context, uid string) (*model.Order, error) {
 				return nil, context.DeadlineExceeded
 			},
-			wantBody:     "context deadline exceeded",
-			wantHTTPCode: http.StatusRequestTimeout,
+			wantBody:     "База данных не ответила вовремя",
+			wantHTTPCode: http.StatusGatewayTimeout,
 		},
 		{
 			name: "other error",
@@ -87,14 +109,17 @@ func TestGetOrderInfo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/?uid="+tt.uid, nil)
+			req := httptest.NewRequest(http.MethodGet, "/order/"+tt.uid, nil)
 			w := httptest.NewRecorder()
 
 			h := &handler.OrderHandler{
 				Service: &MockOrderService{GetOrderInfoFn: tt.serviceFn},
 			}
+			r := chi.NewRouter()
+			r.Get("/order/{uid}", h.GetOrderInfo)
+			r.Get("/order/", h.GetOrderInfo)
 
-			h.GetOrderInfo(w, req)
+			r.ServeHTTP(w, req)
 			resp := w.Result()
 
 			if resp.StatusCode != tt.wantHTTPCode {
@@ -108,3 +133,410 @@ func TestGetOrderInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrderInfo_MasksPII(t *testing.T) {
+	web.LoadTemplates()
+	svc := &MockOrderService{GetOrderInfoFn: func(ctx context.Context, uid string) (*model.Order, error) {
+		return &model.Order{OrderUID: uid, Delivery: model.Delivery{Phone: "+79040000000", City: "Moscow"}}, nil
+	}}
+	support := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "crm", Role: auth.RoleSupport})
+
+	for _, tt := range []struct {
+		name      string
+		showPII   bool
+		wantPhone string
+	}{
+		{"masked by default", false, "7********00"},
+		{"shown when enabled", true, "79040000000"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler.OrderHandler{Service: svc, ShowPII: tt.showPII}
+			r := chi.NewRouter()
+			r.Get("/order/{uid}", h.GetOrderInfo)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/123", nil).WithContext(support))
+
+			body := w.Body.String()
+			if !strings.Contains(body, tt.wantPhone) || !strings.Contains(body, "Moscow") {
+				t.Errorf("expected phone %q and city in page, got %q", tt.wantPhone, body)
+			}
+		})
+	}
+}
+
+func TestGetOrderJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		uid          string
+		serviceFn    func(ctx context.Context, uid string) (*model.Order, error)
+		wantCode     string
+		wantHTTPCode int
+	}{
+		{
+			name: "order found",
+			uid:  "123",
+			serviceFn: func(ctx context.Context, uid string) (*model.Order, error) {
+				return &model.Order{OrderUID: uid, Items: []model.Item{{Brand: "b"}}}, nil
+			},
+			wantHTTPCode: http.StatusOK,
+		},
+		{
+			name: "order not found",
+			uid:  "404",
+			serviceFn: func(ctx context.Context, uid string) (*model.Order, error) {
+				return nil, service.ErrRecordNotFound
+			},
+			wantCode:     "not_found",
+			wantHTTPCode: http.StatusNotFound,
+		},
+		{
+			name: "deadline exceeded",
+			uid:  "timeout",
+			serviceFn: func(ctx context.Context, uid string) (*model.Order, error) {
+				return nil, fmt.Errorf("db: %w", context.DeadlineExceeded)
+			},
+			wantCode:     "timeout",
+			wantHTTPCode: http.StatusGatewayTimeout,
+		},
+		{
+			name: "other error",
+			uid:  "error",
+			serviceFn: func(ctx context.Context, uid string) (*model.Order, error) {
+				return nil, errors.New("oops")
+			},
+			wantCode:     "internal",
+			wantHTTPCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler.OrderHandler{
+				Service: &MockOrderService{GetOrderInfoFn: tt.serviceFn},
+			}
+			r := chi.NewRouter()
+			r.Get("/api/v1/orders/{uid}", h.GetOrderJSON)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+tt.uid, nil))
+
+			if w.Code != tt.wantHTTPCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantHTTPCode)
+			}
+			if tt.wantHTTPCode == http.StatusOK {
+				var got model.Order
+				if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.OrderUID != tt.uid || len(got.Items) != 1 {
+					t.Fatalf("unexpected body %q: %v", w.Body.String(), err)
+				}
+				if w.Header().Get("ETag") == "" {
+					t.Fatalf("expected ETag header")
+				}
+				return
+			}
+			var apiErr handler.APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+				t.Fatalf("error body is not JSON: %q", w.Body.String())
+			}
+			if apiErr.Error.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", apiErr.Error.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGetOrderJSON_IfNoneMatch(t *testing.T) {
+	h := &handler.OrderHandler{
+		Service: &MockOrderService{GetOrderInfoFn: func(ctx context.Context, uid string) (*model.Order, error) {
+			return &model.Order{OrderUID: uid}, nil
+		}},
+	}
+	r := chi.NewRouter()
+	r.Get("/api/v1/orders/{uid}", h.GetOrderJSON)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/u1", nil))
+	etag := w.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/u1", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("expected empty body for 304, got %q", w.Body.String())
+	}
+}
+
+func TestListOrdersJSON(t *testing.T) {
+	var got model.OrderFilter
+	h := &handler.OrderHandler{
+		Service: &MockOrderService{ListOrdersFn: func(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+			got = filter
+			if filter.SortBy == "phone" {
+				return nil, service.ErrInvalidFilter
+			}
+			return &model.OrderPage{Orders: []model.Order{{OrderUID: "u1"}}, NextCursor: "next"}, nil
+		}},
+	}
+	r := chi.NewRouter()
+	r.Get("/api/v1/orders", h.ListOrdersJSON)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders?customer_id=c1&brand=b&nm_id=42&date_from=2024-01-01&date_to=2024-01-31&sort=-order_uid&limit=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got.CustomerID != "c1" || got.Brand != "b" || got.NMID != 42 || got.Limit != 5 || got.SortBy != "order_uid" || !got.SortDesc {
+		t.Fatalf("unexpected filter: %+v", got)
+	}
+	if got.DateTo.Format(time.DateOnly) != "2024-02-01" {
+		t.Fatalf("date_to without time should include the whole day, got %v", got.DateTo)
+	}
+	var page model.OrderPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || len(page.Orders) != 1 || page.NextCursor != "next" {
+		t.Fatalf("unexpected body %q: %v", w.Body.String(), err)
+	}
+
+	for _, query := range []string{"nm_id=abc", "date_from=yesterday", "sort=phone"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListOrdersPage(t *testing.T) {
+	web.LoadTemplates()
+	h := &handler.OrderHandler{
+		Service: &MockOrderService{ListOrdersFn: func(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+			return &model.OrderPage{Orders: []model.Order{{OrderUID: "u1"}}, NextCursor: "next"}, nil
+		}},
+	}
+	r := chi.NewRouter()
+	r.Get("/orders", h.ListOrdersPage)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders?brand=b", nil))
+	body := w.Body.String()
+	for _, want := range []string{`href="/order/u1"`, `value="b"`, "cursor=next"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	var warmedUp health.Flag
+	checker := health.NewChecker(time.Second)
+	checker.Add("postgres", func(context.Context) error { return nil })
+	checker.Add("cache_warmup", warmedUp.Check)
+	h := handler.HealthHandler{Checker: checker}
+
+	r := chi.NewRouter()
+	r.Get("/healthz", h.Liveness)
+	r.Get("/readyz", h.Readiness)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("liveness: expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness before warm-up: expected 503, got %d", rr.Code)
+	}
+	var report health.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Checks["cache_warmup"].Status != health.StatusFail || report.Checks["postgres"].Status != health.StatusOK {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	warmedUp.Set()
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("readiness after warm-up: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	var got *ingest.Message
+	h := &handler.OrderHandler{
+		Service: &MockOrderService{IngestFn: func(ctx context.Context, msg *ingest.Message) error {
+			got = msg
+			switch {
+			case strings.Contains(string(msg.Value), "dup"):
+				return fmt.Errorf("%w: 'dup'", service.ErrOrderExists)
+			case strings.Contains(string(msg.Value), "bad"):
+				return fmt.Errorf("%w: %w", service.ErrIncompleteJson, validation.Violations{{Path: "$.payment.amount", Rule: "min"}})
+			}
+			return nil
+		}},
+	}
+	r := chi.NewRouter()
+	r.Post("/api/v1/orders", h.CreateOrder)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"order_uid":"u1"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/v1/orders/u1" {
+		t.Fatalf("expected 201 with Location, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if got.Source != ingest.SourceHTTP || string(got.Value) != `{"order_uid":"u1"}` {
+		t.Errorf("unexpected message passed to service: %+v", got)
+	}
+
+	if w := post(`{"event_type":"order.cancelled","order_uid":"u1","version":2}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for event, got %d", w.Code)
+	}
+	if w := post(`{"order_uid":"dup"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for existing order, got %d", w.Code)
+	}
+
+	w = post(`{"order_uid":"bad"}`)
+	var apiErr handler.APIError
+	if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusUnprocessableEntity || apiErr.Error.Code != "validation_failed" || len(apiErr.Error.Violations) != 1 {
+		t.Errorf("expected 422 with violations, got %d %+v", w.Code, apiErr)
+	}
+}
+
+// MockAnalyticsService реализует интерфейс service.AnalyticsService
+type MockAnalyticsService func(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error)
+
+func (m MockAnalyticsService) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	return m(ctx, filter)
+}
+
+func TestAnalytics(t *testing.T) {
+	web.LoadTemplates()
+	var got model.AnalyticsFilter
+	h := &handler.AnalyticsHandler{Service: MockAnalyticsService(func(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+		got = filter
+		if filter.Interval == "month" {
+			return nil, fmt.Errorf("%w: interval", service.ErrInvalidFilter)
+		}
+		return &model.Analytics{
+			Revenue: []model.RevenuePoint{
+				{Period: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 1, Revenue: 50},
+				{Period: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 2, Revenue: 200},
+			},
+			TopBrands:  []model.BrandStat{{Brand: "Vivienne Sabo", Currency: "USD", Quantity: 3, Revenue: 250}},
+			Deliveries: []model.DeliveryStat{{DeliveryService: "meest", Region: "Kraiot", Orders: 3}},
+		}, nil
+	})}
+	r := chi.NewRouter()
+	r.Get("/analytics", h.AnalyticsPage)
+	r.Get("/api/v1/analytics", h.GetAnalyticsJSON)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/analytics?date_from=2024-01-01&date_to=2024-01-31&interval=week&top_by=revenue&limit=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if got.Interval != model.IntervalWeek || got.TopBy != model.TopByRevenue || got.Limit != 5 ||
+		!got.DateTo.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected filter %+v", got)
+	}
+	var analytics model.Analytics
+	if err := json.NewDecoder(w.Body).Decode(&analytics); err != nil || len(analytics.Revenue) != 2 || analytics.TopBrands[0].Brand != "Vivienne Sabo" {
+		t.Errorf("unexpected analytics %+v, %v", analytics, err)
+	}
+
+	for _, query := range []string{"date_from=yesterday", "interval=month", "limit=ten"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/analytics?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analytics", nil))
+	body := w.Body.String()
+	for _, want := range []string{"Выручка, USD", "width: 25%", "width: 100%", "Vivienne Sabo", "Kraiot"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page must contain %q, got %s", want, body)
+		}
+	}
+}
+
+type MockExportService func(ctx context.Context, filter model.OrderFilter, w export.Writer) (int, error)
+
+func (m MockExportService) ExportOrders(ctx context.Context, filter model.OrderFilter, w export.Writer) (int, error) {
+	return m(ctx, filter, w)
+}
+
+func TestExportOrders(t *testing.T) {
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", Delivery: model.Delivery{Phone: "+79720000000"}, Items: []model.Item{{Name: "Mascaras"}}}
+	var got model.OrderFilter
+	var failAfterWrite bool
+	h := &handler.ExportHandler{Service: MockExportService(func(ctx context.Context, filter model.OrderFilter, w export.Writer) (int, error) {
+		got = filter
+		if !filter.DateFrom.IsZero() && !filter.DateFrom.Before(filter.DateTo) {
+			return 0, fmt.Errorf("%w: date_from", service.ErrInvalidFilter)
+		}
+		if err := w.Write(order); err != nil {
+			return 0, err
+		}
+		if failAfterWrite {
+			_ = w.Close()
+			return 1, errors.New("connection reset")
+		}
+		return 1, w.Close()
+	})}
+	r := chi.NewRouter()
+	r.Get("/api/v1/orders/export", h.ExportOrders)
+	analytics := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "finance", Role: auth.RoleAnalytics})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?brand=Vivienne+Sabo&date_to=2024-01-31", nil).WithContext(analytics))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") ||
+		!strings.Contains(w.Header().Get("Content-Disposition"), ".csv") {
+		t.Fatalf("status = %d, headers %v", w.Code, w.Header())
+	}
+	if got.Brand != "Vivienne Sabo" || !got.DateTo.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected filter %+v", got)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "b563feb7b2b84b6test") || !strings.Contains(body, "Mascaras") || strings.Contains(body, "79720000000") {
+		t.Errorf("export must contain the order with masked phone, got %q", body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?format=ndjson", nil))
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || !strings.Contains(w.Body.String(), "79720000000") {
+		t.Errorf("ndjson export without principal must be unmasked, got %v %q", w.Header(), w.Body.String())
+	}
+
+	for _, query := range []string{"format=xlsx", "nm_id=abc", "date_from=2024-02-01&date_to=2024-01-01"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?"+query, nil))
+		if w.Code != http.StatusBadRequest || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s: status = %d, headers %v, want 400 without attachment", query, w.Code, w.Header())
+		}
+	}
+
+	// ошибка после начала передачи обрывает ответ
+	failAfterWrite = true
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler panic, got %v", rec)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orders/export", nil))
+	t.Error("handler must abort the response")
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+	"orderservice/internal/health"
+)
+
+// HealthHandler exposes liveness and readiness probes for orchestrators
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+// Liveness reports that the process is running and able to serve HTTP; dependencies are not checked
+// so that a DB or Kafka outage does not make orchestrator restart the service
+func (HH *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK, Checks: map[string]health.CheckResult{}})
+}
+
+// Readiness runs all dependency checks; responds 503 if any of them failed
+func (HH *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := HH.Checker.Run(r.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
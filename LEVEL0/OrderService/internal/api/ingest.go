@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
+	"orderservice/internal/service"
+	"orderservice/internal/validation"
+)
+
+const maxIngestBodySize = 1 << 20
+
+// ingestResult is returned by CreateOrder when the message is processed
+type ingestResult struct {
+	OrderUID string `json:"order_uid"`
+}
+
+// CreateOrder accepts a single order or order event in the same JSON format as Kafka messages and processes it synchronously.
+// Plain orders answer 201 with Location, events answer 200; unlike Kafka, rejected messages are not stored in InvalidRequests
+func (OH *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "too_large", err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	msg := &ingest.Message{
+		Source:  ingest.SourceHTTP,
+		Value:   body,
+		Headers: map[string]string{ingest.HeaderCorrelationID: logger.CorrelationID(r.Context())},
+	}
+	if err := OH.Service.Ingest(r.Context(), msg); err != nil {
+		writeIngestError(w, err)
+		return
+	}
+
+	var probe struct {
+		OrderUID  string `json:"order_uid"`
+		EventType string `json:"event_type"`
+	}
+	_ = json.Unmarshal(body, &probe) //тело уже успешно разобрано сервисом
+	if probe.EventType != "" {
+		writeJSON(w, http.StatusOK, ingestResult{OrderUID: probe.OrderUID})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/orders/"+probe.OrderUID)
+	writeJSON(w, http.StatusCreated, ingestResult{OrderUID: probe.OrderUID})
+}
+
+func writeIngestError(w http.ResponseWriter, err error) {
+	var violations validation.Violations
+	switch {
+	case errors.As(err, &violations):
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(APIError{Error: APIErrorBody{Code: "validation_failed", Message: service.ErrIncompleteJson.Error(), Violations: violations}})
+	case errors.Is(err, service.ErrJSONDecode):
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+	case errors.Is(err, service.ErrOrderExists):
+		writeJSONError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		writeJSONError(w, http.StatusGatewayTimeout, "timeout", err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"orderservice/internal/auth"
+	"orderservice/internal/service"
+	"orderservice/internal/validation"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIError is a structured error body returned by JSON API
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+// APIErrorBody describes error details: machine-readable code, human-readable message and validation violations(if any)
+type APIErrorBody struct {
+	Code       string                `json:"code"`
+	Message    string                `json:"message"`
+	Violations validation.Violations `json:"violations,omitempty"`
+}
+
+// GetOrderJSON provides full order info by its ID from URL as JSON, supports ETag/If-None-Match
+func (OH *OrderHandler) GetOrderJSON(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", "order uid is required")
+		return
+	}
+
+	order, err := OH.Service.GetOrderInfo(r.Context(), uid)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRecordNotFound):
+			writeJSONError(w, http.StatusNotFound, "not_found", err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			writeJSONError(w, http.StatusGatewayTimeout, "timeout", err.Error())
+		case errors.Is(err, service.ErrStorageUnavailable):
+			writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+
+	body, err := json.Marshal(auth.Redact(r.Context(), order))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	etag := makeETag(body)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache") // клиент обязан перепроверять ETag при каждом запросе
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(APIError{Error: APIErrorBody{Code: code, Message: msg}})
+}
+
+// makeETag returns strong ETag built from response body
+func makeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks If-None-Match header value against current ETag using weak comparison (RFC 9110)
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"orderservice/internal/auth"
+	"orderservice/internal/model"
+	"orderservice/internal/service"
+	"orderservice/internal/web"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ordersPageData is passed to orders.gohtml
+type ordersPageData struct {
+	Query   url.Values
+	Orders  []model.Order
+	NextURL string
+	Error   string
+}
+
+// ListOrdersJSON returns a page of orders matching query parameters as JSON
+func (OH *OrderHandler) ListOrdersJSON(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	page, err := OH.Service.ListOrders(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidFilter), errors.Is(err, service.ErrInvalidCursor):
+			writeJSONError(w, http.StatusBadRequest, "bad_request", err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			writeJSONError(w, http.StatusGatewayTimeout, "timeout", err.Error())
+		case errors.Is(err, service.ErrStorageUnavailable):
+			writeJSONError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+
+	page.Orders = auth.RedactAll(r.Context(), page.Orders)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// ListOrdersPage renders HTML page with search form and a page of found orders
+func (OH *OrderHandler) ListOrdersPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := ordersPageData{Query: query}
+
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		data.Error = err.Error()
+		web.Render(w, "orders", data)
+		return
+	}
+
+	page, err := OH.Service.ListOrders(r.Context(), filter)
+	if err != nil {
+		data.Error = "Ошибка при поиске заказов: " + err.Error()
+		web.Render(w, "orders", data)
+		return
+	}
+
+	data.Orders = make([]model.Order, len(page.Orders))
+	for i := range page.Orders {
+		data.Orders[i] = *OH.pageOrder(r, &page.Orders[i])
+	}
+	if page.NextCursor != "" {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("cursor", page.NextCursor)
+		data.NextURL = r.URL.Path + "?" + next.Encode()
+	}
+	web.Render(w, "orders", data)
+}
+
+// parseOrderFilter builds model.OrderFilter from query parameters, "sort" accepts field name with optional "-" prefix for descending order
+func parseOrderFilter(q url.Values) (model.OrderFilter, error) {
+	filter := model.OrderFilter{
+		CustomerID:      strings.TrimSpace(q.Get("customer_id")),
+		TrackNumber:     strings.TrimSpace(q.Get("track_number")),
+		DeliveryService: strings.TrimSpace(q.Get("delivery_service")),
+		Provider:        strings.TrimSpace(q.Get("provider")),
+		Bank:            strings.TrimSpace(q.Get("bank")),
+		Brand:           strings.TrimSpace(q.Get("brand")),
+		Cursor:          q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("nm_id"); v != "" {
+		nmID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("некорректный nm_id: %q", v)
+		}
+		filter.NMID = uint(nmID)
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("некорректный limit: %q", v)
+		}
+	}
+	if v := q.Get("date_from"); v != "" {
+		if filter.DateFrom, _, err = parseDate(v); err != nil {
+			return filter, fmt.Errorf("некорректная date_from: %q", v)
+		}
+	}
+	if v := q.Get("date_to"); v != "" {
+		dateTo, dayOnly, err := parseDate(v)
+		if err != nil {
+			return filter, fmt.Errorf("некорректная date_to: %q", v)
+		}
+		if dayOnly { // дата без времени - включаем весь день
+			dateTo = dateTo.AddDate(0, 0, 1)
+		}
+		filter.DateTo = dateTo
+	}
+	if v := q.Get("sort"); v != "" {
+		filter.SortDesc = strings.HasPrefix(v, "-")
+		filter.SortBy = strings.TrimPrefix(v, "-")
+	}
+	return filter, nil
+}
+
+// parseDate accepts RFC3339 or YYYY-MM-DD, the second result reports whether value had no time part
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), false, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	return t, true, err
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"orderservice/internal/auth"
+	"orderservice/internal/metrics"
+	"orderservice/internal/ratelimit"
+	"orderservice/internal/web"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Recoverer turns a panic in a handler into 500: error.gohtml for HTML pages, JSON error for the API.
+// The panic is logged with stack trace; if the response was already started it is only logged
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler { //штатный способ оборвать ответ, net/http его не логирует
+				panic(rec)
+			}
+			metrics.HTTPPanics.Inc()
+			slog.ErrorContext(r.Context(), "Panic in HTTP handler", "panic", fmt.Sprint(rec), "path", r.URL.Path, "stack", string(debug.Stack()))
+			if ww.Status() == 0 {
+				writeError(ww, r, http.StatusInternalServerError, "internal", "Внутренняя ошибка сервера")
+			}
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Timeout sets deadline of the request context; it reaches the service and the repository, so a slow DB query
+// is cancelled and the handler answers 504 instead of holding the connection until the server write timeout
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RateLimit rejects requests of a client exceeding the limit of the route with 429 and Retry-After.
+// The client is the authenticated subject or the remote IP; must be used after auth middleware in a chi route group,
+// where the route pattern is already known
+func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			ok, retryAfter := limiter.Allow(ratelimit.RouteKey(r.Method, route), clientKey(r))
+			if !ok {
+				rejectRateLimited(w, r, route, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ThrottleAuthFailures answers 429 to a remote IP that has used up its limit of failed authentications(401 responses):
+// RateLimit counts requests per subject after authentication, so without it credentials could be guessed unthrottled.
+// Successful requests do not take tokens. Must be used before auth middleware in a chi route group
+func ThrottleAuthFailures(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := remoteIPKey(r)
+			if ok, retryAfter := limiter.Check("", client); !ok {
+				route := r.URL.Path
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					route = rctx.RoutePattern()
+				}
+				rejectRateLimited(w, r, route, retryAfter)
+				return
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() == http.StatusUnauthorized {
+				limiter.Allow("", client)
+			}
+		})
+	}
+}
+
+// rejectRateLimited answers 429 with Retry-After
+func rejectRateLimited(w http.ResponseWriter, r *http.Request, route string, retryAfter time.Duration) {
+	metrics.HTTPRateLimited.WithLabelValues(route).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, r, http.StatusTooManyRequests, "rate_limited", "Слишком много запросов, повторите позже")
+}
+
+// clientKey identifies the client for rate limiting
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return "subject:" + p.Subject
+	}
+	return remoteIPKey(r)
+}
+
+// remoteIPKey identifies the client by its remote IP
+func remoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// writeError answers with JSON error for API paths and with error page otherwise
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/admin/") {
+		writeJSONError(w, status, code, msg)
+		return
+	}
+	renderError(w, status, msg)
+}
+
+// renderError renders error.gohtml with status
+func renderError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	web.Render(w, "error", msg)
+}
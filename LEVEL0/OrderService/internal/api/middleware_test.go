@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	handler "orderservice/internal/api"
+	"orderservice/internal/auth"
+	"orderservice/internal/model"
+	"orderservice/internal/ratelimit"
+	"orderservice/internal/web"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRecoverer(t *testing.T) {
+	web.LoadTemplates()
+	r := chi.NewRouter()
+	r.Use(handler.Recoverer)
+	r.Get("/order/{uid}", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	r.Get("/api/v1/orders/{uid}", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/1", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "<!DOCTYPE html>") || strings.Contains(w.Body.String(), "boom") {
+		t.Errorf("expected error page, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/1", nil))
+	var body handler.APIError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != http.StatusInternalServerError || body.Error.Code != "internal" {
+		t.Errorf("expected JSON error, got %d %+v, %v", w.Code, body, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	svc := &MockOrderService{GetOrderInfoFn: func(ctx context.Context, uid string) (*model.Order, error) {
+		// медленная БД: ответ только после отмены контекста
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	h := &handler.OrderHandler{Service: svc}
+	r := chi.NewRouter()
+	r.Use(handler.Timeout(50 * time.Millisecond))
+	r.Get("/api/v1/orders/{uid}", h.GetOrderJSON)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/1", nil))
+	if w.Code != http.StatusGatewayTimeout || time.Since(start) > time.Second {
+		t.Errorf("expected 504 after deadline, got %d in %s", w.Code, time.Since(start))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	web.LoadTemplates()
+	limiter := ratelimit.New(ratelimit.Config{
+		Default: ratelimit.Limit{Rate: 0.001, Burst: 1},
+		Routes:  map[string]ratelimit.Limit{"GET /api/v1/orders/{uid}": {Rate: 0.001, Burst: 2}},
+	})
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(handler.RateLimit(limiter))
+		r.Get("/orders", func(w http.ResponseWriter, r *http.Request) {})
+		r.Get("/api/v1/orders/{uid}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	get := func(path, remoteAddr string, p *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// лимит маршрута считается по шаблону, а не по фактическому пути
+	if get("/api/v1/orders/1", "10.0.0.1:1000", nil).Code != http.StatusOK || get("/api/v1/orders/2", "10.0.0.1:1001", nil).Code != http.StatusOK {
+		t.Fatal("expected route burst to be allowed")
+	}
+	w := get("/api/v1/orders/3", "10.0.0.1:1002", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" || !strings.Contains(w.Header().Get("Content-Type"), "json") {
+		t.Errorf("expected 429 JSON with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if get("/api/v1/orders/1", "10.0.0.2:1000", nil).Code != http.StatusOK {
+		t.Errorf("expected another IP to have own bucket")
+	}
+
+	// аутентифицированный клиент ограничивается по subject, с какого бы адреса он ни пришел
+	crm := &auth.Principal{Subject: "crm", Role: auth.RoleSupport}
+	if get("/orders", "10.0.0.3:1000", crm).Code != http.StatusOK {
+		t.Fatal("expected first request to be allowed")
+	}
+	if w := get("/orders", "10.0.0.4:1000", crm); w.Code != http.StatusTooManyRequests || !strings.Contains(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expected 429 page for the same subject, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestThrottleAuthFailures(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{Default: ratelimit.Limit{Rate: 0.001, Burst: 2}})
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(handler.ThrottleAuthFailures(limiter))
+		r.Get("/api/v1/orders", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(auth.HeaderAPIKey) != "good" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+	})
+	get := func(key, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(auth.HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// успешные запросы лимит неудач не расходуют
+	for range 5 {
+		if code := get("good", "10.0.0.1:1000"); code != http.StatusOK {
+			t.Fatalf("expected authenticated requests to pass, got %d", code)
+		}
+	}
+	for range 2 {
+		if code := get("guess", "10.0.0.1:1000"); code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 within the limit, got %d", code)
+		}
+	}
+	// после исчерпания лимита адрес получает 429 даже с верным ключом, другой адрес - нет
+	if code := get("good", "10.0.0.1:1001"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after failed authentications, got %d", code)
+	}
+	if code := get("guess", "10.0.0.2:1000"); code != http.StatusUnauthorized {
+		t.Errorf("expected another IP to have own limit, got %d", code)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// APIKeys is a set of static API keys; only SHA-256 hashes of the keys are kept in memory
+type APIKeys struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+// ParseAPIKeys parses comma-separated "subject:role:key" entries, e.g. "crm:support:s3cr3t,bi:analytics:k3y"
+func ParseAPIKeys(spec string) (*APIKeys, error) {
+	keys := &APIKeys{}
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subject, rest, _ := strings.Cut(entry, ":")
+		role, key, _ := strings.Cut(rest, ":")
+		if subject == "" || key == "" {
+			return nil, fmt.Errorf("API key entry %q: expected subject:role:key", subject)
+		}
+		if !IsValidRole(role) {
+			return nil, fmt.Errorf("API key %q: unknown role %q", subject, role)
+		}
+		hash := sha256.Sum256([]byte(key))
+		if seen[hash] {
+			return nil, fmt.Errorf("API key %q: duplicate key", subject)
+		}
+		seen[hash] = true
+		keys.keys = append(keys.keys, apiKey{hash: hash, principal: Principal{Subject: subject, Role: role, Method: MethodAPIKey}})
+	}
+	return keys, nil
+}
+
+// Len returns number of configured keys
+func (K *APIKeys) Len() int {
+	return len(K.keys)
+}
+
+// Authenticate returns principal of the key; all keys are compared in constant time
+func (K *APIKeys) Authenticate(key string) (*Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+	var found *Principal
+	for i := range K.keys {
+		if subtle.ConstantTimeCompare(hash[:], K.keys[i].hash[:]) == 1 {
+			p := K.keys[i].principal
+			found = &p
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: неизвестный API-ключ", ErrInvalidCredentials)
+	}
+	return found, nil
+}
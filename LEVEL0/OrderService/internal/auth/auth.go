@@ -0,0 +1,134 @@
+// Package auth authenticates API clients by API key or JWT and decides which order data their role may see
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/logger"
+	"slices"
+	"strings"
+)
+
+// Roles of API clients
+const (
+	RoleSupport   = "support"   // поддержка: заказы с полными персональными данными
+	RoleAnalytics = "analytics" // аналитика: заказы с замаскированными Delivery и Payment
+	RoleAdmin     = "admin"     // все, что может support, а также прием заказов и разбор отклоненных сообщений
+)
+
+// Authentication methods reported in Principal.Method
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	ErrNoCredentials      = errors.New("Требуется аутентификация")
+	ErrInvalidCredentials = errors.New("Неверные учетные данные")
+	ErrForbidden          = errors.New("Недостаточно прав")
+)
+
+// Principal is an authenticated client
+type Principal struct {
+	Subject string
+	Role    string
+	Method  string
+}
+
+// SeesPII reports whether personal data of customers is shown to the principal unmasked
+func (p *Principal) SeesPII() bool {
+	return p.Role == RoleSupport || p.Role == RoleAdmin
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	return role == RoleSupport || role == RoleAnalytics || role == RoleAdmin
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns principal of the current request; false if the request is not authenticated(auth disabled)
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Authenticator checks credentials of API clients: API keys and JWT signed with one of the configured keys.
+// A nil *Authenticator means auth is disabled: every request is allowed and sees full data
+type Authenticator struct {
+	Keys *APIKeys     // может быть nil - API-ключи не принимаются
+	JWT  *JWTVerifier // может быть nil - JWT не принимаются
+}
+
+// Authenticate checks API key(if not empty), otherwise bearer token
+func (A *Authenticator) Authenticate(apiKey, bearer string) (*Principal, error) {
+	switch {
+	case apiKey != "":
+		if A.Keys == nil {
+			return nil, fmt.Errorf("%w: API-ключи не настроены", ErrInvalidCredentials)
+		}
+		return A.Keys.Authenticate(apiKey)
+	case bearer != "":
+		if A.JWT == nil {
+			return nil, fmt.Errorf("%w: JWT не настроены", ErrInvalidCredentials)
+		}
+		return A.JWT.Verify(bearer)
+	default:
+		return nil, ErrNoCredentials
+	}
+}
+
+// Authorize checks that the principal from ctx has one of the roles and writes the decision to the audit log.
+// Without authenticator every request is allowed
+func (A *Authenticator) Authorize(ctx context.Context, resource string, roles ...string) error {
+	if A == nil {
+		return nil
+	}
+	p, ok := FromContext(ctx)
+	if !ok {
+		audit(ctx, false, resource, ErrNoCredentials.Error())
+		return ErrNoCredentials
+	}
+	if !slices.Contains(roles, p.Role) {
+		audit(ctx, false, resource, "role not allowed")
+		return fmt.Errorf("%w: роль %s", ErrForbidden, p.Role)
+	}
+	audit(ctx, true, resource, "")
+	return nil
+}
+
+// AuditFailure logs failed authentication of a request to resource
+func AuditFailure(ctx context.Context, resource string, err error) {
+	slog.WarnContext(ctx, "Authentication failed", "audit", true, "resource", resource, "reason", err.Error())
+}
+
+// audit logs access decision; subject and role are already in ctx after authentication
+func audit(ctx context.Context, granted bool, resource, reason string) {
+	if granted {
+		slog.InfoContext(ctx, "Access granted", "audit", true, "resource", resource)
+		return
+	}
+	slog.WarnContext(ctx, "Access denied", "audit", true, "resource", resource, "reason", reason)
+}
+
+// Authenticated adds principal to ctx and its subject and role to log records
+func Authenticated(ctx context.Context, p *Principal) context.Context {
+	ctx = WithPrincipal(ctx, p)
+	return logger.With(ctx, logger.KeySubject, p.Subject, logger.KeyRole, p.Role, "auth_method", p.Method)
+}
+
+// BearerToken extracts token from "Authorization: Bearer <token>"
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"orderservice/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func TestAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys("crm:support:k1, bi:analytics:k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %d", keys.Len())
+	}
+	p, err := keys.Authenticate("k2")
+	if err != nil || p.Subject != "bi" || p.Role != RoleAnalytics || p.Method != MethodAPIKey {
+		t.Fatalf("unexpected principal %+v: %v", p, err)
+	}
+	if _, err := keys.Authenticate("k3"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	for _, spec := range []string{"crm:support", "crm:root:k1", "a:support:k1,b:analytics:k1", ":support:k1"} {
+		if _, err := ParseAPIKeys(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func token(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func claims(sub string, role any, exp time.Duration) jwt.MapClaims {
+	return jwt.MapClaims{"sub": sub, "role": role, "iss": "idp", "exp": time.Now().Add(exp).Unix()}
+}
+
+func TestJWTVerifier_HMAC(t *testing.T) {
+	V, err := NewJWTVerifier(JWTConfig{HMACSecret: testSecret, Issuer: "idp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte(testSecret)
+
+	p, err := V.Verify(token(t, jwt.SigningMethodHS256, secret, "", claims("alice", []any{"analytics", "support"}, time.Minute)))
+	if err != nil || p.Subject != "alice" || p.Role != RoleSupport || p.Method != MethodJWT {
+		t.Fatalf("unexpected principal %+v: %v", p, err)
+	}
+
+	invalid := map[string]string{
+		"expired":      token(t, jwt.SigningMethodHS256, secret, "", claims("alice", "support", -time.Minute)),
+		"wrong secret": token(t, jwt.SigningMethodHS256, []byte("other"), "", claims("alice", "support", time.Minute)),
+		"unknown role": token(t, jwt.SigningMethodHS256, secret, "", claims("alice", "root", time.Minute)),
+		"no subject":   token(t, jwt.SigningMethodHS256, secret, "", claims("", "support", time.Minute)),
+		"wrong issuer": token(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice", "role": "support", "iss": "evil", "exp": time.Now().Add(time.Minute).Unix()}),
+		"no exp":       token(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice", "role": "support", "iss": "idp"}),
+	}
+	for name, raw := range invalid {
+		if _, err := V.Verify(raw); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTVerifier_PublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "idp-2024.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	V, err := NewJWTVerifier(JWTConfig{PublicKeyFiles: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kid := range []string{"idp-2024", ""} {
+		p, err := V.Verify(token(t, jwt.SigningMethodES256, key, kid, claims("bi", "analytics", time.Minute)))
+		if err != nil || p.Role != RoleAnalytics {
+			t.Errorf("kid %q: unexpected principal %+v: %v", kid, p, err)
+		}
+	}
+	if _, err := V.Verify(token(t, jwt.SigningMethodES256, key, "idp-2023", claims("bi", "analytics", time.Minute))); err == nil {
+		t.Error("expected error for unknown kid")
+	}
+	// HS256 не принимается, если секрет не настроен: иначе открытый ключ можно было бы использовать как секрет
+	if _, err := V.Verify(token(t, jwt.SigningMethodHS256, der, "", claims("bi", "admin", time.Minute))); err == nil {
+		t.Error("expected error for HS256 token")
+	}
+
+	if _, err := NewJWTVerifier(JWTConfig{}); err == nil {
+		t.Error("expected error without keys")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys, err := ParseAPIKeys("crm:support:sk,bi:analytics:ak,ops:admin:adk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewJWTVerifier(JWTConfig{HMACSecret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	A := &Authenticator{Keys: keys, JWT: verifier}
+	var seen *Principal
+	h := A.Middleware(A.Require(RoleSupport, RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	})))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantHTTP int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"unknown key", HeaderAPIKey, "nope", http.StatusUnauthorized},
+		{"support key", HeaderAPIKey, "sk", http.StatusOK},
+		{"analytics key", HeaderAPIKey, "ak", http.StatusForbidden},
+		{"admin jwt", "Authorization", "Bearer " + token(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims("ops", "admin", time.Minute)), http.StatusOK},
+		{"basic auth", "Authorization", "Basic b3BzOmFkaw==", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantHTTP {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantHTTP)
+			}
+			if tt.wantHTTP == http.StatusOK && seen == nil {
+				t.Fatal("principal is not passed to handler")
+			}
+			if tt.wantHTTP == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+
+	// без аутентификатора запросы проходят как есть
+	var disabled *Authenticator
+	w := httptest.NewRecorder()
+	disabled.Middleware(disabled.Require(RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("disabled auth: status = %d", w.Code)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	order := &model.Order{
+		OrderUID: "u1",
+		Delivery: model.Delivery{Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com"},
+		Payment:  model.Payment{Transaction: "b563feb7b2b84b6test", RequestID: "", Currency: "USD", Provider: "wbpay", Amount: 1817, Bank: "alpha"},
+	}
+	analytics := WithPrincipal(context.Background(), &Principal{Subject: "bi", Role: RoleAnalytics})
+	masked := Redact(analytics, order)
+	want := model.Delivery{Name: "T**********", Phone: "+9*******00", Zip: "*******", City: "Kiryat Mozkin", Address: "***************", Region: "Kraiot", Email: "t***@gmail.com"}
+	if masked.Delivery != want {
+		t.Errorf("delivery = %+v, want %+v", masked.Delivery, want)
+	}
+	if masked.Payment.Transaction != "***************test" || masked.Payment.Amount != 1817 || masked.Payment.Bank != "alpha" {
+		t.Errorf("unexpected payment %+v", masked.Payment)
+	}
+	if order.Delivery.Name != "Test Testov" {
+		t.Fatal("original order is modified")
+	}
+
+	support := WithPrincipal(context.Background(), &Principal{Subject: "crm", Role: RoleSupport})
+	if Redact(support, order) != order || Redact(context.Background(), order) != order {
+		t.Error("order must not be masked for support and without auth")
+	}
+	if orders := RedactAll(analytics, []model.Order{*order}); orders[0].Delivery.Phone != want.Phone {
+		t.Errorf("RedactAll: unexpected delivery %+v", orders[0].Delivery)
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig describes how bearer tokens are verified
+type JWTConfig struct {
+	HMACSecret     string   // общий секрет для HS256/HS384/HS512
+	PublicKeyFiles []string // PEM с открытыми ключами RSA, ECDSA или Ed25519; имя файла без расширения - kid ключа
+	Issuer         string   // пусто - iss не проверяется
+	Audience       string   // пусто - aud не проверяется
+	RoleClaim      string   // claim с ролью: строка или массив строк; по умолчанию "role"
+	Leeway         time.Duration
+}
+
+// JWTVerifier checks signature, expiration, issuer and audience of JWT and maps its claims to Principal
+type JWTVerifier struct {
+	parser    *jwt.Parser
+	hmac      []byte
+	keys      map[string]crypto.PublicKey
+	roleClaim string
+}
+
+// rolePriority picks the most privileged role when a token has several
+var rolePriority = []string{RoleAdmin, RoleSupport, RoleAnalytics}
+
+// NewJWTVerifier loads verification keys; at least one key is required
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	V := &JWTVerifier{keys: make(map[string]crypto.PublicKey), roleClaim: cfg.RoleClaim}
+	if V.roleClaim == "" {
+		V.roleClaim = "role"
+	}
+	methods := []string{}
+	if cfg.HMACSecret != "" {
+		V.hmac = []byte(cfg.HMACSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	for _, path := range cfg.PublicKeyFiles {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		V.keys[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = key
+		switch key.(type) {
+		case *rsa.PublicKey:
+			methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512")
+		case *ecdsa.PublicKey:
+			methods = append(methods, "ES256", "ES384", "ES512")
+		case ed25519.PublicKey:
+			methods = append(methods, "EdDSA")
+		}
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no JWT verification keys configured")
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired(), jwt.WithLeeway(cfg.Leeway)}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	V.parser = jwt.NewParser(opts...)
+	return V, nil
+}
+
+// loadPublicKey reads PKIX public key from PEM file
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+}
+
+// Verify returns principal of a valid token: subject from "sub", role from the role claim
+func (V *JWTVerifier) Verify(raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := V.parser.ParseWithClaims(raw, claims, V.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: в токене нет sub", ErrInvalidCredentials)
+	}
+	role := V.role(claims[V.roleClaim])
+	if role == "" {
+		return nil, fmt.Errorf("%w: в токене нет известной роли", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: subject, Role: role, Method: MethodJWT}, nil
+}
+
+// keyFunc selects verification key by token algorithm and kid; without kid every key of the algorithm family is tried
+func (V *JWTVerifier) keyFunc(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return V.hmac, nil
+	}
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key, ok := V.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	}
+	var set jwt.VerificationKeySet
+	for _, key := range V.keys {
+		set.Keys = append(set.Keys, key)
+	}
+	return set, nil
+}
+
+// role returns known role from claim value(string or list of strings); the most privileged one if there are several
+func (V *JWTVerifier) role(claim any) string {
+	var roles []string
+	switch v := claim.(type) {
+	case string:
+		roles = []string{v}
+	case []any:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+	for _, candidate := range rolePriority {
+		if slices.Contains(roles, candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
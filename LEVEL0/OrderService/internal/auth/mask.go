@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"orderservice/internal/model"
+)
+
+// Redact returns order as the principal of ctx may see it: for roles without access to personal data
+// a copy with fields of the PII policy masked is returned, otherwise the order itself.
+// Without principal(auth disabled) the order is returned as is
+func Redact(ctx context.Context, order *model.Order) *model.Order {
+	if p, ok := FromContext(ctx); !ok || p.SeesPII() {
+		return order
+	}
+	masked := order.Masked()
+	return &masked
+}
+
+// RedactAll is Redact for a list of orders; the slice is copied only if orders are masked
+func RedactAll(ctx context.Context, orders []model.Order) []model.Order {
+	if p, ok := FromContext(ctx); !ok || p.SeesPII() {
+		return orders
+	}
+	masked := make([]model.Order, len(orders))
+	for i := range orders {
+		masked[i] = orders[i].Masked()
+	}
+	return masked
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// HeaderAPIKey carries API key; JWT is passed in "Authorization: Bearer <token>"
+const HeaderAPIKey = "X-API-Key"
+
+// Middleware authenticates the request; requests without valid credentials get 401.
+// With nil authenticator requests pass through unauthenticated
+func (A *Authenticator) Middleware(next http.Handler) http.Handler {
+	if A == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := A.Authenticate(r.Header.Get(HeaderAPIKey), BearerToken(r.Header.Get("Authorization")))
+		if err != nil {
+			AuditFailure(r.Context(), r.Method+" "+r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orderservice"`)
+			writeError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(Authenticated(r.Context(), p)))
+	})
+}
+
+// Require allows the request only for the listed roles; must be used after Middleware
+func (A *Authenticator) Require(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if A == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := A.Authorize(r.Context(), r.Method+" "+r.URL.Path, roles...); err != nil {
+				status, code := http.StatusForbidden, "forbidden"
+				if errors.Is(err, ErrNoCredentials) {
+					status, code = http.StatusUnauthorized, "unauthorized"
+				}
+				writeError(w, status, code, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeError writes error in the format of JSON API
+func writeError(w http.ResponseWriter, status int, code string, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": err.Error()}})
+}
@@ -1,34 +1,289 @@
 package cache
 
 import (
-	"context"
-	"log"
+	"fmt"
 	"orderservice/internal/model"
-	"orderservice/internal/repository"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// OrderMap provides access to cache-map, contains embedded mutex features
-type OrderMap struct {
-	CacheMap     map[string]model.Order
-	Repo         repository.OrderRepository
-	sync.RWMutex //встраиваем методы мютекса для защиты
+// Eviction policies supported by New
+const (
+	PolicyLRU = "lru"
+	PolicyLFU = "lfu"
+)
+
+// OrderCache is a bounded in-memory storage of orders used by service layer
+type OrderCache interface {
+	Get(uid string) (model.Order, bool)
+	// Peek returns cached order without counting a hit or miss and without changing eviction order
+	Peek(uid string) (model.Order, bool)
+	Set(order model.Order)
+	// Warm adds order as the coldest entry if it is absent and fits into limits without evicting anything;
+	// returns false when cache is full. Used by warm-up, so it never replaces orders stored by live traffic
+	Warm(order model.Order) bool
+	// Snapshot returns unexpired orders, the most valuable for the eviction policy first
+	Snapshot() []model.Order
+	Delete(uid string)
+	// Clear removes all entries; counters are kept
+	Clear()
+	Len() int
+	Stats() Stats
+}
+
+// Config describes cache limits; zero MaxEntries/MaxBytes/TTL mean "no limit"
+type Config struct {
+	Policy     string
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+}
+
+// Stats contains cache counters collected since cache creation
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Bytes       int64
+}
+
+// policy decides which entry is evicted when cache exceeds its limits
+type policy interface {
+	add(e *entry)
+	addCold(e *entry)  //добавить как первого кандидата на вытеснение
+	ordered() []*entry //от самого ценного к первому кандидату на вытеснение
+	touch(e *entry)
+	remove(e *entry)
+	victim() *entry
+	evicted(e *entry) //victim вытесняется из-за лимитов, вызывается перед remove
+}
+
+type entry struct {
+	order     model.Order
+	size      int64
+	expiresAt time.Time
+
+	elem any    //служебные данные политики вытеснения (элемент списка LRU)
+	freq uint64 //количество обращений - для LFU
+	prio uint64 //приоритет LFU: freq плюс возраст кеша на момент последнего обращения
+	tick uint64 //время последнего обращения в "тиках" кеша - для LFU при равном приоритете
+	idx  int    //позиция в куче LFU
+}
+
+type boundedCache struct {
+	mu         sync.Mutex //обычный мютекс: даже чтение меняет порядок вытеснения
+	items      map[string]*entry
+	policy     policy
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	bytes      int64
+	tick       uint64
+	now        func() time.Time
+
+	hits, misses, evictions, expirations atomic.Uint64
+}
+
+// New returns an empty cache with the given eviction policy and limits
+func New(cfg Config) (OrderCache, error) {
+	if cfg.MaxEntries < 0 || cfg.MaxBytes < 0 || cfg.TTL < 0 {
+		return nil, fmt.Errorf("cache limits cannot be negative: %+v", cfg)
+	}
+	var p policy
+	switch cfg.Policy {
+	case PolicyLRU, "":
+		p = newLRU()
+	case PolicyLFU:
+		p = newLFU()
+	default:
+		return nil, fmt.Errorf("unknown cache eviction policy %q", cfg.Policy)
+	}
+	return &boundedCache{
+		items:      make(map[string]*entry),
+		policy:     p,
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+		ttl:        cfg.TTL,
+		now:        time.Now,
+	}, nil
+}
+
+// Get returns cached order by its uid, expired entries are removed and reported as a miss
+func (c *boundedCache) Get(uid string) (model.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[uid]
+	if !ok {
+		c.misses.Add(1)
+		return model.Order{}, false
+	}
+	if c.expired(e) {
+		c.removeEntry(e)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return model.Order{}, false
+	}
+	c.touch(e)
+	c.hits.Add(1)
+	return e.order, true
+}
+
+// Peek implements OrderCache
+func (c *boundedCache) Peek(uid string) (model.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[uid]
+	if !ok || c.expired(e) {
+		return model.Order{}, false
+	}
+	return e.order, true
+}
+
+// Set adds or replaces order in cache and evicts entries exceeding the limits; an order larger than the whole memory budget is not cached
+// and its previous version is removed
+func (c *boundedCache) Set(order model.Order) {
+	size := estimateSize(&order)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxBytes > 0 && size > c.maxBytes {
+		//прежняя версия заказа устарела, а новую кешировать нельзя
+		if e, ok := c.items[order.OrderUID]; ok {
+			c.removeEntry(e)
+		}
+		return
+	}
+
+	if e, ok := c.items[order.OrderUID]; ok {
+		c.bytes += size - e.size
+		e.order, e.size = order, size
+		e.expiresAt = c.expiry()
+		//замена значения - не обращение: иначе обновления заказа выглядели бы для LFU как чтения
+	} else {
+		e = &entry{order: order, size: size, expiresAt: c.expiry(), freq: 1}
+		c.tick++
+		e.tick = c.tick
+		c.items[order.OrderUID] = e
+		c.bytes += size
+		c.policy.add(e)
+	}
+
+	for c.overLimit() {
+		victim := c.policy.victim()
+		if victim == nil {
+			break
+		}
+		c.policy.evicted(victim)
+		c.removeEntry(victim)
+		if c.expired(victim) {
+			c.expirations.Add(1)
+		} else {
+			c.evictions.Add(1)
+		}
+	}
 }
 
-// CreateAndWarmUpOrderCache returns a new map with warmed up cache, access to DB and embedded mutex
-func CreateAndWarmUpOrderCache(repo repository.OrderRepository) (*OrderMap, error) {
-	simpleMap := make(map[string]model.Order)
-	orderMap := OrderMap{Repo: repo}
-	orders, err := orderMap.Repo.GetAllOrders(context.Background())
-	if err != nil {
-		log.Printf("Failed to read orders from DB to warm up cahce: %v", err)
-		return nil, err
+// Warm implements OrderCache
+func (c *boundedCache) Warm(order model.Order) bool {
+	size := estimateSize(&order)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[order.OrderUID]; ok {
+		return true
+	}
+	if (c.maxEntries > 0 && len(c.items) >= c.maxEntries) || (c.maxBytes > 0 && c.bytes+size > c.maxBytes) {
+		return false
 	}
+	e := &entry{order: order, size: size, expiresAt: c.expiry()}
+	c.items[order.OrderUID] = e
+	c.bytes += size
+	c.policy.addCold(e)
+	return true
+}
 
-	for _, v := range orders {
-		simpleMap[v.OrderUID] = v
+// Snapshot implements OrderCache
+func (c *boundedCache) Snapshot() []model.Order {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := c.policy.ordered()
+	orders := make([]model.Order, 0, len(entries))
+	for _, e := range entries {
+		if !c.expired(e) {
+			orders = append(orders, e.order)
+		}
 	}
-	orderMap.CacheMap = simpleMap
-	log.Println("Cache successfully loaded!")
-	return &orderMap, nil
+	return orders
+}
+
+// Delete removes order from cache if it is present
+func (c *boundedCache) Delete(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[uid]; ok {
+		c.removeEntry(e)
+	}
+}
+
+// Clear implements OrderCache
+func (c *boundedCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.items {
+		c.removeEntry(e)
+	}
+}
+
+// Len returns the number of cached entries including not yet removed expired ones
+func (c *boundedCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Stats returns current counters
+func (c *boundedCache) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes := len(c.items), c.bytes
+	c.mu.Unlock()
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+		Entries:     entries,
+		Bytes:       bytes,
+	}
+}
+
+func (c *boundedCache) touch(e *entry) {
+	c.tick++
+	e.tick = c.tick
+	e.freq++
+	c.policy.touch(e)
+}
+
+func (c *boundedCache) removeEntry(e *entry) {
+	c.policy.remove(e)
+	delete(c.items, e.order.OrderUID)
+	c.bytes -= e.size
+}
+
+func (c *boundedCache) overLimit() bool {
+	return (c.maxEntries > 0 && len(c.items) > c.maxEntries) ||
+		(c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+func (c *boundedCache) expiry() time.Time {
+	if c.ttl == 0 {
+		return time.Time{}
+	}
+	return c.now().Add(c.ttl)
+}
+
+func (c *boundedCache) expired(e *entry) bool {
+	return !e.expiresAt.IsZero() && c.now().After(e.expiresAt)
 }
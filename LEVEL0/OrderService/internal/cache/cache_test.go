@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"orderservice/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCache(t *testing.T, cfg Config) *boundedCache {
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return c.(*boundedCache)
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLRU, MaxEntries: 2})
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	c.Get("a")
+	c.Set(model.Order{OrderUID: "c"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	for _, uid := range []string{"a", "c"} {
+		if _, ok := c.Get(uid); !ok {
+			t.Fatalf("expected %s to stay in cache", uid)
+		}
+	}
+	if st := c.Stats(); st.Evictions != 1 || st.Entries != 2 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestLFU_EvictsLeastFrequentlyUsed(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLFU, MaxEntries: 2})
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	c.Set(model.Order{OrderUID: "c"}) // c имеет наименьшую частоту и вытесняется сразу
+
+	if _, ok := c.Get("c"); ok {
+		t.Fatalf("expected c to be evicted")
+	}
+	for _, uid := range []string{"a", "b"} {
+		if _, ok := c.Get(uid); !ok {
+			t.Fatalf("expected frequently used %s to stay in cache", uid)
+		}
+	}
+}
+
+func TestLFU_AdmitsNewHotKey(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLFU, MaxEntries: 2})
+	for _, uid := range []string{"old1", "old2"} {
+		c.Set(model.Order{OrderUID: uid})
+		for range 10 {
+			c.Get(uid)
+		}
+	}
+	// ключи, популярные когда-то, больше не читаются, а новый запрашивается постоянно: каждый промах кладет его в кеш
+	misses := 0
+	for range 50 {
+		if _, ok := c.Get("hot"); ok {
+			break
+		}
+		misses++
+		c.Set(model.Order{OrderUID: "hot"})
+	}
+	if misses == 50 {
+		t.Fatalf("new hot key is never admitted to saturated cache")
+	}
+	for range 5 {
+		c.Get("hot")
+		c.Set(model.Order{OrderUID: "cold"}) // однократные ключи не вытесняют его
+	}
+	if _, ok := c.Peek("hot"); !ok {
+		t.Errorf("expected admitted hot key to stay in cache")
+	}
+}
+
+func TestLFU_SetIsNotRead(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLFU, MaxEntries: 2})
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	c.Get("b")
+	for range 5 {
+		c.Set(model.Order{OrderUID: "a"}) // обновления заказа не делают его популярным
+	}
+	c.Set(model.Order{OrderUID: "c"})
+	if _, ok := c.Peek("a"); ok {
+		t.Errorf("expected updated but unread a to be evicted")
+	}
+	if _, ok := c.Peek("b"); !ok {
+		t.Errorf("expected read b to stay in cache")
+	}
+}
+
+func TestLFU_DeleteDoesNotAge(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLFU, MaxEntries: 2, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	for range 10 {
+		c.Get("a")
+		c.Get("b")
+	}
+	c.Delete("a")
+	now = now.Add(2 * time.Minute)
+	c.Get("b") // истек
+	c.Clear()
+	if age := c.policy.(*lfu).age; age != 0 {
+		t.Fatalf("invalidation and expiry must not age the cache, got age %d", age)
+	}
+
+	c.Set(model.Order{OrderUID: "c"})
+	c.Set(model.Order{OrderUID: "d"})
+	c.Set(model.Order{OrderUID: "e"})
+	if age := c.policy.(*lfu).age; age != 1 {
+		t.Fatalf("expected eviction to raise age to priority of the victim, got %d", age)
+	}
+}
+
+func TestTTL_Expiration(t *testing.T) {
+	c := newCache(t, Config{MaxEntries: 10, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.Set(model.Order{OrderUID: "a"})
+
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected a to expire")
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 1 || st.Expirations != 1 || st.Entries != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	small := model.Order{OrderUID: "a"}
+	budget := estimateSize(&small) * 2
+	c := newCache(t, Config{MaxBytes: budget})
+
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	c.Set(model.Order{OrderUID: "c"})
+	if st := c.Stats(); st.Entries != 2 || st.Bytes > budget {
+		t.Fatalf("memory budget exceeded: %+v", st)
+	}
+
+	c.Set(model.Order{OrderUID: "huge", Entry: strings.Repeat("x", int(budget))})
+	if _, ok := c.Get("huge"); ok {
+		t.Fatalf("order larger than the whole budget should not be cached")
+	}
+
+	c.Set(model.Order{OrderUID: "a", Entry: strings.Repeat("x", int(budget))})
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("previous version of an order too large to cache should be removed")
+	}
+}
+
+func TestSetReplacesAndDelete(t *testing.T) {
+	c := newCache(t, Config{MaxEntries: 10})
+	c.Set(model.Order{OrderUID: "a", TrackNumber: "old"})
+	c.Set(model.Order{OrderUID: "a", TrackNumber: "new"})
+	if o, _ := c.Get("a"); o.TrackNumber != "new" || c.Len() != 1 {
+		t.Fatalf("expected order to be replaced, got %+v", o)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Stats().Bytes != 0 {
+		t.Fatalf("expected cache to be empty after Delete")
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	if _, err := New(Config{Policy: "fifo"}); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+	if _, err := New(Config{MaxEntries: -1}); err == nil {
+		t.Fatalf("expected error for negative limit")
+	}
+}
+
+func TestWarm(t *testing.T) {
+	for _, policy := range []string{PolicyLRU, PolicyLFU} {
+		c := newCache(t, Config{Policy: policy, MaxEntries: 3})
+		c.Set(model.Order{OrderUID: "live", CustomerID: "fresh"})
+
+		if !c.Warm(model.Order{OrderUID: "live", CustomerID: "stale"}) || !c.Warm(model.Order{OrderUID: "a"}) || !c.Warm(model.Order{OrderUID: "b"}) {
+			t.Fatalf("%s: expected orders to fit", policy)
+		}
+		if c.Warm(model.Order{OrderUID: "c"}) {
+			t.Fatalf("%s: expected full cache to refuse warm-up", policy)
+		}
+		if order, _ := c.Get("live"); order.CustomerID != "fresh" {
+			t.Errorf("%s: warm-up must not overwrite cached order", policy)
+		}
+		// прогретые заказы вытесняются раньше прочитанных
+		c.Set(model.Order{OrderUID: "d"})
+		if _, ok := c.Get("live"); !ok {
+			t.Errorf("%s: expected live order to stay in cache", policy)
+		}
+		if c.Len() != 3 {
+			t.Errorf("%s: expected 3 entries, got %d", policy, c.Len())
+		}
+	}
+}
+
+func TestSnapshot_LRUOrder(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLRU})
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+	c.Warm(model.Order{OrderUID: "cold"})
+	c.Get("a")
+
+	var uids []string
+	for _, order := range c.Snapshot() {
+		uids = append(uids, order.OrderUID)
+	}
+	if got := strings.Join(uids, ","); got != "a,b,cold" {
+		t.Errorf("expected the most recently used first, got %s", got)
+	}
+}
+
+func TestPeekAndClear(t *testing.T) {
+	c := newCache(t, Config{Policy: PolicyLRU, MaxEntries: 2})
+	c.Set(model.Order{OrderUID: "a"})
+	c.Set(model.Order{OrderUID: "b"})
+
+	if _, ok := c.Peek("a"); !ok {
+		t.Fatalf("expected order to be found")
+	}
+	// Peek не продлевает жизнь записи и не учитывается в статистике
+	c.Set(model.Order{OrderUID: "c"})
+	if _, ok := c.Peek("a"); ok {
+		t.Errorf("expected peeked order to be evicted as least recently used")
+	}
+	if hits := c.Stats().Hits; hits != 0 {
+		t.Errorf("expected Peek not to count hits, got %d", hits)
+	}
+
+	c.Clear()
+	if c.Len() != 0 || c.Stats().Bytes != 0 {
+		t.Errorf("expected empty cache after Clear, got %d entries", c.Len())
+	}
+}
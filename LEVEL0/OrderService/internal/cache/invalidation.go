@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ChangesChannel is Postgres channel notified by the orders trigger(migration 0004)
+const ChangesChannel = "order_changes"
+
+// Operations reported in OrderChange.Op
+const (
+	OpInsert   = "INSERT"
+	OpUpdate   = "UPDATE"
+	OpDelete   = "DELETE"
+	OpTruncate = "TRUNCATE"
+)
+
+// OrderChange is a payload of a notification about changed order
+type OrderChange struct {
+	Op       string `json:"op"`
+	OrderUID string `json:"order_uid"`
+	Version  uint   `json:"version"`
+}
+
+// Invalidator keeps cache coherent with changes made by other service instances: a cached order is refreshed from DB
+// if the change has a newer version, deleted orders are evicted. Orders that are not cached are ignored
+type Invalidator struct {
+	Repo  repository.OrderRepository
+	Cache OrderCache
+}
+
+// HandleNotification applies JSON-encoded OrderChange to the cache
+func (I *Invalidator) HandleNotification(ctx context.Context, payload string) {
+	var change OrderChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		slog.WarnContext(ctx, "Invalid order change notification", "payload", payload, logger.Err(err))
+		return
+	}
+	ctx = logger.With(ctx, logger.KeyOrderUID, change.OrderUID, "op", change.Op)
+	action := I.apply(ctx, change)
+	metrics.CacheInvalidations.WithLabelValues(action).Inc()
+	slog.DebugContext(ctx, "Order change handled", "action", action, "version", change.Version)
+}
+
+func (I *Invalidator) apply(ctx context.Context, change OrderChange) string {
+	switch change.Op {
+	case OpTruncate:
+		I.Cache.Clear()
+		return "clear"
+	case OpDelete:
+		I.Cache.Delete(change.OrderUID)
+		return "evict"
+	}
+
+	cached, ok := I.Cache.Peek(change.OrderUID)
+	//версия растет с каждым событием, поэтому в кеше уже это или более новое состояние(например, записанное этим же экземпляром)
+	if !ok || cached.Version >= change.Version {
+		return "skip"
+	}
+	order, err := I.Repo.GetOrderByUID(ctx, change.OrderUID)
+	if err != nil {
+		I.Cache.Delete(change.OrderUID)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "Failed to refresh cached order, evicted", logger.Err(err))
+		}
+		return "evict"
+	}
+	//чтение могло уйти на отстающую реплику: старую версию не кешируем, следующий запрос прочитает заказ заново
+	if order.Version < change.Version {
+		I.Cache.Delete(change.OrderUID)
+		return "evict"
+	}
+	I.Cache.Set(*order)
+	return "refresh"
+}
+
+// Reconcile re-reads every cached order from the primary and evicts the ones changed or deleted in DB.
+// Called after orders are loaded from a snapshot, which misses changes made while the service was stopped;
+// the listener must already be subscribed, so changes made during reconciliation are notified
+func (I *Invalidator) Reconcile(ctx context.Context) error {
+	evicted := 0
+	orders := I.Cache.Snapshot()
+	for i := range orders {
+		cached := &orders[i]
+		order, err := I.Repo.GetOrderByUID(repository.WithPrimary(ctx), cached.OrderUID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return fmt.Errorf("cache reconciliation stopped after %d of %d orders: %w", i, len(orders), err)
+		case order.Version <= cached.Version:
+			continue
+		}
+		//новое состояние прочитается при следующем запросе
+		I.Cache.Delete(cached.OrderUID)
+		metrics.CacheInvalidations.WithLabelValues("evict").Inc()
+		evicted++
+	}
+	slog.InfoContext(ctx, "Cache reconciled with DB", "orders", len(orders), "evicted", evicted)
+	return nil
+}
+
+// Resync is called when notifications may have been missed(listener reconnected): every entry may be stale, so cache is cleared
+func (I *Invalidator) Resync(ctx context.Context) {
+	I.Cache.Clear()
+	metrics.CacheInvalidations.WithLabelValues("clear").Inc()
+	slog.WarnContext(ctx, "Order change notifications may have been missed, cache cleared")
+}
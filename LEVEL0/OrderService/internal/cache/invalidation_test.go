@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"orderservice/internal/model"
+	"testing"
+)
+
+func notify(t *testing.T, inv *Invalidator, change OrderChange) {
+	t.Helper()
+	payload, err := json.Marshal(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.HandleNotification(context.Background(), string(payload))
+}
+
+func TestInvalidator_HandleNotification(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t, 3)
+	c := newCache(t, Config{})
+	inv := &Invalidator{Repo: repo, Cache: c}
+
+	// заказ, записанный другим экземпляром
+	if _, err := repo.ApplyOrderEvent(ctx, &model.OrderEvent{EventID: "e1", EventType: model.EventOrderUpdated, OrderUID: "order-0", Version: 2, Order: &model.Order{OrderUID: "order-0", TrackNumber: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Set(model.Order{OrderUID: "order-0", TrackNumber: "old", Version: 1})
+	c.Set(model.Order{OrderUID: "order-1", TrackNumber: "current", Version: 2})
+	c.Set(model.Order{OrderUID: "gone", Version: 1})
+
+	notify(t, inv, OrderChange{Op: OpUpdate, OrderUID: "order-0", Version: 2})
+	if o, _ := c.Peek("order-0"); o.TrackNumber != "new" {
+		t.Errorf("expected cached order to be refreshed, got %+v", o)
+	}
+
+	notify(t, inv, OrderChange{Op: OpUpdate, OrderUID: "order-1", Version: 2})
+	if o, _ := c.Peek("order-1"); o.TrackNumber != "current" {
+		t.Errorf("expected up-to-date order to be kept, got %+v", o)
+	}
+
+	notify(t, inv, OrderChange{Op: OpInsert, OrderUID: "order-2", Version: 0})
+	if _, ok := c.Peek("order-2"); ok {
+		t.Errorf("expected not cached order to be ignored")
+	}
+
+	// реплика еще не получила изменение
+	c.Set(model.Order{OrderUID: "order-2", Version: 1})
+	notify(t, inv, OrderChange{Op: OpUpdate, OrderUID: "order-2", Version: 5})
+	if _, ok := c.Peek("order-2"); ok {
+		t.Errorf("expected order older than notification to be evicted")
+	}
+
+	// заказа уже нет в БД
+	notify(t, inv, OrderChange{Op: OpUpdate, OrderUID: "gone", Version: 2})
+	if _, ok := c.Peek("gone"); ok {
+		t.Errorf("expected order missing in DB to be evicted")
+	}
+
+	notify(t, inv, OrderChange{Op: OpDelete, OrderUID: "order-1"})
+	if _, ok := c.Peek("order-1"); ok {
+		t.Errorf("expected deleted order to be evicted")
+	}
+
+	inv.HandleNotification(ctx, "not json")
+	if c.Len() != 1 {
+		t.Errorf("expected invalid payload to be ignored, got %d entries", c.Len())
+	}
+
+	notify(t, inv, OrderChange{Op: OpTruncate})
+	if c.Len() != 0 {
+		t.Errorf("expected cache to be cleared on truncate, got %d entries", c.Len())
+	}
+}
+
+func TestInvalidator_Resync(t *testing.T) {
+	c := newCache(t, Config{})
+	c.Set(model.Order{OrderUID: "a"})
+	(&Invalidator{Repo: testRepo(t, 0), Cache: c}).Resync(context.Background())
+	if c.Len() != 0 {
+		t.Errorf("expected cache to be cleared after resync")
+	}
+}
+
+func TestInvalidator_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t, 2)
+	c := newCache(t, Config{})
+	inv := &Invalidator{Repo: repo, Cache: c}
+
+	// снимок сохранен до изменения order-0 и удаления заказа
+	c.Warm(model.Order{OrderUID: "order-0", TrackNumber: "old"})
+	c.Warm(model.Order{OrderUID: "order-1"})
+	c.Warm(model.Order{OrderUID: "gone"})
+	if _, err := repo.ApplyOrderEvent(ctx, &model.OrderEvent{EventID: "e1", EventType: model.EventOrderUpdated, OrderUID: "order-0", Version: 2, Order: &model.Order{OrderUID: "order-0", TrackNumber: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inv.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Peek("order-1"); !ok || c.Len() != 1 {
+		t.Errorf("expected only unchanged order-1 to be kept, got %d entries", c.Len())
+	}
+}
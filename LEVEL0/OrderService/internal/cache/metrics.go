@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"orderservice/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RegisterMetrics exposes hit/miss counters and size of c in Prometheus; must be called once per process
+func RegisterMetrics(c OrderCache) {
+	counter := func(name, help string, value func(Stats) uint64) {
+		promauto.NewCounterFunc(prometheus.CounterOpts{Namespace: metrics.Namespace, Subsystem: "cache", Name: name, Help: help},
+			func() float64 { return float64(value(c.Stats())) })
+	}
+	gauge := func(name, help string, value func(Stats) float64) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Namespace: metrics.Namespace, Subsystem: "cache", Name: name, Help: help},
+			func() float64 { return value(c.Stats()) })
+	}
+	counter("hits_total", "Cache hits.", func(s Stats) uint64 { return s.Hits })
+	counter("misses_total", "Cache misses.", func(s Stats) uint64 { return s.Misses })
+	counter("evictions_total", "Entries evicted because of size limits.", func(s Stats) uint64 { return s.Evictions })
+	counter("expirations_total", "Entries removed because of TTL.", func(s Stats) uint64 { return s.Expirations })
+	gauge("entries", "Orders currently in cache.", func(s Stats) float64 { return float64(s.Entries) })
+	gauge("size_bytes", "Approximate memory used by cached orders.", func(s Stats) float64 { return float64(s.Bytes) })
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"cmp"
+	"container/heap"
+	"container/list"
+	"orderservice/internal/model"
+	"slices"
+	"unsafe"
+)
+
+// lru evicts the least recently used entry: the back of the list
+type lru struct {
+	ll *list.List
+}
+
+func newLRU() *lru {
+	return &lru{ll: list.New()}
+}
+
+func (p *lru) add(e *entry) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lru) addCold(e *entry) {
+	e.elem = p.ll.PushBack(e)
+}
+
+func (p *lru) ordered() []*entry {
+	entries := make([]*entry, 0, p.ll.Len())
+	for el := p.ll.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*entry))
+	}
+	return entries
+}
+
+func (p *lru) touch(e *entry) {
+	p.ll.MoveToFront(e.elem.(*list.Element))
+}
+
+func (p *lru) remove(e *entry) {
+	p.ll.Remove(e.elem.(*list.Element))
+}
+
+func (p *lru) evicted(*entry) {}
+
+func (p *lru) victim() *entry {
+	back := p.ll.Back()
+	if back == nil {
+		return nil
+	}
+	return back.Value.(*entry)
+}
+
+// lfu evicts the entry with the lowest priority, the least recently used one among equals. It is LFU with dynamic aging:
+// priority is the number of reads plus the cache age, which is the priority of the last evicted entry. Without aging
+// entries that were hot long ago stay forever, and in a saturated cache a new key is evicted right after insertion
+// however often it is requested; with aging every such eviction raises the priority the next new entry starts with
+type lfu struct {
+	h   lfuHeap
+	age uint64
+}
+
+func newLFU() *lfu {
+	return &lfu{}
+}
+
+func (p *lfu) add(e *entry) {
+	e.prio = p.age + e.freq
+	heap.Push(&p.h, e)
+}
+
+// addCold adds entry with zero frequency: it is evicted before any entry that has been read
+func (p *lfu) addCold(e *entry) {
+	e.freq = 0
+	e.prio = p.age
+	heap.Push(&p.h, e)
+}
+
+func (p *lfu) ordered() []*entry {
+	entries := slices.Clone(p.h)
+	slices.SortFunc(entries, func(a, b *entry) int {
+		return cmp.Or(cmp.Compare(b.prio, a.prio), cmp.Compare(b.tick, a.tick))
+	})
+	return entries
+}
+
+func (p *lfu) touch(e *entry) {
+	e.prio = p.age + e.freq
+	heap.Fix(&p.h, e.idx)
+}
+
+func (p *lfu) remove(e *entry) {
+	heap.Remove(&p.h, e.idx)
+}
+
+// evicted raises the cache age to priority of the victim. Only eviction under pressure ages the cache: deleting
+// or expiring an entry frees space without displacing anything, so it must not make later entries more valuable
+func (p *lfu) evicted(e *entry) {
+	p.age = max(p.age, e.prio)
+}
+
+func (p *lfu) victim() *entry {
+	if len(p.h) == 0 {
+		return nil
+	}
+	return p.h[0]
+}
+
+// lfuHeap implements heap.Interface ordered by (prio, tick)
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].prio != h[j].prio {
+		return h[i].prio < h[j].prio
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].idx = i
+	h[j].idx = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*entry)
+	e.idx = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	e.idx = -1
+	return e
+}
+
+// estimateSize returns approximate memory footprint of an order: struct sizes plus string contents
+func estimateSize(o *model.Order) int64 {
+	size := int64(unsafe.Sizeof(*o)) + int64(unsafe.Sizeof(entry{}))
+	size += int64(len(o.OrderUID) + len(o.TrackNumber) + len(o.Entry) + len(o.Locale) + len(o.InternalSignature) +
+		len(o.CustomerID) + len(o.DeliveryService) + len(o.ShardKey) + len(o.DateCreated) + len(o.OofShard))
+
+	d := &o.Delivery
+	size += int64(len(d.OrderUID) + len(d.Name) + len(d.Phone) + len(d.Zip) + len(d.City) + len(d.Address) + len(d.Region) + len(d.Email))
+
+	p := &o.Payment
+	size += int64(len(p.OrderUID) + len(p.Transaction) + len(p.RequestID) + len(p.Currency) + len(p.Provider) + len(p.Bank))
+
+	for i := range o.Items {
+		it := &o.Items[i]
+		size += int64(unsafe.Sizeof(*it))
+		size += int64(len(it.OrderUID) + len(it.TrackNumber) + len(it.RID) + len(it.Name) + len(it.Size) + len(it.Brand))
+	}
+	return size
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is incremented when the snapshot format changes; snapshots of other versions are ignored
+const snapshotVersion = 1
+
+// ErrSnapshotStale is returned by LoadSnapshot for a snapshot older than the allowed age
+var ErrSnapshotStale = errors.New("cache snapshot is too old")
+
+// snapshotHeader is the first line of a snapshot file
+type snapshotHeader struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Orders    int       `json:"orders"`
+}
+
+// SaveSnapshot writes cached orders to path as NDJSON: a header line followed by orders, the most valuable first.
+// Personal data is encrypted with codec(nil - written as is).
+// Data is written to a temporary file which then replaces path, so an interrupted write never leaves a partial snapshot
+func SaveSnapshot(path string, orderCache OrderCache, codec *pii.Codec) (int, error) {
+	orders := orderCache.Snapshot()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) //после успешного переименования файла уже нет
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(snapshotHeader{Version: snapshotVersion, CreatedAt: time.Now().UTC(), Orders: len(orders)})
+	for i := 0; err == nil && i < len(orders); i++ {
+		if err = codec.SealFields(orders[i].PIIFields()); err == nil {
+			err = enc.Encode(&orders[i])
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(orders), nil
+}
+
+// LoadSnapshot adds orders from a snapshot written by SaveSnapshot with OrderCache.Warm until the cache is full.
+// Returns ErrSnapshotStale if the snapshot is older than maxAge(0 - any age)
+func LoadSnapshot(ctx context.Context, path string, maxAge time.Duration, orderCache OrderCache, codec *pii.Codec) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("%s: header: %w", path, err)
+	}
+	if header.Version != snapshotVersion {
+		return 0, fmt.Errorf("%s: unsupported snapshot version %d", path, header.Version)
+	}
+	if age := time.Since(header.CreatedAt); maxAge > 0 && age > maxAge {
+		return 0, fmt.Errorf("%w: created %s ago", ErrSnapshotStale, age.Round(time.Second))
+	}
+
+	loaded := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return loaded, err
+		}
+		var order model.Order
+		err := dec.Decode(&order)
+		if err == io.EOF {
+			return loaded, nil
+		}
+		if err == nil {
+			err = codec.OpenFields(order.PIIFields())
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("%s: order %d: %w", path, loaded+1, err)
+		}
+		if !orderCache.Warm(order) {
+			return loaded, nil
+		}
+		loaded++
+	}
+}
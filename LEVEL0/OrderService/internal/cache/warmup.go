@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"orderservice/internal/repository"
+	"time"
+)
+
+// Warm-up strategies supported by WarmUp
+const (
+	WarmUpNone     = "none"
+	WarmUpCount    = "count"    // самые новые заказы, не больше Count
+	WarmUpAge      = "age"      // заказы, созданные за последние MaxAge
+	WarmUpSnapshot = "snapshot" // снимок, сохраненный при остановке; если его нет или он устарел - как count
+)
+
+// WarmUpConfig describes which orders are loaded into cache at startup
+type WarmUpConfig struct {
+	Strategy       string
+	Count          int
+	MaxAge         time.Duration
+	PageSize       int // заказов в одном запросе к БД
+	SnapshotPath   string
+	SnapshotMaxAge time.Duration // 0 - снимок любого возраста
+	PII            *pii.Codec    // расшифровка персональных данных снимка
+	// VerifySnapshot(may be nil) is called after orders are loaded from a snapshot, e.g. to evict orders changed since it was saved
+	VerifySnapshot func(ctx context.Context) error
+}
+
+// WarmUp fills orderCache according to cfg.Strategy. Orders are read from the primary DB page by page, newest first, and added with
+// OrderCache.Warm, so warm-up may run in background while requests are served: orders cached by requests are not overwritten.
+// Warm-up stops early when the cache is full
+func WarmUp(ctx context.Context, repo repository.OrderRepository, orderCache OrderCache, cfg WarmUpConfig) error {
+	start := time.Now()
+	filter := model.OrderFilter{SortBy: "date_created", SortDesc: true}
+	limit := cfg.Count
+	switch cfg.Strategy {
+	case WarmUpNone:
+		slog.InfoContext(ctx, "Cache warm-up is disabled")
+		return nil
+	case WarmUpSnapshot:
+		loaded, err := LoadSnapshot(ctx, cfg.SnapshotPath, cfg.SnapshotMaxAge, orderCache, cfg.PII)
+		if err == nil {
+			slog.InfoContext(ctx, "Cache loaded from snapshot", "orders", loaded, "path", cfg.SnapshotPath, "duration", time.Since(start))
+			if cfg.VerifySnapshot != nil {
+				return cfg.VerifySnapshot(ctx)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "Failed to load cache snapshot, warming up from DB", "path", cfg.SnapshotPath, logger.Err(err))
+	case WarmUpAge:
+		filter.DateFrom = time.Now().Add(-cfg.MaxAge)
+		limit = 0
+	case WarmUpCount:
+	default:
+		return fmt.Errorf("unknown cache warm-up strategy %q", cfg.Strategy)
+	}
+
+	loaded, err := warmUpFromDB(ctx, repo, orderCache, filter, limit, max(cfg.PageSize, 1))
+	if err != nil {
+		return fmt.Errorf("cache warm-up stopped after %d orders: %w", loaded, err)
+	}
+	slog.InfoContext(ctx, "Cache successfully loaded", "strategy", cfg.Strategy, "orders", loaded, "duration", time.Since(start))
+	return nil
+}
+
+// warmUpFromDB reads orders matching the filter using keyset pagination until limit(0 - no limit) orders are loaded,
+// the orders are over or the cache is full
+func warmUpFromDB(ctx context.Context, repo repository.OrderRepository, orderCache OrderCache, filter model.OrderFilter, limit, pageSize int) (int, error) {
+	var after *model.OrderCursor
+	loaded := 0
+	for {
+		filter.Limit = pageSize
+		if limit > 0 {
+			filter.Limit = min(pageSize, limit-loaded)
+		}
+		//реплика может отставать, а уведомление об изменении некешированного заказа игнорируется
+		orders, err := repo.ListOrders(repository.WithPrimary(ctx), filter, after)
+		if err != nil {
+			return loaded, err
+		}
+		for i := range orders {
+			if !orderCache.Warm(orders[i]) {
+				return loaded, nil
+			}
+			loaded++
+		}
+		if len(orders) < filter.Limit || (limit > 0 && loaded >= limit) {
+			return loaded, nil
+		}
+		last := &orders[len(orders)-1]
+		after = &model.OrderCursor{SortBy: filter.SortBy, SortDesc: filter.SortDesc, SortValue: last.DateCreated, OrderUID: last.OrderUID}
+	}
+}
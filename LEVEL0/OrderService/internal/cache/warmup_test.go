@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"orderservice/internal/repository"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepo returns memory repository with n orders created one hour apart, order-0 is the newest
+func testRepo(t *testing.T, n int) repository.OrderRepository {
+	t.Helper()
+	repo := repository.NewMemoryRepository()
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := range n {
+		order := &model.Order{
+			OrderUID:    fmt.Sprintf("order-%d", i),
+			DateCreated: now.Add(-time.Duration(i) * time.Hour).Format(time.RFC3339),
+		}
+		if err := repo.AddNewOrder(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestWarmUp_Strategies(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t, 10)
+
+	for _, tc := range []struct {
+		name   string
+		cfg    WarmUpConfig
+		limits Config
+		want   int
+	}{
+		{"count", WarmUpConfig{Strategy: WarmUpCount, Count: 5, PageSize: 2}, Config{}, 5},
+		{"count over total", WarmUpConfig{Strategy: WarmUpCount, Count: 100, PageSize: 3}, Config{}, 10},
+		{"age", WarmUpConfig{Strategy: WarmUpAge, MaxAge: 150 * time.Minute, PageSize: 2}, Config{}, 3},
+		{"full cache", WarmUpConfig{Strategy: WarmUpCount, Count: 100, PageSize: 3}, Config{MaxEntries: 4}, 4},
+		{"none", WarmUpConfig{Strategy: WarmUpNone}, Config{}, 0},
+	} {
+		c := newCache(t, tc.limits)
+		if err := WarmUp(ctx, repo, c, tc.cfg); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if c.Len() != tc.want {
+			t.Errorf("%s: expected %d orders, got %d", tc.name, tc.want, c.Len())
+		}
+		// загружаются самые новые заказы
+		if tc.want > 0 {
+			if _, ok := c.Get("order-0"); !ok {
+				t.Errorf("%s: expected the newest order in cache", tc.name)
+			}
+		}
+	}
+
+	if err := WarmUp(ctx, repo, newCache(t, Config{}), WarmUpConfig{Strategy: "oldest"}); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestSnapshot_SaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.ndjson")
+
+	src := newCache(t, Config{})
+	for i := range 5 {
+		src.Set(model.Order{OrderUID: fmt.Sprintf("order-%d", i), Items: []model.Item{{Name: "Mascara"}}})
+	}
+	if n, err := SaveSnapshot(path, src, nil); err != nil || n != 5 {
+		t.Fatalf("expected 5 saved orders, got %d, %v", n, err)
+	}
+
+	// при нехватке места остаются самые ценные заказы: последние добавленные в LRU
+	dst := newCache(t, Config{MaxEntries: 3})
+	if n, err := LoadSnapshot(ctx, path, time.Hour, dst, nil); err != nil || n != 3 {
+		t.Fatalf("expected 3 loaded orders, got %d, %v", n, err)
+	}
+	order, ok := dst.Get("order-4")
+	if !ok || len(order.Items) != 1 || order.Items[0].Name != "Mascara" {
+		t.Errorf("expected the most recent order with items, got %+v", order)
+	}
+	if _, ok := dst.Get("order-0"); ok {
+		t.Error("expected the least recently used order to be skipped")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if _, err := LoadSnapshot(ctx, path, time.Millisecond, newCache(t, Config{}), nil); !errors.Is(err, ErrSnapshotStale) {
+		t.Errorf("expected ErrSnapshotStale, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files must be removed, got %d files", len(entries))
+	}
+}
+
+func TestWarmUp_SnapshotFallsBackToDB(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepo(t, 4)
+	cfg := WarmUpConfig{Strategy: WarmUpSnapshot, Count: 2, PageSize: 10, SnapshotPath: filepath.Join(t.TempDir(), "missing.ndjson")}
+
+	c := newCache(t, Config{})
+	if err := WarmUp(ctx, repo, c, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected count warm-up without snapshot, got %d orders", c.Len())
+	}
+
+	// снимок есть - БД не используется
+	if _, err := SaveSnapshot(cfg.SnapshotPath, c, nil); err != nil {
+		t.Fatal(err)
+	}
+	c = newCache(t, Config{})
+	verified := 0
+	cfg.VerifySnapshot = func(context.Context) error { verified = c.Len(); return nil }
+	if err := WarmUp(ctx, repository.NewMemoryRepository(), c, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 || verified != 2 {
+		t.Fatalf("expected orders from snapshot to be verified, got %d orders, %d verified", c.Len(), verified)
+	}
+}
+
+func TestSnapshot_EncryptsPII(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.ndjson")
+	keys, err := pii.NewKeyring([]pii.Key{{ID: "k1", Secret: make([]byte, pii.KeySize)}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := pii.NewCodec(keys, pii.DefaultPolicy())
+
+	src := newCache(t, Config{})
+	src.Set(model.Order{OrderUID: "order-0", Delivery: model.Delivery{Phone: "+79040000000", City: "Moscow"}})
+	if _, err := SaveSnapshot(path, src, codec); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "+79040000000") || !strings.Contains(string(raw), "Moscow") {
+		t.Fatalf("expected encrypted phone and plain city in snapshot: %s", raw)
+	}
+	if cached, _ := src.Get("order-0"); cached.Delivery.Phone != "+79040000000" {
+		t.Fatal("saving snapshot must not change cached orders")
+	}
+
+	dst := newCache(t, Config{})
+	if _, err := LoadSnapshot(ctx, path, 0, dst, codec); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := dst.Get("order-0"); order.Delivery.Phone != "+79040000000" {
+		t.Errorf("expected decrypted phone, got %q", order.Delivery.Phone)
+	}
+	if _, err := LoadSnapshot(ctx, path, 0, newCache(t, Config{}), nil); !errors.Is(err, pii.ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys without codec, got %v", err)
+	}
+}
@@ -2,29 +2,67 @@ package db
 
 import (
 	"log"
-	"orderservice/internal/model"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-var models = []any{ //the order of tables is important
-	&model.Order{},
-	&model.Delivery{},
-	&model.Payment{},
-	&model.Item{},
-	&model.InvalidRequest{},
+// PoolConfig configures connection pool and session of a DB; zero fields keep database/sql and server defaults
+type PoolConfig struct {
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	StatementTimeout time.Duration // передается серверу как statement_timeout каждого соединения
 }
 
-// ConnectPostgres creates connection to Postres and runs automigration using structs from order.go
-func ConnectPostgres(dsn string) *gorm.DB {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// gormLogger writes slow queries and errors through slog(log.Default is redirected to it by logger.Setup).
+// Queries are logged without parameter values: they contain personal data of customers
+var gormLogger = gormlogger.New(log.Default(), gormlogger.Config{
+	SlowThreshold:             200 * time.Millisecond,
+	LogLevel:                  gormlogger.Warn,
+	IgnoreRecordNotFoundError: true,
+	ParameterizedQueries:      true,
+})
+
+// ConnectPostgres creates connection pool to Postgres; schema is managed by migrations from internal/migrate.
+// name identifies the DB(primary, replica-1, ...) in logs and pool metrics
+func ConnectPostgres(name, dsn string, pool PoolConfig) *gorm.DB {
+	connConfig, err := pgx.ParseConfig(dsn)
 	if err != nil {
-		log.Fatalf("Cannot open db: %v", err)
+		logger.Fatal("Invalid DSN", "db", name, logger.Err(err))
+	}
+	if pool.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(pool.StatementTimeout.Milliseconds(), 10)
+	}
+	sqlDB := stdlib.OpenDB(*connConfig)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
 	}
-	if err := db.AutoMigrate(models...); err != nil {
-		log.Fatalf("Failed to migrate: %v", err)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	//TranslateError: нарушение уникальности возвращается как gorm.ErrDuplicatedKey, как и в репозитории в памяти
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{TranslateError: true, Logger: gormLogger})
+	if err != nil {
+		logger.Fatal("Cannot open db", "db", name, logger.Err(err))
 	}
-	log.Println("Connected to Postgres")
+	metrics.RegisterDBPool(name, sqlDB, map[string]float64{
+		"max_open_conns":             float64(pool.MaxOpenConns),
+		"max_idle_conns":             float64(pool.MaxIdleConns),
+		"conn_max_lifetime_seconds":  pool.ConnMaxLifetime.Seconds(),
+		"conn_max_idle_time_seconds": pool.ConnMaxIdleTime.Seconds(),
+		"statement_timeout_seconds":  pool.StatementTimeout.Seconds(),
+	})
+	slog.Info("Connected to Postgres", "db", name, "max_open_conns", pool.MaxOpenConns, "statement_timeout", pool.StatementTimeout)
 	return db
 }
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/resilience"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListenerConfig describes Postgres channel subscription
+type ListenerConfig struct {
+	DSN          string
+	Channel      string
+	PingInterval time.Duration // как часто проверять соединение, если уведомлений нет
+	Backoff      resilience.Backoff
+}
+
+// Listener receives Postgres NOTIFY messages over a dedicated connection and restores it after failures
+type Listener struct {
+	cfg ListenerConfig
+	// OnNotify handles payload of every notification
+	OnNotify func(ctx context.Context, payload string)
+	// OnReconnect(may be nil) is called after the connection is restored: notifications sent while it was lost are missed
+	OnReconnect func(ctx context.Context)
+
+	listening chan struct{} //закрывается после первой успешной подписки
+}
+
+// NewListener returns listener with defaults for zero config fields
+func NewListener(cfg ListenerConfig, onNotify func(ctx context.Context, payload string)) *Listener {
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = 30 * time.Second
+	}
+	if cfg.Backoff.Initial <= 0 {
+		cfg.Backoff = resilience.Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second, Jitter: 0.5}
+	}
+	return &Listener{cfg: cfg, OnNotify: onNotify, listening: make(chan struct{})}
+}
+
+// Listening returns channel closed once Run has subscribed for the first time: changes committed after that are notified
+func (L *Listener) Listening() <-chan struct{} {
+	return L.listening
+}
+
+// Run listens until ctx is done
+func (L *Listener) Run(ctx context.Context) {
+	connected := false
+	for retry := 0; ; retry++ {
+		err := L.listen(ctx, func() {
+			if connected {
+				slog.InfoContext(ctx, "Postgres listener reconnected", "channel", L.cfg.Channel)
+				if L.OnReconnect != nil {
+					L.OnReconnect(ctx)
+				}
+			} else {
+				slog.InfoContext(ctx, "Postgres listener started", "channel", L.cfg.Channel)
+				close(L.listening)
+			}
+			connected, retry = true, 0
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		delay := L.cfg.Backoff.Delay(retry)
+		slog.WarnContext(ctx, "Postgres listener disconnected, reconnecting", "channel", L.cfg.Channel, "delay", delay, logger.Err(err))
+		if !resilience.Sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// listen subscribes on a new connection and handles notifications until an error; onListen is called after subscription
+func (L *Listener) listen(ctx context.Context, onListen func()) error {
+	conn, err := pgx.Connect(ctx, L.cfg.DSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{L.cfg.Channel}.Sanitize()); err != nil {
+		return err
+	}
+	onListen()
+
+	for {
+		//ожидание ограничено, чтобы вовремя заметить оборванное соединение, по которому не приходит ошибка
+		waitCtx, cancel := context.WithTimeout(ctx, L.cfg.PingInterval)
+		n, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		switch {
+		case err == nil:
+			L.OnNotify(ctx, n.Payload)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, context.DeadlineExceeded):
+			if err := conn.Ping(ctx); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"orderservice/internal/model"
+	"strconv"
+)
+
+// csvWriter writes a header and one record per item, missing values are empty
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+	record []string
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+}
+
+func (CW *csvWriter) Write(order *model.Order) error {
+	if err := CW.writeHeader(); err != nil {
+		return err
+	}
+	return eachRow(order, func(item *model.Item) error {
+		for i, c := range columns {
+			switch v := c.value(order, item).(type) {
+			case string:
+				CW.record[i] = v
+			case int64:
+				CW.record[i] = strconv.FormatInt(v, 10)
+			default:
+				CW.record[i] = ""
+			}
+		}
+		return CW.w.Write(CW.record)
+	})
+}
+
+// Close writes the header if there were no orders and flushes buffered records
+func (CW *csvWriter) Close() error {
+	if err := CW.writeHeader(); err != nil {
+		return err
+	}
+	CW.w.Flush()
+	return CW.w.Error()
+}
+
+func (CW *csvWriter) writeHeader() error {
+	if CW.header {
+		return nil
+	}
+	CW.header = true
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return CW.w.Write(names)
+}
@@ -0,0 +1,146 @@
+// Package export writes orders to files for bulk export: CSV and Parquet with one row per item,
+// NDJSON with one order per line
+package export
+
+import (
+	"fmt"
+	"io"
+	"orderservice/internal/model"
+	"strings"
+	"time"
+)
+
+// Supported export formats
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Formats lists supported formats in the order they are shown to users
+var Formats = []string{FormatCSV, FormatNDJSON, FormatParquet}
+
+// Writer encodes orders into an export file. Close flushes buffered data and writes the footer(if the format has one),
+// it must be called once after the last order; the underlying io.Writer is not closed
+type Writer interface {
+	Write(order *model.Order) error
+	Close() error
+}
+
+// NewWriter returns Writer of the given format writing to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат выгрузки %q, поддерживаются: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// ContentType returns MIME type of the format for HTTP responses
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// columnType is a type of a flattened column
+type columnType int
+
+const (
+	columnString columnType = iota
+	columnInt
+)
+
+// column is a field of the flattened row: an item together with its order, delivery and payment.
+// value returns string or int64 according to typ, or nil for a missing value
+type column struct {
+	name     string
+	typ      columnType
+	optional bool // nil допускается: поля товара у заказа без товаров и cancelled_at
+	value    func(o *model.Order, item *model.Item) any
+}
+
+// columns of CSV and Parquet files; an order without items gives one row with empty item columns
+var columns = []column{
+	{name: "order_uid", value: func(o *model.Order, _ *model.Item) any { return o.OrderUID }},
+	{name: "track_number", value: func(o *model.Order, _ *model.Item) any { return o.TrackNumber }},
+	{name: "entry", value: func(o *model.Order, _ *model.Item) any { return o.Entry }},
+	{name: "locale", value: func(o *model.Order, _ *model.Item) any { return o.Locale }},
+	{name: "internal_signature", value: func(o *model.Order, _ *model.Item) any { return o.InternalSignature }},
+	{name: "customer_id", value: func(o *model.Order, _ *model.Item) any { return o.CustomerID }},
+	{name: "delivery_service", value: func(o *model.Order, _ *model.Item) any { return o.DeliveryService }},
+	{name: "shardkey", value: func(o *model.Order, _ *model.Item) any { return o.ShardKey }},
+	{name: "sm_id", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.SMID) }},
+	{name: "date_created", value: func(o *model.Order, _ *model.Item) any { return o.DateCreated }},
+	{name: "oof_shard", value: func(o *model.Order, _ *model.Item) any { return o.OofShard }},
+	{name: "version", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Version) }},
+	{name: "cancelled_at", optional: true, value: func(o *model.Order, _ *model.Item) any {
+		if o.CancelledAt == nil {
+			return nil
+		}
+		return o.CancelledAt.UTC().Format(time.RFC3339)
+	}},
+
+	{name: "delivery_name", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Name }},
+	{name: "delivery_phone", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Phone }},
+	{name: "delivery_zip", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Zip }},
+	{name: "delivery_city", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.City }},
+	{name: "delivery_address", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Address }},
+	{name: "delivery_region", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Region }},
+	{name: "delivery_email", value: func(o *model.Order, _ *model.Item) any { return o.Delivery.Email }},
+
+	{name: "payment_transaction", value: func(o *model.Order, _ *model.Item) any { return o.Payment.Transaction }},
+	{name: "payment_request_id", value: func(o *model.Order, _ *model.Item) any { return o.Payment.RequestID }},
+	{name: "payment_currency", value: func(o *model.Order, _ *model.Item) any { return o.Payment.Currency }},
+	{name: "payment_provider", value: func(o *model.Order, _ *model.Item) any { return o.Payment.Provider }},
+	{name: "payment_amount", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Payment.Amount) }},
+	{name: "payment_dt", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Payment.PaymentDT) }},
+	{name: "payment_bank", value: func(o *model.Order, _ *model.Item) any { return o.Payment.Bank }},
+	{name: "payment_delivery_cost", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Payment.DeliveryCost) }},
+	{name: "payment_goods_total", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Payment.GoodsTotal) }},
+	{name: "payment_custom_fee", typ: columnInt, value: func(o *model.Order, _ *model.Item) any { return int64(o.Payment.CustomFee) }},
+
+	itemColumn("item_chrt_id", columnInt, func(i *model.Item) any { return int64(i.ChrtID) }),
+	itemColumn("item_track_number", columnString, func(i *model.Item) any { return i.TrackNumber }),
+	itemColumn("item_price", columnInt, func(i *model.Item) any { return int64(i.Price) }),
+	itemColumn("item_rid", columnString, func(i *model.Item) any { return i.RID }),
+	itemColumn("item_name", columnString, func(i *model.Item) any { return i.Name }),
+	itemColumn("item_sale", columnInt, func(i *model.Item) any { return int64(i.Sale) }),
+	itemColumn("item_size", columnString, func(i *model.Item) any { return i.Size }),
+	itemColumn("item_total_price", columnInt, func(i *model.Item) any { return int64(i.TotalPrice) }),
+	itemColumn("item_nm_id", columnInt, func(i *model.Item) any { return int64(i.NMID) }),
+	itemColumn("item_brand", columnString, func(i *model.Item) any { return i.Brand }),
+	itemColumn("item_status", columnInt, func(i *model.Item) any { return int64(i.Status) }),
+}
+
+func itemColumn(name string, typ columnType, value func(i *model.Item) any) column {
+	return column{name: name, typ: typ, optional: true, value: func(_ *model.Order, item *model.Item) any {
+		if item == nil {
+			return nil
+		}
+		return value(item)
+	}}
+}
+
+// eachRow calls fn for every flattened row of the order
+func eachRow(o *model.Order, fn func(item *model.Item) error) error {
+	if len(o.Items) == 0 {
+		return fn(nil)
+	}
+	for i := range o.Items {
+		if err := fn(&o.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"orderservice/internal/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+func testOrders() []*model.Order {
+	cancelled := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+	return []*model.Order{
+		{
+			OrderUID: "o1", DateCreated: "2024-01-01T00:00:00Z", SMID: 99,
+			Delivery: model.Delivery{Name: "Test Testov", City: "Kiryat Mozkin"},
+			Payment:  model.Payment{Currency: "USD", Amount: 1817},
+			Items: []model.Item{
+				{ChrtID: 1, Name: "Mascaras", Brand: "Vivienne Sabo", TotalPrice: 317},
+				{ChrtID: 2, Name: "Lipstick", Brand: "Vivienne Sabo", TotalPrice: 1500},
+			},
+		},
+		{OrderUID: "o2", DateCreated: "2024-01-02T00:00:00Z", Payment: model.Payment{Currency: "RUB"}, CancelledAt: &cancelled},
+	}
+}
+
+func writeAll(t *testing.T, format string, orders []*model.Order) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orders {
+		if err := w.Write(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewWriter_UnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xlsx", &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV, testOrders()))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows(one per item, one for the order without items), got %d", len(records))
+	}
+	col := func(name string) int { return slices.IndexFunc(columns, func(c column) bool { return c.name == name }) }
+	if records[0][0] != "order_uid" || records[0][col("item_name")] != "item_name" {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	for _, tt := range []struct {
+		row        int
+		name, want string
+	}{
+		{1, "order_uid", "o1"},
+		{1, "delivery_city", "Kiryat Mozkin"},
+		{1, "payment_amount", "1817"},
+		{1, "item_name", "Mascaras"},
+		{2, "item_total_price", "1500"},
+		{2, "sm_id", "99"},
+		{1, "cancelled_at", ""},
+		{3, "order_uid", "o2"},
+		{3, "cancelled_at", "2024-01-03T10:00:00Z"},
+		{3, "item_chrt_id", ""},
+	} {
+		if got := records[tt.row][col(tt.name)]; got != tt.want {
+			t.Errorf("row %d %s = %q, want %q", tt.row, tt.name, got, tt.want)
+		}
+	}
+
+	if got := string(writeAll(t, FormatCSV, nil)); !strings.HasPrefix(got, "order_uid,") || strings.Count(got, "\n") != 1 {
+		t.Errorf("empty export must contain only the header, got %q", got)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	orders := testOrders()
+	scanner := bufio.NewScanner(bytes.NewReader(writeAll(t, FormatNDJSON, orders)))
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var got model.Order
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if got.OrderUID != orders[i].OrderUID || len(got.Items) != len(orders[i].Items) || got.Delivery.City != orders[i].Delivery.City {
+			t.Errorf("line %d: got %+v, want %+v", i, got, orders[i])
+		}
+	}
+	if i != len(orders) {
+		t.Errorf("expected %d lines, got %d", len(orders), i)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	f := openParquet(t, writeAll(t, FormatParquet, testOrders()))
+	if f.NumRows() != 3 || len(f.RowGroups()) != 1 {
+		t.Fatalf("expected 3 rows in 1 row group, got %d rows, %d groups", f.NumRows(), len(f.RowGroups()))
+	}
+	for _, el := range f.Metadata().Schema[1:] {
+		optional := *el.RepetitionType == format.Optional
+		if want := el.Name == "cancelled_at" || strings.HasPrefix(el.Name, "item_"); optional != want {
+			t.Errorf("column %s: optional = %v, want %v", el.Name, optional, want)
+		}
+		switch el.Name {
+		case "sm_id":
+			if *el.Type != format.Int64 {
+				t.Errorf("column %s has type %v, want INT64", el.Name, *el.Type)
+			}
+		case "order_uid":
+			if *el.Type != format.ByteArray || el.LogicalType == nil || el.LogicalType.UTF8 == nil {
+				t.Errorf("column %s has type %v, want UTF8 string", el.Name, *el.Type)
+			}
+		}
+	}
+	for _, chunk := range f.Metadata().RowGroups[0].Columns {
+		if chunk.MetaData.Codec != format.Snappy {
+			t.Errorf("column %v is not Snappy-compressed: %v", chunk.MetaData.PathInSchema, chunk.MetaData.Codec)
+		}
+	}
+
+	empty := openParquet(t, writeAll(t, FormatParquet, nil))
+	if empty.NumRows() != 0 || len(empty.Schema().Columns()) != len(columns) {
+		t.Errorf("empty export must have the schema and no rows, got %d rows, %d columns", empty.NumRows(), len(empty.Schema().Columns()))
+	}
+}
+
+func TestParquetWriter_RowGroups(t *testing.T) {
+	orders := make([]*model.Order, parquetRowGroupRows+1)
+	for i := range orders {
+		orders[i] = &model.Order{OrderUID: "o"}
+	}
+	f := openParquet(t, writeAll(t, FormatParquet, orders))
+	if len(f.RowGroups()) != 2 || f.NumRows() != int64(len(orders)) {
+		t.Errorf("expected 2 row groups with %d rows, got %d groups, %d rows", len(orders), len(f.RowGroups()), f.NumRows())
+	}
+}
+
+// TestParquetWriter_MatchesCSV reads the file back with parquet-go and compares it with the CSV export of the same orders
+func TestParquetWriter_MatchesCSV(t *testing.T) {
+	orders := testOrders()
+	for i := range parquetRowGroupRows {
+		orders = append(orders, &model.Order{OrderUID: fmt.Sprintf("bulk%d", i), SMID: i, Items: []model.Item{{Name: "n", TotalPrice: uint(i)}}})
+	}
+	want, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV, orders))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := writeAll(t, FormatParquet, orders)
+
+	var header []string
+	for _, path := range openParquet(t, file).Schema().Columns() {
+		header = append(header, strings.Join(path, "."))
+	}
+	if !slices.Equal(header, want[0]) {
+		t.Fatalf("schema %v does not match CSV header %v", header, want[0])
+	}
+
+	r := parquet.NewReader(bytes.NewReader(file))
+	defer r.Close()
+	var rows [][]string
+	buf := make([]parquet.Row, 100)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			values := make([]string, len(row))
+			for _, v := range row {
+				if !v.IsNull() {
+					values[v.Column()] = v.String()
+				}
+			}
+			rows = append(rows, values)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rows) != len(want)-1 {
+		t.Fatalf("expected %d rows, got %d", len(want)-1, len(rows))
+	}
+	for i, row := range rows {
+		if !slices.Equal(row, want[i+1]) {
+			t.Fatalf("row %d: got %v, want %v", i, row, want[i+1])
+		}
+	}
+}
+
+func openParquet(t *testing.T, file []byte) *parquet.File {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("not a valid Parquet file: %v", err)
+	}
+	return f
+}
@@ -0,0 +1,28 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"orderservice/internal/model"
+)
+
+// ndjsonWriter writes one order per line in the JSON format orders are received from Kafka,
+// so an export can be fed back to the topic or to POST /api/v1/orders
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (NW *ndjsonWriter) Write(order *model.Order) error {
+	return NW.enc.Encode(order) //Encode добавляет перевод строки
+}
+
+func (NW *ndjsonWriter) Close() error {
+	return NW.buf.Flush()
+}
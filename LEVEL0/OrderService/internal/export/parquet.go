@@ -0,0 +1,78 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"orderservice/internal/model"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// строк в группе: колонки группы копятся в памяти до записи, это ограничивает память на выгрузку
+const parquetRowGroupRows = 10000
+
+// parquetSchema has the columns of CSV export in the same order: strings are UTF8 byte arrays, numbers are INT64,
+// optional columns may be null. parquet.Group sorts fields by name, so the schema is built from a struct type
+var parquetSchema = func() *parquet.Schema {
+	fields := make([]reflect.StructField, len(columns))
+	for i, c := range columns {
+		fields[i] = reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: reflect.TypeFor[string]()}
+		if c.typ == columnInt {
+			fields[i].Type = reflect.TypeFor[int64]()
+		}
+		tag := c.name
+		if c.optional {
+			tag += ",optional"
+		}
+		fields[i].Tag = reflect.StructTag(fmt.Sprintf("parquet:%q", tag))
+	}
+	return parquet.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface())
+}()
+
+// parquetWriter writes a flat Snappy-compressed Parquet file with the columns of CSV export.
+// Only a row group is kept in memory, the footer with metadata of all row groups is written by Close
+type parquetWriter struct {
+	w *parquet.Writer
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: parquet.NewWriter(w, parquetSchema,
+		parquet.Compression(&parquet.Snappy),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupRows),
+	)}
+}
+
+func (PW *parquetWriter) Write(order *model.Order) error {
+	var rows []parquet.Row
+	err := eachRow(order, func(item *model.Item) error {
+		row := make(parquet.Row, len(columns))
+		for i, c := range columns {
+			//колонки схемы верхнего уровня, поэтому индекс колонки совпадает с индексом в columns
+			var v parquet.Value
+			switch value := c.value(order, item).(type) {
+			case string:
+				v = parquet.ByteArrayValue([]byte(value))
+			case int64:
+				v = parquet.Int64Value(value)
+			}
+			definition := 0
+			if c.optional && !v.IsNull() {
+				definition = 1
+			}
+			row[i] = v.Level(0, definition, i)
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = PW.w.WriteRows(rows)
+	return err
+}
+
+// Close writes the last row group and the footer
+func (PW *parquetWriter) Close() error {
+	return PW.w.Close()
+}
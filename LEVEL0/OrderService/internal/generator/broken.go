@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"encoding/json"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"orderservice/internal/validation"
+)
+
+// breaker corrupts a valid order so that it violates rule
+type breaker struct {
+	rule  string
+	apply func(o *model.Order) []byte
+}
+
+// breakers cover every rule of validation.ValidateOrder and validation.ValidateEvent plus invalid JSON
+var breakers = []breaker{
+	{validation.RuleRequired, func(o *model.Order) []byte {
+		o.Delivery.City = ""
+		return marshal(o)
+	}},
+	{validation.RuleEmail, func(o *model.Order) []byte {
+		o.Delivery.Email = "customer.example.com"
+		return marshal(o)
+	}},
+	{validation.RulePhone, func(o *model.Order) []byte {
+		o.Delivery.Phone = "call me later"
+		return marshal(o)
+	}},
+	{validation.RuleCurrency, func(o *model.Order) []byte {
+		o.Payment.Currency = "ZZZ"
+		return marshal(o)
+	}},
+	{validation.RuleLocale, func(o *model.Order) []byte {
+		o.Locale = "English"
+		return marshal(o)
+	}},
+	{validation.RuleRFC3339, func(o *model.Order) []byte {
+		o.DateCreated = "26.11.2021 06:22"
+		return marshal(o)
+	}},
+	{validation.RuleNotEmpty, func(o *model.Order) []byte {
+		o.Items = []model.Item{}
+		return marshal(o)
+	}},
+	{validation.RuleGoodsTotalSum, func(o *model.Order) []byte {
+		o.Payment.GoodsTotal++
+		return marshal(o)
+	}},
+	{validation.RuleTrackNumberMatch, func(o *model.Order) []byte {
+		o.Items[0].TrackNumber = o.TrackNumber + "X"
+		return marshal(o)
+	}},
+	{validation.RuleReservedPrefix, func(o *model.Order) []byte {
+		o.Delivery.Address = pii.Prefix + o.Delivery.Address
+		return marshal(o)
+	}},
+	{validation.RuleEventType, func(o *model.Order) []byte {
+		return marshal(model.OrderEvent{EventType: "order.deleted", OrderUID: o.OrderUID, Version: 1})
+	}},
+	{validation.RuleOrderUIDMatch, func(o *model.Order) []byte {
+		uid := o.OrderUID
+		o.OrderUID += "x"
+		return marshal(model.OrderEvent{EventType: model.EventOrderUpdated, OrderUID: uid, Version: 1, Order: o})
+	}},
+	{validation.RuleJSONSyntax, func(o *model.Order) []byte {
+		raw := marshal(o)
+		return raw[:len(raw)/2]
+	}},
+}
+
+func marshal(v any) []byte {
+	raw, _ := json.Marshal(v)
+	return raw
+}
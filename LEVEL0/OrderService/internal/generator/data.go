@@ -0,0 +1,37 @@
+package generator
+
+// localeNames holds values that depend on the order locale
+type localeNames struct {
+	people      []string
+	cities      []string
+	regions     []string
+	streets     []string
+	phonePrefix string
+}
+
+// namesByLocale - unknown locales use "en"
+var namesByLocale = map[string]*localeNames{
+	"en": {
+		people:      []string{"John Smith", "Emily Johnson", "Michael Brown", "Sarah Davis", "David Wilson"},
+		cities:      []string{"London", "Manchester", "Kiryat Mozkin", "Dublin", "Leeds"},
+		regions:     []string{"Greater London", "Kraiot", "Leinster", "Yorkshire"},
+		streets:     []string{"Baker Street", "High Street", "Station Road", "Ploshad Mira"},
+		phonePrefix: "+44",
+	},
+	"ru": {
+		people:      []string{"Иван Петров", "Анна Смирнова", "Сергей Кузнецов", "Мария Иванова", "Дмитрий Соколов"},
+		cities:      []string{"Москва", "Санкт-Петербург", "Казань", "Новосибирск", "Екатеринбург"},
+		regions:     []string{"Московская обл.", "Ленинградская обл.", "Татарстан", "Свердловская обл."},
+		streets:     []string{"ул. Ленина", "пр. Мира", "ул. Гагарина", "Невский пр."},
+		phonePrefix: "+7",
+	},
+}
+
+var (
+	products         = []string{"Mascaras", "T-shirt", "Sneakers", "Backpack", "Headphones", "Phone case", "Notebook", "Umbrella"}
+	brands           = []string{"Vivienne Sabo", "Nike", "Adidas", "Xiaomi", "Samsonite", "Gloria Jeans"}
+	sizes            = []string{"0", "S", "M", "L", "XL", "42"}
+	providers        = []string{"wbpay", "sbp", "card"}
+	banks            = []string{"alpha", "sber", "tinkoff", "vtb"}
+	deliveryServices = []string{"meest", "cdek", "boxberry", "wb"}
+)
@@ -0,0 +1,176 @@
+// Package generator synthesizes random order payloads for load tests: valid orders and deliberately broken ones
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"orderservice/internal/model"
+	"orderservice/internal/validation"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config describes what orders look like
+type Config struct {
+	MinItems       int
+	MaxItems       int
+	Locales        []string
+	Currencies     []string
+	BrokenFraction float64 // доля некорректных сообщений от 0 до 1
+	Seed           uint64  // 0 - случайный; одинаковый seed дает одинаковую последовательность
+}
+
+// Payload is a generated message
+type Payload struct {
+	Key   string // order_uid, используется как ключ сообщения Kafka
+	Value []byte
+	Rule  string // пусто для корректного заказа, иначе правило валидации, которое нарушено
+}
+
+// Generator produces payloads; it is safe for concurrent use
+type Generator struct {
+	cfg Config
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	breaker int // следующий способ испортить сообщение, перебираются по кругу
+	now     func() time.Time
+}
+
+// New checks config by generating a valid order for every locale and currency
+func New(cfg Config) (*Generator, error) {
+	switch {
+	case cfg.MinItems < 1 || cfg.MaxItems < cfg.MinItems:
+		return nil, fmt.Errorf("invalid items range %d-%d", cfg.MinItems, cfg.MaxItems)
+	case len(cfg.Locales) == 0:
+		return nil, errors.New("at least one locale is required")
+	case len(cfg.Currencies) == 0:
+		return nil, errors.New("at least one currency is required")
+	case cfg.BrokenFraction < 0 || cfg.BrokenFraction > 1:
+		return nil, fmt.Errorf("broken fraction %v is out of [0, 1]", cfg.BrokenFraction)
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	g := &Generator{cfg: cfg, rnd: rand.New(rand.NewPCG(seed, seed)), now: time.Now}
+
+	for _, locale := range cfg.Locales {
+		for _, currency := range cfg.Currencies {
+			order := g.order(locale, currency)
+			if v := validation.ValidateOrder(&order); len(v) > 0 {
+				return nil, fmt.Errorf("locale %q, currency %q: %w", locale, currency, v)
+			}
+		}
+	}
+	return g, nil
+}
+
+// Order returns a random valid order
+func (g *Generator) Order() model.Order {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.order(pick(g.rnd, g.cfg.Locales), pick(g.rnd, g.cfg.Currencies))
+}
+
+// Next returns a valid order or, with probability BrokenFraction, a broken payload
+func (g *Generator) Next() Payload {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	order := g.order(pick(g.rnd, g.cfg.Locales), pick(g.rnd, g.cfg.Currencies))
+	if g.rnd.Float64() >= g.cfg.BrokenFraction {
+		value, _ := json.Marshal(order)
+		return Payload{Key: order.OrderUID, Value: value}
+	}
+	b := breakers[g.breaker]
+	g.breaker = (g.breaker + 1) % len(breakers)
+	return Payload{Key: order.OrderUID, Value: b.apply(&order), Rule: b.rule}
+}
+
+// order builds a consistent order: items share the track number, goods_total is the sum of items, amount includes delivery and fees
+func (g *Generator) order(locale, currency string) model.Order {
+	r := g.rnd
+	names := namesByLocale[locale]
+	if names == nil {
+		names = namesByLocale["en"]
+	}
+	uid := randomString(r, 16)
+	track := "WBIL" + strconv.Itoa(1_000_000+r.IntN(9_000_000))
+	created := g.now().UTC().Add(-time.Duration(r.IntN(30*24*3600)) * time.Second).Truncate(time.Second)
+
+	n := g.cfg.MinItems + r.IntN(g.cfg.MaxItems-g.cfg.MinItems+1)
+	items := make([]model.Item, n)
+	var goodsTotal uint
+	for i := range items {
+		price := uint(100 + r.IntN(9900))
+		sale := uint(r.IntN(60))
+		total := max(price*(100-sale)/100, 1)
+		items[i] = model.Item{
+			ChrtID:      uint(1_000_000 + r.IntN(9_000_000)),
+			TrackNumber: track,
+			Price:       price,
+			RID:         randomString(r, 20),
+			Name:        pick(r, products),
+			Sale:        sale,
+			Size:        pick(r, sizes),
+			TotalPrice:  total,
+			NMID:        uint(100_000 + r.IntN(900_000)),
+			Brand:       pick(r, brands),
+			Status:      202,
+		}
+		goodsTotal += total
+	}
+	deliveryCost := uint(r.IntN(2000))
+	customFee := uint(r.IntN(3)) * 100
+
+	return model.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    pick(r, names.people),
+			Phone:   fmt.Sprintf("%s %03d %03d %02d %02d", names.phonePrefix, r.IntN(1000), r.IntN(1000), r.IntN(100), r.IntN(100)),
+			Zip:     strconv.Itoa(100_000 + r.IntN(900_000)),
+			City:    pick(r, names.cities),
+			Address: fmt.Sprintf("%s %d", pick(r, names.streets), 1+r.IntN(150)),
+			Region:  pick(r, names.regions),
+			Email:   fmt.Sprintf("customer%d@example.com", r.IntN(100_000)),
+		},
+		Payment: model.Payment{
+			Transaction:  uid,
+			Currency:     currency,
+			Provider:     pick(r, providers),
+			Amount:       goodsTotal + deliveryCost + customFee,
+			PaymentDT:    uint(created.Unix()),
+			Bank:         pick(r, banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+			CustomFee:    customFee,
+		},
+		Items:           items,
+		Locale:          locale,
+		CustomerID:      "customer-" + strconv.Itoa(r.IntN(10_000)),
+		DeliveryService: pick(r, deliveryServices),
+		ShardKey:        strconv.Itoa(r.IntN(10)),
+		SMID:            r.IntN(100),
+		DateCreated:     created.Format(time.RFC3339),
+		OofShard:        strconv.Itoa(1 + r.IntN(2)),
+	}
+}
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func randomString(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[r.IntN(len(alphabet))]
+	}
+	return string(b)
+}
+
+func pick[T any](r *rand.Rand, values []T) T {
+	return values[r.IntN(len(values))]
+}
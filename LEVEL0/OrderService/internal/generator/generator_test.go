@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"orderservice/internal/model"
+	"orderservice/internal/validation"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{MinItems: 1, MaxItems: 4, Locales: []string{"en", "ru"}, Currencies: []string{"USD", "RUB"}, Seed: 42}
+}
+
+func TestOrder_Valid(t *testing.T) {
+	g, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 200 {
+		order := g.Order()
+		if v := validation.ValidateOrder(&order); len(v) > 0 {
+			t.Fatalf("generated order is invalid: %v", v)
+		}
+		if len(order.Items) < 1 || len(order.Items) > 4 {
+			t.Fatalf("items count %d is out of range", len(order.Items))
+		}
+		if !slices.Contains([]string{"USD", "RUB"}, order.Payment.Currency) {
+			t.Fatalf("unexpected currency %q", order.Payment.Currency)
+		}
+	}
+}
+
+func TestNext_BrokenCoversEveryRule(t *testing.T) {
+	cfg := testConfig()
+	cfg.BrokenFraction = 1
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	covered := make(map[string]bool)
+	for range len(breakers) {
+		p := g.Next()
+		covered[p.Rule] = true
+		if got := violatedRules(p.Value); !slices.Contains(got, p.Rule) {
+			t.Errorf("payload for rule %s violates %v", p.Rule, got)
+		}
+	}
+	for _, rule := range []string{
+		validation.RuleRequired, validation.RuleEmail, validation.RulePhone, validation.RuleCurrency,
+		validation.RuleLocale, validation.RuleRFC3339, validation.RuleNotEmpty, validation.RuleGoodsTotalSum,
+		validation.RuleTrackNumberMatch, validation.RuleReservedPrefix, validation.RuleEventType, validation.RuleOrderUIDMatch,
+		validation.RuleJSONSyntax,
+	} {
+		if !covered[rule] {
+			t.Errorf("rule %s is not covered", rule)
+		}
+	}
+}
+
+// violatedRules decodes payload like the service does and returns violated rules
+func violatedRules(raw []byte) []string {
+	var probe struct {
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return []string{validation.RuleJSONSyntax}
+	}
+	var v validation.Violations
+	if probe.EventType != "" {
+		var event model.OrderEvent
+		_ = json.Unmarshal(raw, &event)
+		v = validation.ValidateEvent(&event)
+	} else {
+		var order model.Order
+		_ = json.Unmarshal(raw, &order)
+		v = validation.ValidateOrder(&order)
+	}
+	rules := make([]string, len(v))
+	for i := range v {
+		rules[i] = v[i].Rule
+	}
+	return rules
+}
+
+func TestNew_RejectsInvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Currencies = []string{"ZZZ"}
+	if _, err := New(cfg); err == nil {
+		t.Error("expected error for unknown currency")
+	}
+	cfg = testConfig()
+	cfg.Locales = []string{"English"}
+	if _, err := New(cfg); err == nil {
+		t.Error("expected error for invalid locale")
+	}
+	cfg = testConfig()
+	cfg.MinItems, cfg.MaxItems = 3, 2
+	if _, err := New(cfg); err == nil {
+		t.Error("expected error for invalid items range")
+	}
+}
+
+func TestRun_WriterSink(t *testing.T) {
+	cfg := testConfig()
+	cfg.BrokenFraction = 0.5
+	now := time.Now()
+	g, _ := New(cfg)
+	g.now = func() time.Time { return now }
+	var buf bytes.Buffer
+
+	stats := Run(context.Background(), g, &WriterSink{W: &buf}, 0, 50)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if stats.Sent != 50 || len(lines) != 50 {
+		t.Fatalf("expected 50 messages, got %d sent and %d lines", stats.Sent, len(lines))
+	}
+	broken := 0
+	for _, n := range stats.Broken {
+		broken += n
+	}
+	if broken == 0 || broken == 50 {
+		t.Errorf("expected a mix of valid and broken messages, got %d broken", broken)
+	}
+
+	// одинаковый seed дает одинаковые сообщения
+	g2, _ := New(cfg)
+	g2.now = g.now
+	var buf2 bytes.Buffer
+	Run(context.Background(), g2, &WriterSink{W: &buf2}, 0, 50)
+	if buf.String() != buf2.String() {
+		t.Error("expected the same output for the same seed")
+	}
+}
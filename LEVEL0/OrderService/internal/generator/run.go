@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"context"
+	"log/slog"
+	"orderservice/internal/logger"
+	"time"
+)
+
+// Stats counts messages sent by Run
+type Stats struct {
+	Sent   int            // успешно отправлено, включая некорректные
+	Broken map[string]int // отправлено некорректных по нарушенному правилу
+	Failed int            // не удалось отправить
+}
+
+// Run sends count messages(0 - until ctx is done) at rate messages per second(0 - as fast as the sink accepts).
+// Send errors are logged and do not stop the generator
+func Run(ctx context.Context, g *Generator, sink Sink, rate float64, count int) Stats {
+	stats := Stats{Broken: make(map[string]int)}
+	var tick <-chan time.Time
+	if rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for i := 0; count == 0 || i < count; i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return stats
+			}
+		} else if ctx.Err() != nil {
+			return stats
+		}
+
+		p := g.Next()
+		if err := sink.Send(ctx, []byte(p.Key), p.Value); err != nil {
+			if ctx.Err() != nil {
+				return stats
+			}
+			stats.Failed++
+			slog.ErrorContext(ctx, "Failed to send generated order", logger.KeyOrderUID, p.Key, logger.Err(err))
+			continue
+		}
+		stats.Sent++
+		if p.Rule != "" {
+			stats.Broken[p.Rule]++
+		}
+		slog.DebugContext(ctx, "Generated order sent", logger.KeyOrderUID, p.Key, "broken_rule", p.Rule)
+	}
+	return stats
+}
@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Sink delivers generated messages: Kafka topic(kafka.Producer), NDJSON stream or HTTP ingest endpoint
+type Sink interface {
+	Send(ctx context.Context, key, value []byte) error
+}
+
+// WriterSink writes one message per line, the output can be loaded with "orderservice replay"
+type WriterSink struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// Send writes value and a newline
+func (s *WriterSink) Send(ctx context.Context, key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.W.Write(value); err != nil {
+		return err
+	}
+	_, err := s.W.Write([]byte{'\n'})
+	return err
+}
+
+// HTTPSink posts messages to POST /api/v1/orders of a running service
+type HTTPSink struct {
+	URL    string
+	Client *http.Client // http.DefaultClient если nil
+}
+
+// Send posts value; rejection by validation(4xx) is expected for broken payloads and is not an error, 5xx is
+func (s *HTTPSink) Send(ctx context.Context, key, value []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(key) > 0 {
+		req.Header.Set("X-Correlation-ID", string(key))
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s: unexpected status %s", s.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// readRoles may call every method of the API: all of them only read orders
+var readRoles = []string{auth.RoleSupport, auth.RoleAnalytics, auth.RoleAdmin}
+
+// unaryAuth authenticates the call by "x-api-key" or "authorization: Bearer" metadata and checks the role
+func unaryAuth(A *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, A, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuth is unaryAuth for streaming calls
+func streamAuth(A *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), A, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize returns ctx with principal of the call or Unauthenticated/PermissionDenied status
+func authorize(ctx context.Context, A *auth.Authenticator, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := A.Authenticate(first(md.Get("x-api-key")), auth.BearerToken(first(md.Get("authorization"))))
+	if err != nil {
+		auth.AuditFailure(ctx, method, err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	ctx = auth.Authenticated(ctx, p)
+	if err := A.Authorize(ctx, method, readRoles...); err != nil {
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return ctx, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// authStream replaces context of the stream with the authenticated one
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (S *authStream) Context() context.Context {
+	return S.ctx
+}
@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"orderservice/internal/grpcapi/orderspb"
+	"orderservice/internal/model"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var updateTypes = map[string]orderspb.OrderUpdate_Type{
+	model.EventOrderCreated:   orderspb.OrderUpdate_TYPE_CREATED,
+	model.EventOrderUpdated:   orderspb.OrderUpdate_TYPE_UPDATED,
+	model.EventOrderCancelled: orderspb.OrderUpdate_TYPE_CANCELLED,
+}
+
+func filterFromProto(req *orderspb.ListOrdersRequest) model.OrderFilter {
+	filter := model.OrderFilter{
+		CustomerID:      req.GetCustomerId(),
+		TrackNumber:     req.GetTrackNumber(),
+		DeliveryService: req.GetDeliveryService(),
+		Provider:        req.GetProvider(),
+		Bank:            req.GetBank(),
+		Brand:           req.GetBrand(),
+		NMID:            uint(req.GetNmId()),
+		SortBy:          req.GetSortBy(),
+		SortDesc:        req.GetSortDesc(),
+		Limit:           int(req.GetPageSize()),
+		Cursor:          req.GetPageToken(),
+	}
+	if req.DateFrom != nil {
+		filter.DateFrom = req.GetDateFrom().AsTime()
+	}
+	if req.DateTo != nil {
+		filter.DateTo = req.GetDateTo().AsTime()
+	}
+	return filter
+}
+
+func updateToProto(update *model.OrderUpdate) *orderspb.OrderUpdate {
+	return &orderspb.OrderUpdate{Type: updateTypes[update.Type], Order: orderToProto(&update.Order)}
+}
+
+func orderToProto(o *model.Order) *orderspb.Order {
+	p := &orderspb.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderspb.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderspb.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       uint64(o.Payment.Amount),
+			PaymentDt:    uint64(o.Payment.PaymentDT),
+			Bank:         o.Payment.Bank,
+			DeliveryCost: uint64(o.Payment.DeliveryCost),
+			GoodsTotal:   uint64(o.Payment.GoodsTotal),
+			CustomFee:    uint64(o.Payment.CustomFee),
+		},
+		Items:             make([]*orderspb.Item, 0, len(o.Items)),
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SMID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		Version:           uint64(o.Version),
+	}
+	for _, item := range o.Items {
+		p.Items = append(p.Items, &orderspb.Item{
+			ChrtId:      uint64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       uint64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        uint64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  uint64(item.TotalPrice),
+			NmId:        uint64(item.NMID),
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		})
+	}
+	if o.CancelledAt != nil {
+		p.CancelledAt = timestamppb.New(*o.CancelledAt)
+	}
+	return p
+}
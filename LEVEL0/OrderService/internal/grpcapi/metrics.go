@@ -0,0 +1,28 @@
+package grpcapi
+
+import (
+	"context"
+	"orderservice/internal/metrics"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func unaryMetrics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observe(info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamMetrics(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observe(info.FullMethod, start, err)
+	return err
+}
+
+func observe(method string, start time.Time, err error) {
+	metrics.GRPCRequestDuration.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
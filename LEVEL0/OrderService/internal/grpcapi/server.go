@@ -0,0 +1,163 @@
+// Package grpcapi exposes orders over gRPC for internal consumers; the API is described in proto/orders/v1/orders.proto
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=orderspb --go_opt=paths=source_relative --go-grpc_out=orderspb --go-grpc_opt=paths=source_relative orders/v1/orders.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"orderservice/internal/auth"
+	"orderservice/internal/grpcapi/orderspb"
+	"orderservice/internal/model"
+	"orderservice/internal/service"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// OrderServer implements orderspb.OrderServiceServer on top of service.OrderService
+type OrderServer struct {
+	orderspb.UnimplementedOrderServiceServer
+	Service service.OrderService
+	// Done is closed when the server is shutting down, open WatchOrders streams are finished then
+	Done <-chan struct{}
+}
+
+// Server is gRPC server of orders API; like http.Server it is started with Serve and stopped with Shutdown
+type Server struct {
+	grpc     *grpc.Server
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewServer creates gRPC server with OrderService, metrics and auth interceptors and reflection(for grpcurl and similar tools).
+// With nil authenticator calls are not authenticated
+func NewServer(svc service.OrderService, authn *auth.Authenticator, opts ...grpc.ServerOption) *Server {
+	S := &Server{done: make(chan struct{})}
+	unary := []grpc.UnaryServerInterceptor{unaryMetrics}
+	stream := []grpc.StreamServerInterceptor{streamMetrics}
+	if authn != nil {
+		unary = append(unary, unaryAuth(authn))
+		stream = append(stream, streamAuth(authn))
+	}
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, opts...)
+	S.grpc = grpc.NewServer(opts...)
+	orderspb.RegisterOrderServiceServer(S.grpc, &OrderServer{Service: svc, Done: S.done})
+	reflection.Register(S.grpc)
+	return S
+}
+
+// Serve accepts connections on lis until Shutdown; returns nil after Shutdown
+func (S *Server) Serve(lis net.Listener) error {
+	err := S.grpc.Serve(lis)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown finishes WatchOrders streams, stops accepting connections and waits for running calls;
+// when ctx is done the remaining calls are cancelled
+func (S *Server) Shutdown(ctx context.Context) error {
+	S.stopOnce.Do(func() { close(S.done) })
+	stopped := make(chan struct{})
+	go func() {
+		S.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		S.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+// GetOrder returns order by its UID
+func (OS *OrderServer) GetOrder(ctx context.Context, req *orderspb.GetOrderRequest) (*orderspb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, err := OS.Service.GetOrderInfo(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return orderToProto(auth.Redact(ctx, order)), nil
+}
+
+// ListOrders returns a page of orders matching the request filter
+func (OS *OrderServer) ListOrders(ctx context.Context, req *orderspb.ListOrdersRequest) (*orderspb.ListOrdersResponse, error) {
+	page, err := OS.Service.ListOrders(ctx, filterFromProto(req))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &orderspb.ListOrdersResponse{
+		Orders:        make([]*orderspb.Order, 0, len(page.Orders)),
+		NextPageToken: page.NextCursor,
+	}
+	orders := auth.RedactAll(ctx, page.Orders)
+	for i := range orders {
+		resp.Orders = append(resp.Orders, orderToProto(&orders[i]))
+	}
+	return resp, nil
+}
+
+// WatchOrders streams orders created or changed by this instance until the client cancels the call or the server shuts down
+func (OS *OrderServer) WatchOrders(req *orderspb.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderspb.OrderUpdate]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-OS.Done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	filter := model.OrderFilter{
+		CustomerID:      req.GetCustomerId(),
+		TrackNumber:     req.GetTrackNumber(),
+		DeliveryService: req.GetDeliveryService(),
+	}
+	err := OS.Service.WatchOrders(ctx, filter, func(update *model.OrderUpdate) error {
+		masked := &model.OrderUpdate{Type: update.Type, Order: *auth.Redact(ctx, &update.Order)}
+		return stream.Send(updateToProto(masked))
+	})
+	switch {
+	case stream.Context().Err() != nil:
+		return status.FromContextError(stream.Context().Err()).Err()
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Unavailable, "server is shutting down")
+	default:
+		return toStatus(err)
+	}
+}
+
+// toStatus maps service errors to gRPC status codes
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, service.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidFilter), errors.Is(err, service.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, service.ErrWatchLagged):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
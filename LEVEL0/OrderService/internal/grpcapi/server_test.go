@@ -0,0 +1,235 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"orderservice/internal/auth"
+	"orderservice/internal/cache"
+	"orderservice/internal/grpcapi/orderspb"
+	"orderservice/internal/ingest"
+	"orderservice/internal/repository"
+	"orderservice/internal/service"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func orderJSON(uid, customerID string, day int) []byte {
+	return fmt.Appendf(nil, `{"order_uid":%q,"track_number":"T","entry":"WBIL","delivery":{"name":"A","phone":"+79040000000","zip":"1","city":"C","address":"A","region":"R","email":"e@example.com"},"payment":{"transaction":%[1]q,"request_id":"","currency":"USD","provider":"p","amount":1,"payment_dt":1637907727,"bank":"b","delivery_cost":1,"goods_total":1,"custom_fee":500},"items":[{"chrt_id":1,"track_number":"T","price":1,"rid":"r","name":"n","sale":0,"size":"s","total_price":1,"nm_id":1,"brand":"b","status":1}],"locale":"en","internal_signature":"","customer_id":%q,"delivery_service":"d","shardkey":"1","sm_id":1,"date_created":"2024-01-%02dT06:22:19Z","oof_shard":"1"}`, uid, customerID, day)
+}
+
+// startServer serves orders API over in-process bufconn and returns connected client
+func startServer(t *testing.T, authn *auth.Authenticator) (service.OrderService, *Server, orderspb.OrderServiceClient) {
+	t.Helper()
+	orderCache, err := cache.New(cache.Config{MaxEntries: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewOrderService(repository.NewMemoryRepository(), orderCache, nil)
+	srv := NewServer(svc, authn)
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return svc, srv, orderspb.NewOrderServiceClient(conn)
+}
+
+func ingestOrder(t *testing.T, svc service.OrderService, raw []byte) {
+	t.Helper()
+	if err := svc.Ingest(context.Background(), &ingest.Message{Value: raw}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	ctx := context.Background()
+	svc, _, client := startServer(t, nil)
+	ingestOrder(t, svc, orderJSON("u1", "c1", 1))
+
+	order, err := client.GetOrder(ctx, &orderspb.GetOrderRequest{OrderUid: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.GetCustomerId() != "c1" || order.GetPayment().GetCustomFee() != 500 || len(order.GetItems()) != 1 || order.GetItems()[0].GetBrand() != "b" {
+		t.Errorf("unexpected order %v", order)
+	}
+
+	for _, tc := range []struct {
+		uid  string
+		code codes.Code
+	}{
+		{"missing", codes.NotFound},
+		{"", codes.InvalidArgument},
+	} {
+		_, err := client.GetOrder(ctx, &orderspb.GetOrderRequest{OrderUid: tc.uid})
+		if status.Code(err) != tc.code {
+			t.Errorf("uid %q: expected %s, got %v", tc.uid, tc.code, err)
+		}
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	ctx := context.Background()
+	svc, _, client := startServer(t, nil)
+	for i, uid := range []string{"a", "b", "c"} {
+		ingestOrder(t, svc, orderJSON(uid, "c1", i+1))
+	}
+	ingestOrder(t, svc, orderJSON("other", "c2", 4))
+
+	var uids []string
+	req := &orderspb.ListOrdersRequest{CustomerId: "c1", PageSize: 2}
+	for {
+		resp, err := client.ListOrders(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range resp.GetOrders() {
+			uids = append(uids, o.GetOrderUid())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if fmt.Sprint(uids) != "[c b a]" {
+		t.Errorf("expected customer orders newest first, got %v", uids)
+	}
+
+	_, err := client.ListOrders(ctx, &orderspb.ListOrdersRequest{SortBy: "price"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for unsupported sort, got %v", err)
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	svc, srv, client := startServer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchOrders(ctx, &orderspb.WatchOrdersRequest{CustomerId: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// подписка на сервере оформляется асинхронно: создаем заказы, пока первый из них не придет в поток
+	stopIngest := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stopIngest:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			svc.Ingest(ctx, &ingest.Message{Value: orderJSON(fmt.Sprintf("other-%d", i), "c2", 1)})
+			svc.Ingest(ctx, &ingest.Message{Value: orderJSON(fmt.Sprintf("mine-%d", i), "c1", 1)})
+		}
+	}()
+	update, err := stream.Recv()
+	close(stopIngest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetType() != orderspb.OrderUpdate_TYPE_CREATED || update.GetOrder().GetCustomerId() != "c1" {
+		t.Errorf("unexpected update %v", update)
+	}
+
+	// остановка сервера завершает поток, не дожидаясь клиента
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected watch streams to be finished on shutdown")
+	}
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable after shutdown, got %v", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	keys, err := auth.ParseAPIKeys("crm:support:sk,bi:analytics:ak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, _, client := startServer(t, &auth.Authenticator{Keys: keys})
+	ingestOrder(t, svc, orderJSON("u1", "c1", 1))
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	if _, err := client.GetOrder(context.Background(), &orderspb.GetOrderRequest{OrderUid: "u1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if _, err := client.GetOrder(withKey("nope"), &orderspb.GetOrderRequest{OrderUid: "u1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	order, err := client.GetOrder(withKey("sk"), &orderspb.GetOrderRequest{OrderUid: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.GetDelivery().GetPhone() != "+79040000000" {
+		t.Errorf("support must see full phone, got %q", order.GetDelivery().GetPhone())
+	}
+
+	resp, err := client.ListOrders(withKey("ak"), &orderspb.ListOrdersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetOrders()) != 1 || resp.GetOrders()[0].GetDelivery().GetPhone() != "+7********00" || resp.GetOrders()[0].GetDelivery().GetCity() != "C" {
+		t.Errorf("analytics must see masked delivery, got %v", resp.GetOrders())
+	}
+
+	stream, err := client.WatchOrders(context.Background(), &orderspb.WatchOrdersRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for watch, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(withKey("ak"), 5*time.Second)
+	defer cancel()
+	stream, err = client.WatchOrders(ctx, &orderspb.WatchOrdersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopIngest := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stopIngest:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			svc.Ingest(ctx, &ingest.Message{Value: orderJSON(fmt.Sprintf("w-%d", i), "c1", 1)})
+		}
+	}()
+	update, err := stream.Recv()
+	close(stopIngest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetOrder().GetDelivery().GetPhone() != "+7********00" {
+		t.Errorf("analytics must see masked delivery in updates, got %v", update.GetOrder().GetDelivery())
+	}
+}
@@ -0,0 +1,170 @@
+// Package health aggregates readiness checks of service dependencies and startup steps
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported in Report and CheckResult
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports state of a single dependency; nil means healthy
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks; Status is ok only if every check passed
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name    string
+	check   Check
+	startup bool // только для Wait, в Run не входит
+}
+
+// Checker runs registered checks concurrently, each limited by timeout
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewChecker - returns Checker with per-check timeout(2s if not positive)
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Add registers check under name; checks with the same name are replaced
+func (c *Checker) Add(name string, check Check) {
+	c.add(namedCheck{name: name, check: check})
+}
+
+// AddStartup registers check used only to sequence startup with Wait; it is not part of Run, so a condition
+// which may fail for a while in normal operation(e.g. consumer group rebalancing) does not fail readiness
+func (c *Checker) AddStartup(name string, check Check) {
+	c.add(namedCheck{name: name, check: check, startup: true})
+}
+
+func (c *Checker) add(nc namedCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.checks {
+		if c.checks[i].name == nc.name {
+			c.checks[i] = nc
+			return
+		}
+	}
+	c.checks = append(c.checks, nc)
+}
+
+// Run executes all checks except startup ones and returns aggregated report
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	var checks []namedCheck
+	for _, nc := range c.checks {
+		if !nc.startup {
+			checks = append(checks, nc)
+		}
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, nc.check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, nc := range checks {
+		report.Checks[nc.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// Wait blocks until check registered under name passes, polling it every interval; returns error if ctx is done first
+func (c *Checker) Wait(ctx context.Context, name string, interval time.Duration) error {
+	check, ok := c.lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown health check %q", name)
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		res := c.run(ctx, check)
+		if res.Status == StatusOK {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s is not ready: %s: %w", name, res.Error, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Checker) lookup(name string) (Check, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, nc := range c.checks {
+		if nc.name == name {
+			return nc.check, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := check(ctx); err != nil {
+		return CheckResult{Status: StatusFail, Error: err.Error()}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+// ErrNotReady is reported by Flag until it is set
+var ErrNotReady = errors.New("not ready yet")
+
+// Flag is a one-way readiness condition for startup steps such as cache warm-up or template loading
+type Flag struct {
+	ready atomic.Bool
+}
+
+// Set marks the step as completed
+func (f *Flag) Set() {
+	f.ready.Store(true)
+}
+
+// Check implements Check: returns ErrNotReady until Set is called
+func (f *Flag) Check(context.Context) error {
+	if !f.ready.Load() {
+		return ErrNotReady
+	}
+	return nil
+}
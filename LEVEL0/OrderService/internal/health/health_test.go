@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunAggregatesChecks(t *testing.T) {
+	var flag Flag
+	c := NewChecker(time.Second)
+	c.Add("db", func(context.Context) error { return nil })
+	c.Add("broker", func(context.Context) error { return errors.New("connection refused") })
+	c.Add("warmup", flag.Check)
+
+	report := c.Run(context.Background())
+	if report.Status != StatusFail {
+		t.Fatalf("expected fail, got %s", report.Status)
+	}
+	if got := report.Checks["db"].Status; got != StatusOK {
+		t.Errorf("db: expected ok, got %s", got)
+	}
+	if got := report.Checks["broker"]; got.Status != StatusFail || got.Error != "connection refused" {
+		t.Errorf("broker: unexpected result %+v", got)
+	}
+	if got := report.Checks["warmup"].Error; got != ErrNotReady.Error() {
+		t.Errorf("warmup: expected %q, got %q", ErrNotReady, got)
+	}
+
+	c.Add("broker", func(context.Context) error { return nil })
+	flag.Set()
+	if report := c.Run(context.Background()); report.Status != StatusOK || len(report.Checks) != 3 {
+		t.Fatalf("expected 3 passing checks, got %+v", report)
+	}
+
+	// проверки запуска не влияют на readiness
+	c.AddStartup("rebalancing", func(context.Context) error { return errors.New("group is rebalancing") })
+	if report := c.Run(context.Background()); report.Status != StatusOK || len(report.Checks) != 3 {
+		t.Fatalf("startup check must not be reported, got %+v", report)
+	}
+}
+
+func TestRunAppliesTimeout(t *testing.T) {
+	c := NewChecker(10 * time.Millisecond)
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if report := c.Run(context.Background()); report.Checks["slow"].Status != StatusFail {
+		t.Fatalf("expected slow check to fail by timeout, got %+v", report)
+	}
+}
+
+func TestWait(t *testing.T) {
+	var calls atomic.Int32
+	c := NewChecker(time.Second)
+	c.Add("broker", func(context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err := c.Wait(context.Background(), "broker", time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c.Add("never", func(context.Context) error { return errors.New("down") })
+	if err := c.Wait(ctx, "never", time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	c.AddStartup("group", func(context.Context) error { return nil })
+	if err := c.Wait(ctx, "group", time.Millisecond); err != nil {
+		t.Fatalf("expected startup check to be waited for, got %v", err)
+	}
+	if err := c.Wait(ctx, "missing", time.Millisecond); err == nil {
+		t.Fatal("expected error for unknown check")
+	}
+}
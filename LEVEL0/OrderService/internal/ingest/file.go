@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// maxLineSize limits a single NDJSON line
+const maxLineSize = 4 << 20
+
+// FileSource replays NDJSON(one order or event per line, the format of mocks.json) into handler in batches
+type FileSource struct {
+	Reader    io.Reader
+	Name      string // имя файла для логов и InvalidRequests/DLQ
+	BatchSize int    // по умолчанию 100
+}
+
+// Run reads messages until EOF; blank lines are skipped, line number is used as message offset.
+// Returns error if reading fails or handler reports transient failure - lines before the failed batch are already processed
+func (s *FileSource) Run(ctx context.Context, h Handler) error {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	scanner := bufio.NewScanner(s.Reader)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	var (
+		batch    = make([]Message, 0, batchSize)
+		line     int64
+		messages int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := h.ProcessBatch(ctx, batch); err != nil {
+			return fmt.Errorf("%s: lines %d-%d: %w", s.Name, batch[0].Offset, batch[len(batch)-1].Offset, err)
+		}
+		messages += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		value := bytes.TrimSpace(scanner.Bytes())
+		if len(value) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg := Message{
+			Source: SourceFile,
+			Value:  bytes.Clone(value),
+			Topic:  s.Name,
+			Offset: line,
+		}
+		msg.EnsureCorrelationID()
+		batch = append(batch, msg)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: line %d: %w", s.Name, line+1, err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "File replayed", "file", s.Name, "messages", messages)
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package ingest defines transport-neutral messages and sources delivering them to the order pipeline
+package ingest
+
+import (
+	"context"
+	"orderservice/internal/logger"
+)
+
+// Transports messages come from
+const (
+	SourceKafka = "kafka"
+	SourceHTTP  = "http"
+	SourceFile  = "file"
+	//повтор отклоненного сообщения из InvalidRequests через admin API
+	SourceReplay = "replay"
+)
+
+// HeaderCorrelationID is the message header carrying correlation ID between services
+const HeaderCorrelationID = "x-correlation-id"
+
+// Message is a raw order or order event received from any transport
+type Message struct {
+	Source  string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+	// Координаты сообщения в источнике для логов и DLQ: для Kafka - топик, партиция и смещение, для файла - имя и номер строки
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// Handler processes messages; rejected messages are stored for later replay, so error is returned only on transient failure
+// and the whole batch may be delivered again
+type Handler interface {
+	ProcessBatch(ctx context.Context, msgs []Message) error
+}
+
+// Source delivers messages to handler until ctx is done or the source is exhausted
+type Source interface {
+	Run(ctx context.Context, h Handler) error
+}
+
+// EnsureCorrelationID sets x-correlation-id header(message key or a new ID) if it is missing,
+// so that all processing stages and DLQ see the same ID; returns the ID
+func (m *Message) EnsureCorrelationID() string {
+	if id := m.Headers[HeaderCorrelationID]; id != "" {
+		return id
+	}
+	id := string(m.Key)
+	if id == "" {
+		id = logger.NewCorrelationID()
+	}
+	if m.Headers == nil {
+		m.Headers = make(map[string]string, 1)
+	}
+	m.Headers[HeaderCorrelationID] = id
+	return id
+}
+
+// Context returns context for processing of the message: correlation ID is taken from x-correlation-id header,
+// message key or generated; source and position are attached as log fields
+func (m *Message) Context(ctx context.Context) context.Context {
+	id := m.Headers[HeaderCorrelationID]
+	if id == "" {
+		id = string(m.Key)
+	}
+	if id == "" {
+		id = logger.NewCorrelationID()
+	}
+	ctx = logger.WithCorrelationID(ctx, id)
+	return logger.With(ctx, "source", m.Source, logger.KeyPartition, m.Partition, logger.KeyOffset, m.Offset)
+}
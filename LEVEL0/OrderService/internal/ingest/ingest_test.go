@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/logger"
+	"strings"
+	"testing"
+)
+
+type recordingHandler struct {
+	batches [][]Message
+	err     error
+}
+
+func (h *recordingHandler) ProcessBatch(ctx context.Context, msgs []Message) error {
+	h.batches = append(h.batches, append([]Message(nil), msgs...))
+	return h.err
+}
+
+func TestMessageCorrelation(t *testing.T) {
+	msg := Message{Key: []byte("uid-1"), Partition: 2, Offset: 10}
+	if id := msg.EnsureCorrelationID(); id != "uid-1" {
+		t.Errorf("expected key as correlation ID, got %q", id)
+	}
+	if msg.Headers[HeaderCorrelationID] != "uid-1" {
+		t.Errorf("expected ID to be stored in header, got %v", msg.Headers)
+	}
+
+	keyless := Message{}
+	id := keyless.EnsureCorrelationID()
+	if id == "" || logger.CorrelationID(keyless.Context(context.Background())) != id {
+		t.Errorf("generated ID must be kept in header, got %q", id)
+	}
+
+	withHeader := Message{Key: []byte("uid-2"), Headers: map[string]string{HeaderCorrelationID: "trace-7"}}
+	if got := logger.CorrelationID(withHeader.Context(context.Background())); got != "trace-7" {
+		t.Errorf("header must take precedence over key, got %q", got)
+	}
+}
+
+func TestFileSource_Batches(t *testing.T) {
+	input := "{\"a\":1}\n\n{\"a\":2}\n{\"a\":3}\n"
+	h := &recordingHandler{}
+	src := &FileSource{Reader: strings.NewReader(input), Name: "mocks.json", BatchSize: 2}
+
+	if err := src.Run(context.Background(), h); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(h.batches) != 2 || len(h.batches[0]) != 2 || len(h.batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", h.batches)
+	}
+	// пустая строка пропускается, но учитывается в нумерации
+	last := h.batches[1][0]
+	if string(last.Value) != `{"a":3}` || last.Offset != 4 || last.Source != SourceFile || last.Topic != "mocks.json" {
+		t.Errorf("unexpected message: %+v", last)
+	}
+	if last.Headers[HeaderCorrelationID] == "" {
+		t.Error("expected correlation ID to be set")
+	}
+}
+
+func TestFileSource_StopsOnHandlerError(t *testing.T) {
+	h := &recordingHandler{err: errors.New("connection refused")}
+	src := &FileSource{Reader: strings.NewReader("{}\n{}\n{}\n"), Name: "in", BatchSize: 2}
+
+	err := src.Run(context.Background(), h)
+	if err == nil || !strings.Contains(err.Error(), "lines 1-2") {
+		t.Fatalf("expected error with failed lines range, got %v", err)
+	}
+	if len(h.batches) != 1 {
+		t.Errorf("expected processing to stop after the failed batch, got %d batches", len(h.batches))
+	}
+}
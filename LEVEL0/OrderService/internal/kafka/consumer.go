@@ -2,30 +2,224 @@ package kafka
 
 import (
 	"context"
-	"log"
-	"orderservice/internal/service"
+	"log/slog"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/resilience"
+	"strconv"
 	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
 )
 
-// StartConsumer initializes listening to Kafka messages, which will be forwarded to Service-layer
-func StartConsumer(ctx context.Context, srv service.OrderService, broker, topic string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	reader := NewKafkaReader(broker, topic)
+// ConsumerConfig describes consumer parallelism, batching and retry policy
+type ConsumerConfig struct {
+	Broker          string
+	Topic           string
+	Workers         int           // количество обработчиков; все сообщения одной партиции попадают к одному обработчику
+	BatchSize       int           // максимальное количество сообщений в одной транзакции
+	BatchTimeout    time.Duration // сколько ждать заполнения пачки, прежде чем обработать неполную
+	RetryBackoff    time.Duration // начальная пауза между повторами
+	MaxRetryBackoff time.Duration // максимальная пауза между повторами
+}
+
+// committer is a part of kafka.Reader used by workers
+type committer interface {
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Consumer reads orders from Kafka topic and forwards them to ingest.Handler in batches, implements ingest.Source
+type Consumer struct {
+	cfg ConsumerConfig
+}
+
+// NewConsumer returns consumer of the configured topic; it joins consumer group only when Run is called
+func NewConsumer(cfg ConsumerConfig) *Consumer {
+	return &Consumer{cfg: cfg}
+}
+
+// Run consumes messages until ctx is done. Offsets are committed only after the batch is processed,
+// transient failures are retried with exponential backoff
+func (C *Consumer) Run(ctx context.Context, h ingest.Handler) error {
+	cfg := C.cfg
+	reader := NewKafkaReader(cfg.Broker, cfg.Topic)
 	defer reader.Close()
 
+	workers := make([]chan kafka.Message, max(cfg.Workers, 1))
+	var workersWG sync.WaitGroup
+	for i := range workers {
+		workers[i] = make(chan kafka.Message, max(cfg.BatchSize, 1))
+		workersWG.Add(1)
+		go func(in <-chan kafka.Message) {
+			defer workersWG.Done()
+			runWorker(ctx, h, reader, cfg, in)
+		}(workers[i])
+	}
+	defer func() {
+		for _, in := range workers {
+			close(in)
+		}
+		workersWG.Wait()
+		slog.Info("Kafka consumer stopped")
+	}()
+
+	backoff := newBackoff(cfg.RetryBackoff, cfg.MaxRetryBackoff)
 	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			delay := backoff.next()
+			slog.WarnContext(ctx, "Kafka read error, retrying", logger.Err(err), "delay", delay)
+			if !resilience.Sleep(ctx, delay) {
+				return nil
+			}
+			continue
+		}
+		backoff.reset()
+		if slog.Default().Enabled(ctx, slog.LevelDebug) {
+			slog.DebugContext(ctx, "Message fetched", logger.KeyPartition, msg.Partition, logger.KeyOffset, msg.Offset)
+		}
+		metrics.KafkaMessagesConsumed.Inc()
+		//HighWaterMark - offset следующего сообщения, которое будет записано в партицию
+		metrics.KafkaConsumerLag.WithLabelValues(strconv.Itoa(msg.Partition)).Set(float64(max(msg.HighWaterMark-msg.Offset-1, 0)))
+
 		select {
+		case workers[msg.Partition%len(workers)] <- msg:
 		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// runWorker accumulates messages into batches, processes them and commits offsets after successful processing
+func runWorker(ctx context.Context, h ingest.Handler, c committer, cfg ConsumerConfig, in <-chan kafka.Message) {
+	batchSize := max(cfg.BatchSize, 1)
+	batch := make([]kafka.Message, 0, batchSize)
+	timer := time.NewTimer(cfg.BatchTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
 			return
-		default:
-			msg, err := reader.ReadMessage(ctx)
-			if err != nil {
-				log.Printf("Kafka read error: %v", err)
-				continue
+		}
+		if processWithRetry(ctx, h, cfg, batch) {
+			commit(ctx, c, batch)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				flush()
+				return
 			}
-			srv.AddNewOrder(&msg)
-			reader.CommitMessages(ctx, msg)
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				timer.Reset(cfg.BatchTimeout)
+			}
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// processWithRetry retries the batch until it is processed or consumer is stopped; returns true if batch may be committed
+func processWithRetry(ctx context.Context, h ingest.Handler, cfg ConsumerConfig, batch []kafka.Message) bool {
+	//начатая обработка доводится до конца даже при остановке, прерываются только паузы между повторами
+	processCtx := context.WithoutCancel(ctx)
+	msgs := make([]ingest.Message, len(batch))
+	for i := range batch {
+		msgs[i] = toIngestMessage(batch[i])
+	}
+	backoff := newBackoff(cfg.RetryBackoff, cfg.MaxRetryBackoff)
+	for attempt := 1; ; attempt++ {
+		err := h.ProcessBatch(processCtx, msgs)
+		if err == nil {
+			return true
 		}
+		delay := backoff.next()
+		slog.WarnContext(ctx, "Failed to process batch, retrying", batchAttrs(batch), "attempt", attempt, logger.Err(err), "delay", delay)
+		if !resilience.Sleep(ctx, delay) {
+			slog.WarnContext(ctx, "Consumer stopped, batch is left uncommitted and will be redelivered", batchAttrs(batch))
+			return false
+		}
+	}
+}
+
+// toIngestMessage converts Kafka message; correlation ID is fixed once so that retries and DLQ see the same ID
+func toIngestMessage(msg kafka.Message) ingest.Message {
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	m := ingest.Message{
+		Source:    ingest.SourceKafka,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+	m.EnsureCorrelationID()
+	return m
+}
 
+func commit(ctx context.Context, c committer, batch []kafka.Message) {
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := c.CommitMessages(commitCtx, batch...); err != nil {
+		// сообщения будут доставлены повторно, обработка идемпотентна
+		slog.ErrorContext(ctx, "Failed to commit offsets", batchAttrs(batch), logger.Err(err))
 	}
 }
+
+// batchAttrs describes batch in logs by its size and offsets range
+func batchAttrs(batch []kafka.Message) slog.Attr {
+	return slog.Group("batch",
+		"size", len(batch),
+		"first_partition", batch[0].Partition,
+		"first_offset", batch[0].Offset,
+		"last_partition", batch[len(batch)-1].Partition,
+		"last_offset", batch[len(batch)-1].Offset,
+	)
+}
+
+// backoff produces exponentially growing delays capped by max
+type backoff struct {
+	initial, max, current time.Duration
+}
+
+func newBackoff(initial, maxDelay time.Duration) *backoff {
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if maxDelay < initial {
+		maxDelay = initial
+	}
+	return &backoff{initial: initial, max: maxDelay}
+}
+
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.initial
+	} else {
+		b.current = min(b.current*2, b.max)
+	}
+	return b.current
+}
+
+func (b *backoff) reset() {
+	b.current = 0
+}
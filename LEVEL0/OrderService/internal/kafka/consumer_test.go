@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/ingest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeHandler struct {
+	mu       sync.Mutex
+	failures int // сколько первых вызовов ProcessBatch вернут ошибку
+	batches  [][]ingest.Message
+}
+
+func (f *fakeHandler) ProcessBatch(ctx context.Context, msgs []ingest.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]ingest.Message(nil), msgs...))
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+type fakeCommitter struct {
+	mu        sync.Mutex
+	committed []int64
+}
+
+func (f *fakeCommitter) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range msgs {
+		f.committed = append(f.committed, m.Offset)
+	}
+	return nil
+}
+
+func testConfig() ConsumerConfig {
+	return ConsumerConfig{BatchSize: 2, BatchTimeout: 20 * time.Millisecond, RetryBackoff: time.Millisecond, MaxRetryBackoff: 4 * time.Millisecond}
+}
+
+func TestRunWorker_BatchesAndCommitsAfterSuccess(t *testing.T) {
+	srv := &fakeHandler{failures: 2}
+	c := &fakeCommitter{}
+	in := make(chan kafka.Message, 10)
+	for i := range 3 {
+		in <- kafka.Message{Offset: int64(i)}
+	}
+	close(in)
+
+	runWorker(context.Background(), srv, c, testConfig(), in)
+
+	// первая пачка (2 сообщения) дважды падает и проходит с третьей попытки, остаток дописывается при закрытии канала
+	if len(srv.batches) != 4 || len(srv.batches[0]) != 2 || len(srv.batches[3]) != 1 {
+		t.Fatalf("unexpected batches: %d", len(srv.batches))
+	}
+	if len(c.committed) != 3 || c.committed[0] != 0 || c.committed[2] != 2 {
+		t.Fatalf("expected all offsets committed in order, got %v", c.committed)
+	}
+}
+
+func TestRunWorker_FlushesByTimeout(t *testing.T) {
+	srv := &fakeHandler{}
+	c := &fakeCommitter{}
+	in := make(chan kafka.Message, 10)
+	done := make(chan struct{})
+	go func() {
+		runWorker(context.Background(), srv, c, testConfig(), in)
+		close(done)
+	}()
+
+	in <- kafka.Message{Offset: 7}
+	time.Sleep(100 * time.Millisecond)
+	c.mu.Lock()
+	committed := append([]int64(nil), c.committed...)
+	c.mu.Unlock()
+	if len(committed) != 1 || committed[0] != 7 {
+		t.Fatalf("expected incomplete batch to be flushed by timeout, got %v", committed)
+	}
+	close(in)
+	<-done
+}
+
+func TestRunWorker_NoCommitWhenStopped(t *testing.T) {
+	srv := &fakeHandler{failures: 1000}
+	c := &fakeCommitter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan kafka.Message, 10)
+	in <- kafka.Message{Offset: 1}
+	close(in)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	runWorker(ctx, srv, c, testConfig(), in)
+
+	if len(c.committed) != 0 {
+		t.Fatalf("failed batch must not be committed, got %v", c.committed)
+	}
+}
+
+func TestRunWorker_KeepsCorrelationIDAcrossRetries(t *testing.T) {
+	srv := &fakeHandler{failures: 1}
+	in := make(chan kafka.Message, 1)
+	in <- kafka.Message{Topic: "orders", Partition: 3, Offset: 5, Headers: []kafka.Header{{Key: "trace", Value: []byte("t1")}}}
+	close(in)
+
+	runWorker(context.Background(), srv, &fakeCommitter{}, testConfig(), in)
+
+	if len(srv.batches) != 2 {
+		t.Fatalf("expected one retry, got %d batches", len(srv.batches))
+	}
+	first, second := srv.batches[0][0], srv.batches[1][0]
+	if first.Source != ingest.SourceKafka || first.Topic != "orders" || first.Partition != 3 || first.Offset != 5 || first.Headers["trace"] != "t1" {
+		t.Errorf("unexpected converted message: %+v", first)
+	}
+	id := first.Headers[ingest.HeaderCorrelationID]
+	if id == "" || second.Headers[ingest.HeaderCorrelationID] != id {
+		t.Errorf("correlation ID must be generated once, got %q and %q", id, second.Headers[ingest.HeaderCorrelationID])
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(10*time.Millisecond, 35*time.Millisecond)
+	want := []time.Duration{10, 20, 35, 35}
+	for i, w := range want {
+		if got := b.next(); got != w*time.Millisecond {
+			t.Fatalf("step %d: got %v, want %v", i, got, w*time.Millisecond)
+		}
+	}
+	b.reset()
+	if got := b.next(); got != 10*time.Millisecond {
+		t.Fatalf("after reset got %v", got)
+	}
+}
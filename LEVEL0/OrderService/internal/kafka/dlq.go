@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"context"
+	"orderservice/internal/ingest"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers attached to every message published to DLQ
+const (
+	HeaderError           = "x-error"
+	HeaderOriginSource    = "x-origin-source"
+	HeaderOriginTopic     = "x-origin-topic"
+	HeaderOriginPartition = "x-origin-partition"
+	HeaderOriginOffset    = "x-origin-offset"
+	HeaderRejectedAt      = "x-rejected-at"
+)
+
+// DLQPublisher publishes rejected messages into dead-letter topic, implements service.DeadLetterPublisher
+type DLQPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewDLQPublisher returns publisher writing into the given DLQ topic
+func NewDLQPublisher(broker, topic string) *DLQPublisher {
+	return &DLQPublisher{writer: NewKafkaWriter(broker, topic)}
+}
+
+// PublishDeadLetter sends original message key and value to DLQ, the rejection reason and origin coordinates are passed in headers;
+// for messages not from Kafka the origin topic is the file name or empty
+func (p *DLQPublisher) PublishDeadLetter(ctx context.Context, msg *ingest.Message, reason error) error {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	for k, v := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	headers = append(headers,
+		kafka.Header{Key: HeaderError, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderOriginSource, Value: []byte(msg.Source)},
+		kafka.Header{Key: HeaderOriginTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderOriginPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderOriginOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderRejectedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
+
+// Close flushes and closes underlying writer
+func (p *DLQPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/health"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ConsumerGroupID is the consumer group shared by all instances of the service
+const ConsumerGroupID = "order-service"
+
+// clientID identifies this process among consumer group members
+var clientID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("order-service-%s-%d", host, os.Getpid())
+}()
+
+// BrokerCheck returns health check which succeeds if broker accepts TCP connections and answers metadata request
+func BrokerCheck(broker string) health.Check {
+	return func(ctx context.Context) error {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.Brokers()
+		return err
+	}
+}
+
+// ConsumerGroupCheck returns health check which succeeds if this process is a member of the consumer group
+// and the group has finished rebalancing
+func ConsumerGroupCheck(broker string) health.Check {
+	client := &kafka.Client{Addr: kafka.TCP(broker), Timeout: 5 * time.Second}
+	return func(ctx context.Context) error {
+		resp, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{
+			Addr:     client.Addr,
+			GroupIDs: []string{ConsumerGroupID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Groups) == 0 {
+			return fmt.Errorf("consumer group %q not found", ConsumerGroupID)
+		}
+		group := resp.Groups[0]
+		if group.Error != nil {
+			return group.Error
+		}
+		if group.GroupState != "Stable" {
+			return fmt.Errorf("consumer group %q is %s", ConsumerGroupID, group.GroupState)
+		}
+		joined := slices.ContainsFunc(group.Members, func(m kafka.DescribeGroupsResponseMember) bool {
+			return m.ClientID == clientID
+		})
+		if !joined {
+			return fmt.Errorf("consumer %s has not joined group %q", clientID, ConsumerGroupID)
+		}
+		return nil
+	}
+}
@@ -8,13 +8,13 @@ import (
 	"gorm.io/gorm"
 )
 
-// Kafka provides db-connection and access for managing cache-map
+// Kafka provides db-connection and access for managing cache
 type Kafka struct {
-	DB  *gorm.DB
-	Map *cache.OrderMap
+	DB    *gorm.DB
+	Cache cache.OrderCache
 }
 
-// NewKafkaWriter returns new Kafka writer in order to emulate messages from it
+// NewKafkaWriter returns new synchronous Kafka writer
 func NewKafkaWriter(broker, topic string) *kafka.Writer {
 	return kafka.NewWriter(kafka.WriterConfig{
 		Brokers:      []string{broker},
@@ -30,10 +30,15 @@ func NewKafkaReader(broker, topic string) *kafka.Reader {
 	return kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     []string{broker},
 		Topic:       topic,
-		GroupID:     "order-service",
+		GroupID:     ConsumerGroupID,
 		MinBytes:    10e3,
 		MaxBytes:    10e6,
 		StartOffset: kafka.FirstOffset,
 		MaxWait:     1 * time.Second,
+		Dialer: &kafka.Dialer{ //ClientID нужен, чтобы проверка готовности нашла этот процесс среди участников группы
+			ClientID:  clientID,
+			Timeout:   10 * time.Second,
+			DualStack: true,
+		},
 	})
 }
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers attached to every message published from the outbox
+const (
+	HeaderEventType = "x-event-type"
+	HeaderOutboxID  = "x-outbox-id"
+)
+
+// OutboxPublisher publishes outbox messages keyed by order_uid, implements outbox.Publisher.
+// Messages of one order go to one partition; the broker acknowledges a message only after all in-sync replicas got it
+type OutboxPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewOutboxPublisher returns publisher writing into the given topic
+func NewOutboxPublisher(broker, topic string) *OutboxPublisher {
+	return &OutboxPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(broker),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond, //сообщения передаются одним вызовом, ждать добора пачки не нужно
+	}}
+}
+
+// Publish writes all messages in one call; kafka.WriteErrors is converted to per-message errors
+func (p *OutboxPublisher) Publish(ctx context.Context, msgs []model.OutboxMessage) []error {
+	out := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		out[i] = kafka.Message{
+			Key:   []byte(msg.AggregateID),
+			Value: []byte(msg.Payload),
+			Headers: []kafka.Header{
+				{Key: HeaderEventType, Value: []byte(msg.EventType)},
+				{Key: HeaderOutboxID, Value: []byte(strconv.FormatUint(msg.ID, 10))},
+			},
+		}
+	}
+	err := p.writer.WriteMessages(ctx, out...)
+	if err == nil {
+		return nil
+	}
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == len(msgs) {
+		return writeErrs
+	}
+	errs := make([]error, len(msgs))
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Close flushes and closes underlying writer
+func (p *OutboxPublisher) Close() error {
+	return p.writer.Close()
+}
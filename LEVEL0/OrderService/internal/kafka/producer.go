@@ -0,0 +1,27 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Producer publishes messages into a topic, implements generator.Sink
+type Producer struct {
+	writer *kafka.Writer
+}
+
+// NewProducer returns producer writing into the given topic
+func NewProducer(broker, topic string) *Producer {
+	return &Producer{writer: NewKafkaWriter(broker, topic)}
+}
+
+// Send publishes a single message and waits for the broker acknowledgement
+func (p *Producer) Send(ctx context.Context, key, value []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+}
+
+// Close flushes and closes underlying writer
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
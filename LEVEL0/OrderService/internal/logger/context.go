@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Header names used to pass correlation ID between services
+const (
+	HeaderRequestID     = "X-Request-ID"
+	HeaderCorrelationID = "X-Correlation-ID"
+)
+
+// HTTPMiddleware takes correlation ID from X-Request-ID/X-Correlation-ID header or generates a new one,
+// returns it in X-Request-ID response header and logs every finished request
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if id == "" {
+			id = r.Header.Get(HeaderCorrelationID)
+		}
+		if id == "" {
+			id = NewCorrelationID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		ctx := WithCorrelationID(r.Context(), id)
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "HTTP request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration", time.Since(start),
+			"bytes", ww.BytesWritten(),
+		)
+	})
+}
@@ -0,0 +1,122 @@
+// Package logger configures log/slog and carries correlation ID and other fields through context
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Field names shared by all packages
+const (
+	KeyCorrelationID = "correlation_id"
+	KeyOrderUID      = "order_uid"
+	KeyPartition     = "partition"
+	KeyOffset        = "offset"
+	KeyError         = "error"
+	KeySubject       = "subject" //аутентифицированный клиент: имя API-ключа или sub из JWT
+	KeyRole          = "role"
+)
+
+type (
+	attrsKey       struct{}
+	correlationKey struct{}
+)
+
+// Setup creates logger with given level(debug, info, warn, error) and format(json, text),
+// makes it the default one for slog and the standard log package
+func Setup(level, format string) (*slog.Logger, error) {
+	return SetupWriter(os.Stdout, level, format)
+}
+
+// SetupWriter is Setup writing to w; subcommands whose output goes to stdout log to stderr
+func SetupWriter(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: expected json or text", format)
+	}
+	l := slog.New(contextHandler{h})
+	slog.SetDefault(l)
+	return l, nil
+}
+
+// Fatal logs message with error level and exits the process
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Err returns error attribute
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// With returns context whose log records will include args(key-value pairs or slog.Attr)
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	attrs := make([]slog.Attr, len(prev), len(prev)+len(args))
+	copy(attrs, prev)
+	//Record используется только для разбора args в атрибуты по правилам slog
+	var r slog.Record
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// WithCorrelationID returns context carrying correlation ID; it is added to every log record made with this context
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, correlationKey{}, id)
+	return With(ctx, KeyCorrelationID, id)
+}
+
+// CorrelationID returns correlation ID from ctx or empty string
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// NewCorrelationID generates random 16-byte hex identifier
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextHandler adds attributes stored in context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
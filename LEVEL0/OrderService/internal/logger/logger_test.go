@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("invalid JSON log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSetup(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	if _, err := SetupWriter(&bytes.Buffer{}, "verbose", "json"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, err := SetupWriter(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+
+	var buf bytes.Buffer
+	l, err := SetupWriter(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.Info("hidden")
+	l.Warn("shown")
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "shown" {
+		t.Fatalf("expected only warn record, got %v", lines)
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	l, err := SetupWriter(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithCorrelationID(context.Background(), "req-1")
+	child := With(ctx, KeyOrderUID, "uid-1")
+	l.InfoContext(child, "child")
+	l.InfoContext(ctx, "parent")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0][KeyCorrelationID] != "req-1" || lines[0][KeyOrderUID] != "uid-1" {
+		t.Errorf("child record misses context fields: %v", lines[0])
+	}
+	// поля дочернего контекста не должны попадать в родительский
+	if _, ok := lines[1][KeyOrderUID]; ok || lines[1][KeyCorrelationID] != "req-1" {
+		t.Errorf("unexpected parent record: %v", lines[1])
+	}
+	if CorrelationID(child) != "req-1" {
+		t.Errorf("expected correlation ID req-1, got %q", CorrelationID(child))
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	var got string
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = CorrelationID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderCorrelationID, "abc")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got != "abc" || rr.Header().Get(HeaderRequestID) != "abc" {
+		t.Errorf("expected correlation ID from header, got %q / %q", got, rr.Header().Get(HeaderRequestID))
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got == "" || rr.Header().Get(HeaderRequestID) != got {
+		t.Errorf("expected generated correlation ID to be returned, got %q / %q", got, rr.Header().Get(HeaderRequestID))
+	}
+}
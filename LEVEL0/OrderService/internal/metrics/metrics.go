@@ -0,0 +1,171 @@
+// Package metrics declares Prometheus metrics of the service and helpers to collect them
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes all metrics of the service
+const Namespace = "orderservice"
+
+// Kafka consumer metrics
+var (
+	KafkaMessagesConsumed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "kafka", Name: "messages_consumed_total",
+		Help: "Messages fetched from Kafka.",
+	})
+	KafkaMessagesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "kafka", Name: "messages_rejected_total",
+		Help: "Messages sent to InvalidRequests and DLQ by reason: decode, validation, poison.",
+	}, []string{"reason"})
+	KafkaMessagesPersisted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "kafka", Name: "messages_persisted_total",
+		Help: "Messages successfully stored in DB by kind: order, event.",
+	}, []string{"kind"})
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: "kafka", Name: "consumer_lag",
+		Help: "Messages left in partition after the last fetched one.",
+	}, []string{"partition"})
+)
+
+// Repository metrics
+var (
+	RepositoryQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "repository", Name: "query_duration_seconds",
+		Help:    "Duration of repository calls including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "status"})
+	RepositoryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "repository", Name: "retries_total",
+		Help: "Repeated repository calls after a transient DB error.",
+	}, []string{"method"})
+	RepositoryCircuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: "repository", Name: "circuit_state",
+		Help: "State of the DB circuit breaker: 0 - closed, 1 - half-open, 2 - open.",
+	})
+	RepositoryCircuitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "repository", Name: "circuit_rejections_total",
+		Help: "Repository calls rejected without querying the DB because the circuit breaker is open.",
+	})
+)
+
+// DB connection pool metrics; pool usage itself is exported as go_sql_* by RegisterDBPool
+var (
+	DBPoolSettings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace, Subsystem: "db", Name: "pool_settings",
+		Help: "Configured pool and session limits by DB and setting, 0 - no limit.",
+	}, []string{"db", "setting"})
+	DBReplicaFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "db", Name: "replica_fallbacks_total",
+		Help: "Reads repeated on the primary because a read replica was unavailable.",
+	})
+)
+
+// Cache invalidation metrics
+var (
+	CacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "cache", Name: "invalidations_total",
+		Help: "Handled order change notifications by action: refresh, evict, skip, clear.",
+	}, []string{"action"})
+)
+
+// Outbox relay metrics
+var (
+	OutboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "outbox", Name: "messages_published_total",
+		Help: "Outbox messages delivered to Kafka.",
+	})
+	OutboxPublishErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "outbox", Name: "publish_errors_total",
+		Help: "Failed attempts to deliver an outbox message; the message is retried.",
+	})
+	OutboxDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "outbox", Name: "messages_deleted_total",
+		Help: "Published outbox messages removed after retention period.",
+	})
+)
+
+// PII encryption metrics
+var (
+	PIIReencrypted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "pii", Name: "reencrypted_rows_total",
+		Help: "Stored rows whose personal data was re-encrypted with the active key or the current policy, by table.",
+	}, []string{"table"})
+)
+
+// HTTP metrics
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "HTTP request latency by route pattern, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+	HTTPRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "rate_limited_total",
+		Help: "Requests rejected with 429 by route pattern.",
+	}, []string{"route"})
+	HTTPPanics = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace, Subsystem: "http", Name: "panics_total",
+		Help: "Panics recovered in HTTP handlers.",
+	})
+)
+
+// gRPC metrics
+var (
+	GRPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace, Subsystem: "grpc", Name: "request_duration_seconds",
+		Help:    "gRPC call latency by method and status code; for streams - the whole stream lifetime.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+// Handler returns HTTP handler exposing all registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDBPool exports statistics of the named connection pool(open, in use and idle connections, waits) and its settings
+func RegisterDBPool(name string, db *sql.DB, settings map[string]float64) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+	for setting, value := range settings {
+		DBPoolSettings.WithLabelValues(name, setting).Set(value)
+	}
+}
+
+// HTTPMiddleware measures request latency; chi route pattern is used as label to keep cardinality low
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		HTTPRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveQuery records duration of a repository call started at start
+func ObserveQuery(method string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	RepositoryQueryDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestHTTPMiddlewareUsesRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(HTTPMiddleware)
+	r.Get("/api/v1/orders/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, uid := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+uid, nil))
+	}
+
+	// разные uid не должны порождать отдельные серии
+	if n := testutil.CollectAndCount(HTTPRequestDuration); n != 1 {
+		t.Fatalf("expected 1 series, got %d", n)
+	}
+	var m dto.Metric
+	if err := HTTPRequestDuration.WithLabelValues(http.MethodGet, "/api/v1/orders/{uid}", "404").(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 2 {
+		t.Fatalf("expected 2 observations, got %d", got)
+	}
+}
@@ -0,0 +1,290 @@
+// Package migrate applies versioned SQL migrations embedded into the binary and tracks them in schema_version table
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"orderservice/internal/logger"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	// ErrUnknownVersion is returned when DB schema is newer than the latest migration known to this binary
+	ErrUnknownVersion = errors.New("unknown schema version")
+	// ErrPendingMigrations is returned when DB schema is older than the latest migration
+	ErrPendingMigrations = errors.New("schema has pending migrations")
+)
+
+// lockID is pg_advisory_lock key, so that several instances do not migrate concurrently
+const lockID = 7_243_001
+
+var fileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up/down SQL scripts with the same version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Applied is a row of schema_version table
+type Applied struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status describes DB schema state relative to known migrations
+type Status struct {
+	Current int // 0 - ни одна миграция не применена
+	Latest  int
+	Applied []Applied
+	Pending []Migration
+}
+
+// Migrator applies migrations to the DB
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns Migrator with migrations embedded into the binary
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load reads NNNN_name.up.sql/NNNN_name.down.sql pairs; versions must be unique, start from 1 and go without gaps
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, path := range names {
+		base := path[len("sql/"):]
+		m := fileRe.FindStringSubmatch(base)
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: file name must be NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		version, _ := strconv.Atoi(m[1])
+		raw, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: up and down files have different names", version)
+		}
+		if m[3] == "up" {
+			mig.Up = string(raw)
+		} else {
+			mig.Down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mig := range migrations {
+		if mig.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential from 1, got %d at position %d", mig.Version, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest returns the latest known migration version
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Up applies all pending migrations, each in its own transaction; returns the number of applied migrations
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, m.Latest(), false); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations[current:] {
+			if err := apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back steps latest migrations; returns the number of rolled back migrations
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, m.Latest(), false); err != nil {
+			return err
+		}
+		for v := current; v > 0 && rolledBack < steps; v-- {
+			if err := apply(ctx, conn, m.migrations[v-1], false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status returns applied and pending migrations
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	st := Status{Latest: m.Latest()}
+	if err := ensureTable(ctx, m.db); err != nil {
+		return st, err
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_version ORDER BY version`)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a Applied
+		if err := rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return st, err
+		}
+		st.Applied = append(st.Applied, a)
+		st.Current = max(st.Current, a.Version)
+	}
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+	if st.Current < st.Latest {
+		st.Pending = m.migrations[st.Current:]
+	}
+	return st, nil
+}
+
+// Check returns ErrUnknownVersion or ErrPendingMigrations unless DB schema is exactly at the latest known version
+func (m *Migrator) Check(ctx context.Context) error {
+	st, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return checkVersion(st.Current, st.Latest, true)
+}
+
+// checkVersion compares DB schema version with the latest known one; pending migrations are an error only if strict
+func checkVersion(current, latest int, strict bool) error {
+	switch {
+	case current > latest:
+		return fmt.Errorf("%w: DB is at version %d, this build knows migrations up to %d", ErrUnknownVersion, current, latest)
+	case strict && current < latest:
+		return fmt.Errorf("%w: DB is at version %d, latest is %d; run \"migrate up\"", ErrPendingMigrations, current, latest)
+	}
+	return nil
+}
+
+// locked runs fn on a single connection holding advisory lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		//снимаем блокировку даже если ctx уже отменен
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			slog.Error("Failed to release migration lock", logger.Err(err))
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func ensureTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var v int
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+	return v, err
+}
+
+// apply runs up or down script of mig and updates schema_version in the same transaction
+func apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, direction := mig.Down, "down"
+	if up {
+		script, direction = mig.Up, "up"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_version (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_version WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Migration applied", "version", mig.Version, "name", mig.Name, "direction", direction)
+	return nil
+}
@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("embedded migrations are invalid: %v", err)
+	}
+	if len(migrations) < 2 {
+		t.Fatalf("expected at least 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Name != "init" || !strings.Contains(migrations[0].Up, "CREATE TABLE IF NOT EXISTS orders") {
+		t.Errorf("first migration must create initial schema, got %s", migrations[0].Name)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	tests := []struct {
+		name string
+		fs   fstest.MapFS
+		want string
+	}{
+		{"bad name", fstest.MapFS{"sql/init.up.sql": file("x")}, "file name"},
+		{"missing down", fstest.MapFS{"sql/0001_init.up.sql": file("x")}, "both up and down"},
+		{"different names", fstest.MapFS{
+			"sql/0001_init.up.sql":    file("x"),
+			"sql/0001_other.down.sql": file("x"),
+		}, "different names"},
+		{"gap", fstest.MapFS{
+			"sql/0001_init.up.sql":   file("x"),
+			"sql/0001_init.down.sql": file("x"),
+			"sql/0003_next.up.sql":   file("x"),
+			"sql/0003_next.down.sql": file("x"),
+		}, "sequential"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.fs)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	migrations, err := load(fstest.MapFS{
+		"sql/0002_next.down.sql": file("down2"),
+		"sql/0001_init.up.sql":   file("up1"),
+		"sql/0002_next.up.sql":   file("up2"),
+		"sql/0001_init.down.sql": file("down1"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Up != "up1" || migrations[1].Down != "down2" {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	if err := checkVersion(3, 3, true); err != nil {
+		t.Errorf("expected nil for current schema, got %v", err)
+	}
+	if err := checkVersion(4, 3, false); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+	if err := checkVersion(2, 3, true); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("expected ErrPendingMigrations, got %v", err)
+	}
+	if err := checkVersion(2, 3, false); err != nil {
+		t.Errorf("pending migrations are allowed before up, got %v", err)
+	}
+}
+
+// TestLegacyDateCreated runs migration 0006 against a real Postgres(MIGRATE_TEST_DATABASE_URL, in a temporary schema)
+// over rows that the service accepted before date_created was validated as RFC3339
+func TestLegacyDateCreated(t *testing.T) {
+	dsn := os.Getenv("MIGRATE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("MIGRATE_TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := stdlib.OpenDB(*config)
+	defer admin.Close()
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+
+	config.RuntimeParams["search_path"] = schema
+	config.RuntimeParams["TimeZone"] = "Europe/Moscow" //результат не должен зависеть от TimeZone сессии
+	db := stdlib.OpenDB(*config)
+	defer db.Close()
+
+	all, err := load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const version = 6
+	if _, err := (&Migrator{db: db, migrations: all[:version-1]}).Up(ctx); err != nil {
+		t.Fatalf("migrations before %d: %v", version, err)
+	}
+	dates := map[string]string{
+		"valid":   "2021-11-26T09:22:19+03:00",
+		"naive":   "2021-11-26 06:22:19",
+		"invalid": "2021-13-01T00:00:00Z",
+		"garbage": "not a date",
+	}
+	for uid, date := range dates {
+		if _, err := db.ExecContext(ctx, `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id,
+			delivery_service, shard_key, sm_id, date_created, oof_shard) VALUES ($1, '', '', '', '', '', '', '', 0, $2, '')`, uid, date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Migrator{db: db, migrations: all[:version]}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migration %d over legacy rows: %v", version, err)
+	}
+	want := map[string]string{
+		"valid":   "2021-11-26T09:22:19+03:00",
+		"naive":   "2021-11-26T06:22:19.000000Z",
+		"invalid": "1970-01-01T00:00:00.000000Z",
+		"garbage": "1970-01-01T00:00:00.000000Z",
+	}
+	for uid, date := range want {
+		var got string
+		var created time.Time
+		if err := db.QueryRowContext(ctx, `SELECT date_created, order_created_at(date_created) FROM orders WHERE order_uid = $1`, uid).
+			Scan(&got, &created); err != nil {
+			t.Fatalf("%s: %v", uid, err)
+		}
+		parsed, _ := time.Parse(time.RFC3339, date)
+		if got != date || !created.Equal(parsed) {
+			t.Errorf("%s: got %q(%s), want %q", uid, got, created, date)
+		}
+	}
+	var legacy int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM orders_legacy_date_created`).Scan(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy != 3 {
+		t.Errorf("expected 3 legacy values kept, got %d", legacy)
+	}
+	var created *time.Time
+	if err := db.QueryRowContext(ctx, `SELECT order_created_at('2021-11-26 06:22:19')`).Scan(&created); err != nil || created != nil {
+		t.Errorf("value without offset must give NULL, got %v, %v", created, err)
+	}
+
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for uid, date := range dates {
+		var got string
+		if err := db.QueryRowContext(ctx, `SELECT date_created FROM orders WHERE order_uid = $1`, uid).Scan(&got); err != nil || got != date {
+			t.Errorf("%s: down must restore %q, got %q, %v", uid, date, got, err)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_histories;
+DROP TABLE IF EXISTS invalid_requests;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,88 @@
+-- Исходная схема; совпадает с тем, что создавал GORM AutoMigrate, поэтому безопасна для уже существующих баз
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid          text PRIMARY KEY,
+    track_number       text NOT NULL,
+    entry              text NOT NULL,
+    locale             text NOT NULL,
+    internal_signature text NOT NULL,
+    customer_id        text NOT NULL,
+    delivery_service   text NOT NULL,
+    shard_key          text NOT NULL,
+    sm_id              bigint NOT NULL,
+    date_created       text NOT NULL,
+    oof_shard          text NOT NULL,
+    version            bigint NOT NULL DEFAULT 0,
+    cancelled_at       timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS deliveries (
+    d_id      bigserial PRIMARY KEY,
+    order_uid text NOT NULL,
+    name      text NOT NULL,
+    phone     text NOT NULL,
+    zip       text NOT NULL,
+    city      text NOT NULL,
+    address   text NOT NULL,
+    region    text NOT NULL,
+    email     text NOT NULL,
+    CONSTRAINT fk_orders_delivery FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_deliveries_order_uid ON deliveries (order_uid);
+
+CREATE TABLE IF NOT EXISTS payments (
+    p_id          bigserial PRIMARY KEY,
+    order_uid     text NOT NULL,
+    transaction   text NOT NULL,
+    request_id    text NOT NULL,
+    currency      text NOT NULL,
+    provider      text NOT NULL,
+    amount        bigint NOT NULL,
+    payment_dt    bigint NOT NULL,
+    bank          text NOT NULL,
+    delivery_cost bigint NOT NULL,
+    goods_total   bigint NOT NULL,
+    custom_fee    bigint NOT NULL,
+    CONSTRAINT fk_orders_payment FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_payments_order_uid ON payments (order_uid);
+
+CREATE TABLE IF NOT EXISTS items (
+    i_id         bigserial PRIMARY KEY,
+    order_uid    text NOT NULL,
+    chrt_id      bigint NOT NULL,
+    track_number text NOT NULL,
+    price        bigint NOT NULL,
+    r_id         text NOT NULL,
+    name         text NOT NULL,
+    sale         bigint NOT NULL,
+    size         text NOT NULL,
+    total_price  bigint NOT NULL,
+    nm_id        bigint NOT NULL,
+    brand        text NOT NULL,
+    status       bigint NOT NULL,
+    CONSTRAINT fk_orders_items FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);
+
+CREATE TABLE IF NOT EXISTS invalid_requests (
+    id            bigserial PRIMARY KEY,
+    received_at   timestamptz NOT NULL,
+    updated_at    timestamptz,
+    raw_json      text NOT NULL,
+    error_message text NOT NULL,
+    status        text NOT NULL,
+    attempts      bigint NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_invalid_requests_status ON invalid_requests (status);
+
+CREATE TABLE IF NOT EXISTS order_histories (
+    id         bigserial PRIMARY KEY,
+    order_uid  text NOT NULL,
+    event_id   text NOT NULL,
+    event_type text NOT NULL,
+    version    bigint NOT NULL,
+    payload    text NOT NULL,
+    created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_order_histories_order_uid ON order_histories (order_uid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_histories_event_id ON order_histories (event_id);
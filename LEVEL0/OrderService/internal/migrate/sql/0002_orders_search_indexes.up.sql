@@ -0,0 +1,3 @@
+-- Индексы для списка заказов: сортировка по дате с keyset-пагинацией и фильтр по покупателю
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created, order_uid);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
@@ -0,0 +1,14 @@
+-- Transactional outbox: события пишутся в одной транзакции с заказом и публикуются в Kafka фоновым relay
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id           bigserial PRIMARY KEY,
+    event_type   text NOT NULL,
+    aggregate_id text NOT NULL,
+    payload      text NOT NULL,
+    created_at   timestamptz NOT NULL,
+    published_at timestamptz,
+    attempts     bigint NOT NULL DEFAULT 0,
+    last_error   text NOT NULL DEFAULT ''
+);
+-- relay выбирает неопубликованные сообщения по порядку, очистка удаляет опубликованные по дате
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_published_at ON outbox_messages (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS orders_notify_truncate ON orders;
+DROP TRIGGER IF EXISTS orders_notify_change ON orders;
+DROP FUNCTION IF EXISTS notify_order_change();
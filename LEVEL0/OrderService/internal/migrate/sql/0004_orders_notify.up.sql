@@ -0,0 +1,24 @@
+-- Уведомления об изменении заказов для согласования кешей нескольких экземпляров сервиса.
+-- NOTIFY доставляется только после фиксации транзакции; payload: {"op":"INSERT|UPDATE|DELETE|TRUNCATE","order_uid":"...","version":N}
+CREATE OR REPLACE FUNCTION notify_order_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        PERFORM pg_notify('order_changes', json_build_object('op', TG_OP)::text);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('order_changes', json_build_object('op', TG_OP, 'order_uid', OLD.order_uid, 'version', OLD.version)::text);
+    ELSE
+        PERFORM pg_notify('order_changes', json_build_object('op', TG_OP, 'order_uid', NEW.order_uid, 'version', NEW.version)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS orders_notify_change ON orders;
+CREATE TRIGGER orders_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON orders
+    FOR EACH ROW EXECUTE FUNCTION notify_order_change();
+
+DROP TRIGGER IF EXISTS orders_notify_truncate ON orders;
+CREATE TRIGGER orders_notify_truncate
+    AFTER TRUNCATE ON orders
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_order_change();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_messages_pending;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS failed_at;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS available_at;
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages (id) WHERE published_at IS NULL;
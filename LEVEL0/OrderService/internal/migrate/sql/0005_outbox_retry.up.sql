@@ -0,0 +1,6 @@
+-- available_at: relay не берет сообщение раньше этого времени - пока его публикует другой экземпляр или до повторной попытки;
+-- failed_at: сообщение отложено после OUTBOX_MAX_ATTEMPTS неудач и больше не публикуется
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS available_at timestamptz;
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS failed_at timestamptz;
+DROP INDEX IF EXISTS idx_outbox_messages_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_pending ON outbox_messages (id) WHERE published_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created, order_uid);
+DROP INDEX IF EXISTS idx_orders_created_at;
+DROP FUNCTION IF EXISTS order_created_at(text);
+UPDATE orders SET date_created = legacy.date_created
+    FROM orders_legacy_date_created legacy WHERE orders.order_uid = legacy.order_uid;
+DROP TABLE IF EXISTS orders_legacy_date_created;
@@ -0,0 +1,42 @@
+-- date_created хранится текстом, как пришел в сообщении: как текст его сравнивать нельзя(другие смещения и доли секунды),
+-- а приведение text::timestamptz не IMMUTABLE и не индексируется.
+-- До проверки RFC3339 в validation date_created принималась в любом непустом виде. Такие строки приводятся к RFC3339 в UTC
+-- (значение без смещения считается UTC, неразбираемое заменяется началом эпохи), исходное значение сохраняется
+-- в orders_legacy_date_created для разбора
+CREATE TABLE IF NOT EXISTS orders_legacy_date_created (
+    order_uid    text PRIMARY KEY,
+    date_created text NOT NULL,
+    migrated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+DO $$
+DECLARE
+    r       record;
+    created timestamptz;
+BEGIN
+    SET LOCAL TimeZone = 'UTC';
+    FOR r IN SELECT order_uid, date_created FROM orders LOOP
+        BEGIN
+            created := r.date_created::timestamptz;
+            CONTINUE WHEN r.date_created ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$';
+        EXCEPTION WHEN data_exception THEN
+            created := 'epoch';
+        END;
+        INSERT INTO orders_legacy_date_created (order_uid, date_created) VALUES (r.order_uid, r.date_created)
+            ON CONFLICT (order_uid) DO NOTHING;
+        --version не меняется: это версия событий заказа, а не строки
+        UPDATE orders SET date_created = to_char(created, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"') WHERE order_uid = r.order_uid;
+    END LOOP;
+END $$;
+
+-- Значение со смещением разбирается одинаково при любой TimeZone сессии, остальные дают NULL,
+-- поэтому функцию можно объявить IMMUTABLE, а строка в обход валидации не ломает вставку
+CREATE OR REPLACE FUNCTION order_created_at(date_created text) RETURNS timestamptz
+    LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE
+    AS $$
+        SELECT CASE WHEN date_created ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$'
+            THEN date_created::timestamptz END
+    $$;
+-- фильтр по периоду, сортировка по дате и keyset-пагинация используют одно выражение
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (order_created_at(date_created), order_uid);
+DROP INDEX IF EXISTS idx_orders_date_created;
@@ -0,0 +1,89 @@
+package model
+
+import "time"
+
+// Intervals of the revenue series
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week" // с понедельника, как date_trunc('week') в Postgres
+)
+
+// Orderings of top brands and products
+const (
+	TopByQuantity = "quantity"
+	TopByRevenue  = "revenue"
+)
+
+// AnalyticsFilter selects orders aggregated by analytics; cancelled orders are never counted
+type AnalyticsFilter struct {
+	DateFrom time.Time // включительно
+	DateTo   time.Time // не включительно
+	Interval string    // IntervalDay или IntervalWeek
+	TopBy    string    // TopByQuantity или TopByRevenue
+	Limit    int       // длина топов брендов и товаров и таблицы служб доставки
+}
+
+// PeriodStart returns start of the revenue period containing t, in UTC
+func (f AnalyticsFilter) PeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if f.Interval == IntervalWeek {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// Analytics is a set of order aggregates over AnalyticsFilter; amounts are in minor units of their currency
+// and are never summed across currencies
+type Analytics struct {
+	DateFrom    time.Time      `json:"date_from"`
+	DateTo      time.Time      `json:"date_to"`
+	Interval    string         `json:"interval"`
+	Revenue     []RevenuePoint `json:"revenue"`
+	TopBrands   []BrandStat    `json:"top_brands"`
+	TopProducts []ProductStat  `json:"top_products"`
+	Deliveries  []DeliveryStat `json:"deliveries"`
+	Baskets     []BasketStat   `json:"baskets"`
+}
+
+// RevenuePoint is revenue of one period in one currency, from Payment.Amount
+type RevenuePoint struct {
+	Period   time.Time `gorm:"column:period" json:"period"`
+	Currency string    `gorm:"column:currency" json:"currency"`
+	Orders   int64     `gorm:"column:orders" json:"orders"`
+	Revenue  int64     `gorm:"column:revenue" json:"revenue"`
+}
+
+// BrandStat is the number of sold items of a brand and their Item.TotalPrice sum in one currency
+type BrandStat struct {
+	Brand    string `gorm:"column:brand" json:"brand"`
+	Currency string `gorm:"column:currency" json:"currency"`
+	Quantity int64  `gorm:"column:quantity" json:"quantity"`
+	Revenue  int64  `gorm:"column:revenue" json:"revenue"`
+}
+
+// ProductStat is BrandStat of a single nm_id
+type ProductStat struct {
+	NMID     uint   `gorm:"column:nm_id" json:"nm_id"`
+	Name     string `gorm:"column:name" json:"name"`
+	Brand    string `gorm:"column:brand" json:"brand"`
+	Currency string `gorm:"column:currency" json:"currency"`
+	Quantity int64  `gorm:"column:quantity" json:"quantity"`
+	Revenue  int64  `gorm:"column:revenue" json:"revenue"`
+}
+
+// DeliveryStat is the number of orders of a delivery service to a region
+type DeliveryStat struct {
+	DeliveryService string `gorm:"column:delivery_service" json:"delivery_service"`
+	Region          string `gorm:"column:region" json:"region"` //пусто, если регион шифруется и группировать по нему нельзя
+	Orders          int64  `gorm:"column:orders" json:"orders"`
+}
+
+// BasketStat describes average order in one currency: its Payment.Amount and number of items
+type BasketStat struct {
+	Currency  string  `gorm:"column:currency" json:"currency"`
+	Orders    int64   `gorm:"column:orders" json:"orders"`
+	Revenue   int64   `gorm:"column:revenue" json:"revenue"`
+	AvgAmount float64 `gorm:"column:avg_amount" json:"avg_amount"`
+	AvgItems  float64 `gorm:"column:avg_items" json:"avg_items"`
+}
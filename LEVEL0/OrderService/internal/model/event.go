@@ -0,0 +1,26 @@
+package model
+
+// Types of order events received from Kafka
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderUpdated   = "order.updated"
+	EventOrderCancelled = "order.cancelled"
+)
+
+// OrderEvent is a versioned change of an order. Created and updated events carry the full new state of the order,
+// cancelled event needs only OrderUID and Version. A message without event_type is treated as a plain order (legacy format)
+type OrderEvent struct {
+	EventID    string     `json:"event_id"`
+	EventType  string     `json:"event_type"`
+	OrderUID   string     `json:"order_uid"`
+	Version    uint       `json:"version"`
+	OccurredAt CustomTime `json:"occurred_at"`
+	Order      *Order     `json:"order,omitempty"`
+}
+
+// OrderUpdate is a change of an order accepted by the service, delivered to WatchOrders subscribers.
+// Type is one of the event types: plain orders are reported as EventOrderCreated
+type OrderUpdate struct {
+	Type  string
+	Order Order // состояние заказа после изменения
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// SortableOrderFields maps allowed values of the "sort" parameter to columns of the orders table
+var SortableOrderFields = map[string]string{
+	"date_created": "date_created",
+	"order_uid":    "order_uid",
+	"customer_id":  "customer_id",
+	"track_number": "track_number",
+}
+
+// OrderFilter describes search criteria, sorting and pagination for order listing; empty fields are ignored
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	DateFrom        time.Time // включительно
+	DateTo          time.Time // не включительно
+	Provider        string    // Payment.Provider
+	Bank            string    // Payment.Bank
+	Brand           string    // хотя бы один Item с таким брендом
+	NMID            uint      // хотя бы один Item с таким nm_id
+
+	SortBy   string // ключ из SortableOrderFields
+	SortDesc bool
+	Limit    int
+	Cursor   string // непрозрачный курсор из OrderPage.NextCursor
+}
+
+// OrderCursor is a decoded keyset-pagination position: the last order of the previous page
+type OrderCursor struct {
+	SortBy    string `json:"s"`
+	SortDesc  bool   `json:"d"`
+	SortValue string `json:"v"`
+	OrderUID  string `json:"u"`
+}
+
+// OrderPage is a single page of orders with a cursor to the next one (empty if it is the last page)
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Matches reports whether the order satisfies filter criteria; sorting and pagination fields are ignored.
+// It is the in-memory counterpart of the SQL conditions built by the Postgres repository
+func (f OrderFilter) Matches(o *Order) bool {
+	if f.CustomerID != "" && o.CustomerID != f.CustomerID {
+		return false
+	}
+	if f.TrackNumber != "" && o.TrackNumber != f.TrackNumber {
+		return false
+	}
+	if f.DeliveryService != "" && o.DeliveryService != f.DeliveryService {
+		return false
+	}
+	if !f.DateFrom.IsZero() || !f.DateTo.IsZero() {
+		created, err := time.Parse(time.RFC3339, o.DateCreated)
+		if err != nil {
+			return false
+		}
+		if !f.DateFrom.IsZero() && created.Before(f.DateFrom) {
+			return false
+		}
+		if !f.DateTo.IsZero() && !created.Before(f.DateTo) {
+			return false
+		}
+	}
+	if f.Provider != "" && o.Payment.Provider != f.Provider {
+		return false
+	}
+	if f.Bank != "" && o.Payment.Bank != f.Bank {
+		return false
+	}
+	if f.Brand != "" && !slices.ContainsFunc(o.Items, func(i Item) bool { return i.Brand == f.Brand }) {
+		return false
+	}
+	if f.NMID != 0 && !slices.ContainsFunc(o.Items, func(i Item) bool { return i.NMID == f.NMID }) {
+		return false
+	}
+	return true
+}
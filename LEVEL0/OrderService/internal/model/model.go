@@ -30,6 +30,9 @@ type Order struct {
 	SMID              int    `gorm:"not null" json:"sm_id"`
 	DateCreated       string `gorm:"not null" json:"date_created"`
 	OofShard          string `gorm:"not null" json:"oof_shard"`
+
+	Version     uint       `gorm:"not null;default:0" json:"version,omitempty"` //версия последнего примененного события
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
 }
 
 // Delivery contains delivery information for a certain order
@@ -80,13 +83,34 @@ type Item struct {
 
 // InvalidRequest is a struct for storing order information if it is received from Kafka in invalid form
 type InvalidRequest struct {
-	ID           *uint     `gorm:"primaryKey;autoIncrement;->" json:"-"`
-	ReceivedAt   time.Time `gorm:"not null" json:"-"`
-	RawJSON      string    `gorm:"not null" json:"-"`
-	ErrorMessage string    `gorm:"not null" json:"-"`
-	Status       string    `gorm:"not null" json:"-"` //may be used for assigning statuses like "new","processed"
+	ID           *uint     `gorm:"primaryKey;autoIncrement;->" json:"id"`
+	ReceivedAt   time.Time `gorm:"not null" json:"received_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RawJSON      string    `gorm:"not null" json:"raw_json"`
+	ErrorMessage string    `gorm:"not null" json:"error_message"`
+	Status       string    `gorm:"not null;index" json:"status"`
+	Attempts     int       `gorm:"not null;default:0" json:"attempts"` //количество повторных попыток обработки
+}
+
+// OrderHistory is an audit record of an applied order event, EventID is unique and used for deduplication
+type OrderHistory struct {
+	ID        *uint     `gorm:"primaryKey;autoIncrement;->" json:"-"`
+	OrderUID  string    `gorm:"index;not null" json:"order_uid"`
+	EventID   string    `gorm:"uniqueIndex;not null" json:"event_id"`
+	EventType string    `gorm:"not null" json:"event_type"`
+	Version   uint      `gorm:"not null" json:"version"`
+	Payload   string    `gorm:"not null" json:"payload"` //состояние заказа в JSON после применения события
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
 }
 
+// Statuses of InvalidRequest lifecycle: New -> Retried -> ... -> Resolved or Discarded
+const (
+	InvalidStatusNew       = "New"       //сообщение только что отклонено
+	InvalidStatusRetried   = "Retried"   //была попытка повторной обработки, но она тоже завершилась ошибкой
+	InvalidStatusResolved  = "Resolved"  //после исправления заказ успешно сохранен
+	InvalidStatusDiscarded = "Discarded" //сообщение отброшено администратором
+)
+
 // UnmarshalJSON - method for CustomTime used to process "RFC3339" and "Unix timestamp" input date types
 // не забыть добавить сохранение заказа в RAW-табличку в слое сервиса
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
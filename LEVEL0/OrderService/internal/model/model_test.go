@@ -1,7 +1,10 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,3 +31,24 @@ func TestCustomTime_Unix(t *testing.T) {
 		t.Fatalf("got %v", ct.UTC())
 	}
 }
+
+func TestOrder_LogValueMasksPII(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+	order := Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+		Payment:  Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD"},
+	}
+	log.Info("order", "order", order, "request", InvalidRequest{RawJSON: `{"phone":"+9720000000"}`, Status: "pending"})
+
+	out := buf.String()
+	for _, leaked := range []string{"Testov", "+9720000000", "test@gmail.com"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("personal data %q leaked to log: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "t***@gmail.com") || !strings.Contains(out, "Kiryat Mozkin") || !strings.Contains(out, "order.order_uid=b563feb7b2b84b6test") {
+		t.Errorf("unexpected log record: %s", out)
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// EventOrderPersisted is published to the outbox topic when a new order is stored
+const EventOrderPersisted = "order.persisted"
+
+// OutboxMessage is a row of the transactional outbox: it is written in the same transaction as the order
+// and published to Kafka by the relay, so the event is never lost and never sent for a rolled back order
+type OutboxMessage struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventType   string     `gorm:"not null" json:"event_type"`
+	AggregateID string     `gorm:"not null" json:"aggregate_id"` //order_uid, используется как ключ сообщения Kafka
+	Payload     string     `gorm:"not null" json:"payload"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"` //неудачные попытки публикации
+	LastError   string     `gorm:"not null;default:''" json:"last_error,omitempty"`
+	AvailableAt *time.Time `json:"available_at,omitempty"` //раньше этого времени relay сообщение не берет: оно публикуется или ждет повтора
+	FailedAt    *time.Time `json:"failed_at,omitempty"`    //сообщение отложено после исчерпания попыток и больше не публикуется
+}
+
+// OrderPersistedEvent is the payload of order.persisted outbox messages
+type OrderPersistedEvent struct {
+	EventID    string    `json:"event_id"` //"<order_uid>:persisted", подписчики используют его для дедупликации
+	EventType  string    `json:"event_type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      *Order    `json:"order"`
+}
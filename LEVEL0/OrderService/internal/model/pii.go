@@ -0,0 +1,74 @@
+package model
+
+import (
+	"log/slog"
+	"orderservice/internal/pii"
+)
+
+// PIIFields returns pointers to the fields of the order which may hold personal data, by pii field names
+func (o *Order) PIIFields() map[string]*string {
+	return map[string]*string{
+		pii.FieldDeliveryName:    &o.Delivery.Name,
+		pii.FieldDeliveryPhone:   &o.Delivery.Phone,
+		pii.FieldDeliveryEmail:   &o.Delivery.Email,
+		pii.FieldDeliveryAddress: &o.Delivery.Address,
+		pii.FieldDeliveryZip:     &o.Delivery.Zip,
+		pii.FieldDeliveryCity:    &o.Delivery.City,
+		pii.FieldDeliveryRegion:  &o.Delivery.Region,
+		pii.FieldPaymentTx:       &o.Payment.Transaction,
+		pii.FieldPaymentRequest:  &o.Payment.RequestID,
+	}
+}
+
+// Masked returns copy of the order with fields of the current PII policy masked
+func (o Order) Masked() Order {
+	pii.Current().Mask(o.PIIFields())
+	return o
+}
+
+// LogValue logs order with personal data masked, so an order passed to slog never leaks it
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.Uint64("version", uint64(o.Version)),
+		slog.Any("delivery", o.Delivery),
+		slog.Any("payment", o.Payment),
+	)
+}
+
+// LogValue logs delivery with fields of the current PII policy masked
+func (d Delivery) LogValue() slog.Value {
+	fields := (&Order{Delivery: d}).PIIFields()
+	pii.Current().Mask(fields)
+	return slog.GroupValue(
+		slog.String("name", *fields[pii.FieldDeliveryName]),
+		slog.String("phone", *fields[pii.FieldDeliveryPhone]),
+		slog.String("email", *fields[pii.FieldDeliveryEmail]),
+		slog.String("address", *fields[pii.FieldDeliveryAddress]),
+		slog.String("zip", *fields[pii.FieldDeliveryZip]),
+		slog.String("city", *fields[pii.FieldDeliveryCity]),
+		slog.String("region", *fields[pii.FieldDeliveryRegion]),
+	)
+}
+
+// LogValue logs payment with identifiers masked according to the current PII policy
+func (p Payment) LogValue() slog.Value {
+	fields := (&Order{Payment: p}).PIIFields()
+	pii.Current().Mask(fields)
+	return slog.GroupValue(
+		slog.String("transaction", *fields[pii.FieldPaymentTx]),
+		slog.String("request_id", *fields[pii.FieldPaymentRequest]),
+		slog.String("currency", p.Currency),
+		slog.String("provider", p.Provider),
+		slog.Uint64("amount", uint64(p.Amount)),
+	)
+}
+
+// LogValue logs rejected message without its payload, which contains personal data
+func (r InvalidRequest) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("status", r.Status), slog.Int("attempts", r.Attempts)}
+	if r.ID != nil {
+		attrs = append(attrs, slog.Uint64("id", uint64(*r.ID)))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,142 @@
+// Package outbox publishes messages of the transactional outbox to the broker
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"orderservice/internal/resilience"
+	"time"
+)
+
+// Publisher delivers outbox messages to the broker
+type Publisher interface {
+	// Publish returns an error for every message(nil if it was delivered); nil slice means all messages were delivered
+	Publish(ctx context.Context, msgs []model.OutboxMessage) []error
+}
+
+// Config describes relay polling, retry and cleanup policy
+type Config struct {
+	BatchSize       int           // сообщений за один опрос
+	PollInterval    time.Duration // пауза, когда неопубликованных сообщений нет
+	RetryBackoff    time.Duration // начальная пауза после ошибки, в том числе перед повтором неудачного сообщения
+	MaxRetryBackoff time.Duration
+	MaxAttempts     int           // после стольких неудач сообщение откладывается(failed_at); 0 - без ограничения
+	Lease           time.Duration // сколько сообщения закреплены за relay на время публикации
+	Retention       time.Duration // сколько хранить опубликованные сообщения
+	CleanupInterval time.Duration
+}
+
+// Relay periodically moves messages from the outbox to the broker with at-least-once delivery:
+// a message is marked published only after the broker acknowledged it
+type Relay struct {
+	repo repository.OutboxRepository
+	pub  Publisher
+	cfg  Config
+}
+
+// NewRelay returns relay with defaults for zero config fields
+func NewRelay(repo repository.OutboxRepository, pub Publisher, cfg Config) *Relay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
+	}
+	if cfg.MaxRetryBackoff < cfg.RetryBackoff {
+		cfg.MaxRetryBackoff = 30 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = time.Hour
+	}
+	return &Relay{repo: repo, pub: pub, cfg: cfg}
+}
+
+// Run publishes messages until ctx is done
+func (R *Relay) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "Outbox relay started")
+	defer slog.InfoContext(ctx, "Outbox relay stopped")
+
+	backoff := R.backoff()
+	retry := 0
+	lastCleanup := time.Now()
+	for {
+		published, failed, err := R.PublishBatch(ctx)
+		var delay time.Duration
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil || failed > 0 && published == 0:
+			//экспоненциальная пауза, чтобы не долбить недоступный брокер или БД; отдельные неудачные сообщения
+			//откладываются сами и не мешают публикации остальных
+			delay = backoff.Delay(retry)
+			retry++
+			slog.WarnContext(ctx, "Outbox publishing failed, retrying", "failed", failed, logger.Err(err), "delay", delay)
+		case published+failed == R.cfg.BatchSize:
+			retry = 0 //вероятно, есть еще сообщения - продолжаем без паузы
+		default:
+			retry = 0
+			delay = R.cfg.PollInterval
+		}
+
+		if time.Since(lastCleanup) >= R.cfg.CleanupInterval {
+			R.Cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+		if delay > 0 && !resilience.Sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// PublishBatch publishes one batch of pending messages; returns the number of delivered and failed messages
+func (R *Relay) PublishBatch(ctx context.Context) (published, failed int, err error) {
+	claim := repository.OutboxClaim{
+		Limit:       R.cfg.BatchSize,
+		Lease:       R.cfg.Lease,
+		MaxAttempts: R.cfg.MaxAttempts,
+		Backoff:     R.backoff(),
+	}
+	published, failed, err = R.repo.ClaimOutbox(ctx, claim, func(msgs []model.OutboxMessage) []error {
+		return R.pub.Publish(ctx, msgs)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	metrics.OutboxPublished.Add(float64(published))
+	metrics.OutboxPublishErrors.Add(float64(failed))
+	if published > 0 {
+		slog.DebugContext(ctx, "Outbox messages published", "count", published)
+	}
+	return published, failed, nil
+}
+
+// backoff is the pause after failures of the relay and before retry of a failed message
+func (R *Relay) backoff() resilience.Backoff {
+	return resilience.Backoff{Initial: R.cfg.RetryBackoff, Max: R.cfg.MaxRetryBackoff}
+}
+
+// Cleanup removes messages published earlier than Retention ago
+func (R *Relay) Cleanup(ctx context.Context) {
+	deleted, err := R.repo.DeleteOutboxPublished(ctx, time.Now().Add(-R.cfg.Retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to clean up outbox", logger.Err(err))
+		return
+	}
+	metrics.OutboxDeleted.Add(float64(deleted))
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Outbox cleaned up", "deleted", deleted)
+	}
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePublisher fails the first failures calls and records delivered messages
+type fakePublisher struct {
+	mu        sync.Mutex
+	failures  int
+	calls     int
+	delivered []model.OutboxMessage
+}
+
+func (p *fakePublisher) Publish(_ context.Context, msgs []model.OutboxMessage) []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls <= p.failures {
+		errs := make([]error, len(msgs))
+		for i := range errs {
+			errs[i] = errors.New("broker unavailable")
+		}
+		return errs
+	}
+	p.delivered = append(p.delivered, msgs...)
+	return nil
+}
+
+func (p *fakePublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.delivered)
+}
+
+func addOrders(t *testing.T, repo repository.OrderRepository, uids ...string) {
+	t.Helper()
+	orders := make([]*model.Order, len(uids))
+	for i, uid := range uids {
+		orders[i] = &model.Order{OrderUID: uid, DateCreated: "2021-11-26T06:22:19Z"}
+	}
+	if err := repo.AddNewOrders(context.Background(), orders); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	addOrders(t, repo, "a", "b", "c")
+	pub := &fakePublisher{failures: 1}
+	relay := NewRelay(repo.(repository.OutboxRepository), pub, Config{BatchSize: 2, RetryBackoff: time.Nanosecond})
+
+	if published, failed, err := relay.PublishBatch(ctx); err != nil || published != 0 || failed != 2 {
+		t.Fatalf("expected 2 failed messages, got %d published, %d failed, %v", published, failed, err)
+	}
+	if published, failed, _ := relay.PublishBatch(ctx); published != 2 || failed != 0 {
+		t.Fatalf("expected 2 published messages, got %d published, %d failed", published, failed)
+	}
+	if published, _, _ := relay.PublishBatch(ctx); published != 1 {
+		t.Fatalf("expected the rest of messages, got %d", published)
+	}
+	if published, _, _ := relay.PublishBatch(ctx); published != 0 {
+		t.Fatalf("every message must be published once, got %d more", published)
+	}
+
+	// порядок сохраняется, несмотря на повтор
+	var event model.OrderPersistedEvent
+	for i, uid := range []string{"a", "b", "c"} {
+		msg := pub.delivered[i]
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			t.Fatal(err)
+		}
+		if msg.AggregateID != uid || event.OrderUID != uid || event.EventType != model.EventOrderPersisted || event.Order == nil {
+			t.Errorf("unexpected message %d: %+v", i, msg)
+		}
+	}
+}
+
+func TestRun_RetriesAndCleansUp(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	addOrders(t, repo, "a", "b")
+	pub := &fakePublisher{failures: 2}
+	relay := NewRelay(repo.(repository.OutboxRepository), pub, Config{
+		PollInterval:    5 * time.Millisecond,
+		RetryBackoff:    time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+		Retention:       time.Nanosecond,
+		CleanupInterval: time.Nanosecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pub.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// сообщение, созданное во время работы, тоже публикуется
+	addOrders(t, repo, "c")
+	for pub.count() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	if got := pub.count(); got != 3 {
+		t.Fatalf("expected 3 delivered messages, got %d", got)
+	}
+	// опубликованные сообщения удалены, повторно ничего не отправляется
+	var left int
+	_, _, _ = repo.(repository.OutboxRepository).ClaimOutbox(context.Background(), repository.OutboxClaim{Limit: 10}, func(msgs []model.OutboxMessage) []error {
+		left = len(msgs)
+		return nil
+	})
+	if deleted, _ := repo.(repository.OutboxRepository).DeleteOutboxPublished(context.Background(), time.Now()); left != 0 || deleted != 0 {
+		t.Errorf("expected empty outbox, got %d pending and %d published", left, deleted)
+	}
+}
@@ -0,0 +1,102 @@
+package pii
+
+import (
+	"slices"
+)
+
+// payloadFields are encrypted whenever the codec is configured
+var payloadFields = []string{FieldRawJSON, FieldHistoryPayload, FieldOutboxPayload}
+
+// Codec encrypts personal data before it is stored and decrypts it after reading. Order fields are encrypted
+// according to the policy, payloads with whole orders always. A nil *Codec stores values as is
+// but still fails on encrypted ones, so a service started without keys does not show ciphertext as data
+type Codec struct {
+	keys   *Keyring
+	policy Policy
+}
+
+// NewCodec returns codec encrypting policy fields with keys
+func NewCodec(keys *Keyring, policy Policy) *Codec {
+	return &Codec{keys: keys, policy: policy}
+}
+
+// Covers reports whether values of the field are stored encrypted
+func (C *Codec) Covers(field string) bool {
+	return C != nil && (C.policy.Contains(field) || slices.Contains(payloadFields, field))
+}
+
+// ActiveKey returns ID of the key new values are encrypted with; empty for nil codec
+func (C *Codec) ActiveKey() string {
+	if C == nil {
+		return ""
+	}
+	return C.keys.Active()
+}
+
+// Seal returns value as it should be stored: encrypted if the field is covered; empty values stay empty.
+// Input starting with Prefix is encrypted as any other value, so it is never mistaken for ciphertext on reading
+func (C *Codec) Seal(field, value string) (string, error) {
+	if value == "" || !C.Covers(field) {
+		return value, nil
+	}
+	return C.keys.Encrypt(field, []byte(value))
+}
+
+// Open returns plain value of a stored one; plain values are returned as is, so data stored before encryption
+// was enabled or of fields removed from the policy remains readable
+func (C *Codec) Open(field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if C == nil {
+		return "", ErrNoKeys
+	}
+	plaintext, err := C.keys.Decrypt(field, value)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SealFields seals fields(name -> pointer to value) in place
+func (C *Codec) SealFields(fields map[string]*string) error {
+	for name, value := range fields {
+		sealed, err := C.Seal(name, *value)
+		if err != nil {
+			return err
+		}
+		*value = sealed
+	}
+	return nil
+}
+
+// OpenFields opens fields in place
+func (C *Codec) OpenFields(fields map[string]*string) error {
+	for name, value := range fields {
+		plain, err := C.Open(name, *value)
+		if err != nil {
+			return err
+		}
+		*value = plain
+	}
+	return nil
+}
+
+// Stale reports whether stored value has to be rewritten: a covered field which is plain or encrypted with an old key,
+// or an encrypted field which is no longer covered
+func (C *Codec) Stale(field, value string) bool {
+	id, encrypted := KeyID(value)
+	if !C.Covers(field) {
+		return encrypted
+	}
+	return value != "" && id != C.keys.Active()
+}
+
+// Rewrite re-encrypts stored value according to the current keys and policy
+func (C *Codec) Rewrite(field, value string) (string, error) {
+	plain, err := C.Open(field, value)
+	if err != nil {
+		return "", err
+	}
+	return C.Seal(field, plain)
+}
@@ -0,0 +1,207 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Prefix starts every encrypted value: "pii:v1:<key id>:<base64 envelope>"
+const Prefix = "pii:v1:"
+
+// KeySize is the size of key-encryption keys and data keys, AES-256
+const KeySize = 32
+
+var (
+	// ErrUnknownKey is returned when a value is encrypted with a key that is not configured
+	ErrUnknownKey = errors.New("PII encrypted with unknown key")
+	// ErrNoKeys is returned when an encrypted value is read without configured keys
+	ErrNoKeys = errors.New("PII is encrypted but no keys are configured")
+	// ErrCorrupted is returned when an encrypted value cannot be decoded or authenticated
+	ErrCorrupted = errors.New("PII ciphertext is corrupted")
+)
+
+var keyIDRe = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// Key is a key-encryption key: it encrypts random data keys, which encrypt the values themselves
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys parses "id:base64-key" entries separated by commas or new lines; lines starting with # are skipped
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDRe.MatchString(id) {
+			return nil, fmt.Errorf("PII key entry %q: expected id:base64-key, id of letters, digits and '-'", id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(secret) != KeySize {
+			return nil, fmt.Errorf("PII key %q: expected %d bytes in base64", id, KeySize)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// LoadKeyFile reads keys in the format of ParseKeys from a file
+func LoadKeyFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeys(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Keyring encrypts values with the active key and decrypts values encrypted with any of its keys
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// NewKeyring returns keyring of keys; active is the ID of the key used for encryption(the last key if empty).
+// Old keys are kept in the keyring until all values are re-encrypted with the active one
+func NewKeyring(keys []Key, active string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no PII keys configured")
+	}
+	K := &Keyring{keys: make(map[string]cipher.AEAD, len(keys)), active: active}
+	for _, key := range keys {
+		if _, ok := K.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate PII key %q", key.ID)
+		}
+		aead, err := newAEAD(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("PII key %q: %w", key.ID, err)
+		}
+		K.keys[key.ID] = aead
+	}
+	if K.active == "" {
+		K.active = keys[len(keys)-1].ID
+	}
+	if _, ok := K.keys[K.active]; !ok {
+		return nil, fmt.Errorf("active PII key %q is not configured", K.active)
+	}
+	return K, nil
+}
+
+// Active returns ID of the key used for encryption
+func (K *Keyring) Active() string {
+	return K.active
+}
+
+// Encrypt encrypts plaintext with a new data key and wraps the data key with the active key.
+// aad(field name) is authenticated, so a value copied to another field does not decrypt
+func (K *Keyring) Encrypt(aad string, plaintext []byte) (string, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	kek := K.keys[K.active]
+	// envelope: nonce и зашифрованный ключ данных, затем nonce и зашифрованное значение
+	envelope, err := seal(kek, nil, dek, []byte(K.active))
+	if err != nil {
+		return "", err
+	}
+	if envelope, err = seal(data, envelope, plaintext, []byte(aad)); err != nil {
+		return "", err
+	}
+	return Prefix + K.active + ":" + base64.RawStdEncoding.EncodeToString(envelope), nil
+}
+
+// Decrypt decrypts value produced by Encrypt with the same aad
+func (K *Keyring) Decrypt(aad, value string) ([]byte, error) {
+	id, encoded, ok := split(value)
+	if !ok {
+		return nil, ErrCorrupted
+	}
+	kek, ok := K.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	envelope, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	wrappedLen := kek.NonceSize() + KeySize + kek.Overhead()
+	if len(envelope) < wrappedLen {
+		return nil, ErrCorrupted
+	}
+	dek, err := open(kek, envelope[:wrappedLen], []byte(id))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(data, envelope[wrappedLen:], []byte(aad))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether value was produced by Keyring.Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// KeyID returns ID of the key value is encrypted with; false for plain values
+func KeyID(value string) (string, bool) {
+	id, _, ok := split(value)
+	return id, ok
+}
+
+func split(value string) (id, encoded string, ok bool) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal appends random nonce and ciphertext to dst
+func seal(aead cipher.AEAD, dst, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+}
@@ -0,0 +1,44 @@
+package pii
+
+import "strings"
+
+const maskChar = "*"
+
+// MaskValue masks value of the field for display: the name keeps its first letter, the phone - two first and two last digits,
+// the email - first letter and domain, payment identifiers - four last characters; other fields are masked completely
+func MaskValue(field, value string) string {
+	switch strings.TrimPrefix(field, "$.") {
+	case FieldDeliveryName:
+		return mask(value, 1, 0)
+	case FieldDeliveryPhone:
+		return mask(value, 2, 2)
+	case FieldDeliveryEmail:
+		return maskEmail(value)
+	case FieldPaymentTx, FieldPaymentRequest:
+		return mask(value, 0, 4)
+	default:
+		return mask(value, 0, 0)
+	}
+}
+
+// mask keeps first and last runes of s and replaces the rest; short values are masked completely
+func mask(s string, first, last int) string {
+	if s == "" {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= first+last+2 {
+		//короткое значение после частичной маскировки легко угадать
+		return strings.Repeat(maskChar, len(runes))
+	}
+	return string(runes[:first]) + strings.Repeat(maskChar, len(runes)-first-last) + string(runes[len(runes)-last:])
+}
+
+// maskEmail keeps the first rune of the local part and the domain
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return mask(email, 0, 0)
+	}
+	return mask(local, 1, 0) + "@" + domain
+}
@@ -0,0 +1,183 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(id string, b byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{b}, KeySize)}
+}
+
+func testKeyring(t *testing.T, active string, keys ...Key) *Keyring {
+	t.Helper()
+	K, err := NewKeyring(keys, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return K
+}
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	K := testKeyring(t, "", testKey("k1", 1))
+
+	value, err := K.Encrypt(FieldDeliveryPhone, []byte("+79040000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, Prefix+"k1:") || strings.Contains(value, "79040000000") {
+		t.Fatalf("unexpected ciphertext %q", value)
+	}
+	again, _ := K.Encrypt(FieldDeliveryPhone, []byte("+79040000000"))
+	if again == value {
+		t.Errorf("expected random data key and nonce for every value")
+	}
+
+	plain, err := K.Decrypt(FieldDeliveryPhone, value)
+	if err != nil || string(plain) != "+79040000000" {
+		t.Fatalf("expected roundtrip, got %q, %v", plain, err)
+	}
+
+	// значение, перенесенное в другое поле, не расшифровывается
+	if _, err := K.Decrypt(FieldDeliveryEmail, value); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted for another field, got %v", err)
+	}
+	if _, err := K.Decrypt(FieldDeliveryPhone, value[:len(value)-4]); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted for truncated value, got %v", err)
+	}
+	other := testKeyring(t, "", testKey("k2", 2))
+	if _, err := other.Decrypt(FieldDeliveryPhone, value); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	k1, k2 := testKey("k1", 1), testKey("k2", 2)
+	if K := testKeyring(t, "", k1, k2); K.Active() != "k2" {
+		t.Errorf("expected the last key to be active, got %q", K.Active())
+	}
+	if K := testKeyring(t, "k1", k1, k2); K.Active() != "k1" {
+		t.Errorf("expected configured active key, got %q", K.Active())
+	}
+	for name, keys := range map[string][]Key{
+		"no keys":   nil,
+		"duplicate": {k1, k1},
+		"short key": {{ID: "k3", Secret: []byte("short")}},
+	} {
+		if _, err := NewKeyring(keys, ""); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := NewKeyring([]Key{k1}, "k9"); err == nil {
+		t.Errorf("expected error for unknown active key")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, KeySize))
+	keys, err := ParseKeys("# old\n2024-01:" + secret + "\n 2024-06:" + secret + " , ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "2024-01" || keys[1].ID != "2024-06" || len(keys[1].Secret) != KeySize {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	for _, spec := range []string{
+		"nokey",
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"bad id:" + secret,
+	} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCodec_Rotation(t *testing.T) {
+	k1, k2 := testKey("k1", 1), testKey("k2", 2)
+	policy, err := NewPolicy(FieldDeliveryPhone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := NewCodec(testKeyring(t, "", k1), policy)
+
+	sealed, err := old.Seal(FieldDeliveryPhone, "+79040000000")
+	if err != nil || !IsEncrypted(sealed) {
+		t.Fatalf("expected encrypted value, got %q, %v", sealed, err)
+	}
+	if city, _ := old.Seal(FieldDeliveryCity, "Moscow"); city != "Moscow" {
+		t.Errorf("fields out of policy must be stored as is, got %q", city)
+	}
+	if raw, _ := old.Seal(FieldRawJSON, "{}"); !IsEncrypted(raw) {
+		t.Errorf("payloads must always be encrypted, got %q", raw)
+	}
+	lookalike := Prefix + "k1:+79040000000"
+	if stored, _ := old.Seal(FieldDeliveryPhone, lookalike); stored == lookalike {
+		t.Errorf("plain value with reserved prefix must be encrypted")
+	} else if plain, err := old.Open(FieldDeliveryPhone, stored); err != nil || plain != lookalike {
+		t.Errorf("expected %q back, got %q, %v", lookalike, plain, err)
+	}
+	if old.Stale(FieldDeliveryPhone, sealed) || !old.Stale(FieldDeliveryPhone, "+79040000000") {
+		t.Errorf("expected only plain value to be stale")
+	}
+
+	// новый активный ключ: старые значения читаются и перешифровываются
+	rotated := NewCodec(testKeyring(t, "k2", k1, k2), policy)
+	if !rotated.Stale(FieldDeliveryPhone, sealed) {
+		t.Fatalf("expected value encrypted with old key to be stale")
+	}
+	rewritten, err := rotated.Rewrite(FieldDeliveryPhone, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(rewritten); id != "k2" || rotated.Stale(FieldDeliveryPhone, rewritten) {
+		t.Errorf("expected value re-encrypted with k2, got %q", rewritten)
+	}
+	if plain, err := rotated.Open(FieldDeliveryPhone, rewritten); err != nil || plain != "+79040000000" {
+		t.Errorf("expected plain value, got %q, %v", plain, err)
+	}
+
+	// поле исключено из политики: значение расшифровывается обратно
+	narrowed := NewCodec(testKeyring(t, "k2", k1, k2), Policy{})
+	if !narrowed.Stale(FieldDeliveryPhone, rewritten) {
+		t.Fatalf("expected encrypted value of uncovered field to be stale")
+	}
+	if plain, _ := narrowed.Rewrite(FieldDeliveryPhone, rewritten); plain != "+79040000000" {
+		t.Errorf("expected decrypted value, got %q", plain)
+	}
+}
+
+func TestCodec_Nil(t *testing.T) {
+	var C *Codec
+	if v, err := C.Seal(FieldDeliveryPhone, "+79040000000"); err != nil || v != "+79040000000" {
+		t.Errorf("nil codec must store values as is, got %q, %v", v, err)
+	}
+	sealed, _ := NewCodec(testKeyring(t, "", testKey("k1", 1)), DefaultPolicy()).Seal(FieldDeliveryPhone, "+79040000000")
+	if _, err := C.Open(FieldDeliveryPhone, sealed); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy("$.delivery.phone", " payment.transaction ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Contains(FieldDeliveryPhone) || !p.Contains(FieldPaymentTx) || p.Contains(FieldDeliveryEmail) {
+		t.Errorf("unexpected policy fields %v", p.Fields())
+	}
+	if _, err := NewPolicy("delivery.passport"); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+
+	phone, email, city := "+79040000000", "test@gmail.com", "Moscow"
+	DefaultPolicy().Mask(map[string]*string{FieldDeliveryPhone: &phone, FieldDeliveryEmail: &email, FieldDeliveryCity: &city})
+	if phone != "+7********00" || email != "t***@gmail.com" || city != "Moscow" {
+		t.Errorf("unexpected masked values %q %q %q", phone, email, city)
+	}
+}
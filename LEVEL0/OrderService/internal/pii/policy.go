@@ -0,0 +1,105 @@
+// Package pii defines which order fields are personal data and protects them: masks them for display and logs
+// and encrypts them at rest with envelope encryption
+package pii
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Personal data fields of an order, named by their JSON paths without "$."
+const (
+	FieldDeliveryName    = "delivery.name"
+	FieldDeliveryPhone   = "delivery.phone"
+	FieldDeliveryEmail   = "delivery.email"
+	FieldDeliveryAddress = "delivery.address"
+	FieldDeliveryZip     = "delivery.zip"
+	FieldDeliveryCity    = "delivery.city"
+	FieldDeliveryRegion  = "delivery.region"
+	FieldPaymentTx       = "payment.transaction"
+	FieldPaymentRequest  = "payment.request_id"
+)
+
+// Stored payloads which contain whole orders; they are encrypted whenever keys are configured, regardless of the policy
+const (
+	FieldRawJSON        = "invalid_requests.raw_json"
+	FieldHistoryPayload = "order_histories.payload"
+	FieldOutboxPayload  = "outbox_messages.payload"
+)
+
+// Fields lists every field a policy may include
+var Fields = []string{
+	FieldDeliveryName, FieldDeliveryPhone, FieldDeliveryEmail, FieldDeliveryAddress, FieldDeliveryZip,
+	FieldDeliveryCity, FieldDeliveryRegion, FieldPaymentTx, FieldPaymentRequest,
+}
+
+// DefaultFields are customer contacts and payment identifiers; city and region are left out, they are needed for analytics
+var DefaultFields = []string{
+	FieldDeliveryName, FieldDeliveryPhone, FieldDeliveryEmail, FieldDeliveryAddress, FieldDeliveryZip,
+	FieldPaymentTx, FieldPaymentRequest,
+}
+
+// Policy is a set of order fields treated as personal data
+type Policy struct {
+	fields []string
+}
+
+// NewPolicy returns policy of the given fields; unknown fields are rejected
+func NewPolicy(fields ...string) (Policy, error) {
+	var p Policy
+	for _, f := range fields {
+		f = strings.TrimPrefix(strings.TrimSpace(f), "$.")
+		if !slices.Contains(Fields, f) {
+			return Policy{}, fmt.Errorf("unknown PII field %q", f)
+		}
+		if !slices.Contains(p.fields, f) {
+			p.fields = append(p.fields, f)
+		}
+	}
+	slices.Sort(p.fields)
+	return p, nil
+}
+
+// DefaultPolicy returns policy of DefaultFields
+func DefaultPolicy() Policy {
+	p, _ := NewPolicy(DefaultFields...)
+	return p
+}
+
+// Contains reports whether field(name or JSON path like "$.delivery.phone") is personal data
+func (p Policy) Contains(field string) bool {
+	_, found := slices.BinarySearch(p.fields, strings.TrimPrefix(field, "$."))
+	return found
+}
+
+// Fields returns fields of the policy
+func (p Policy) Fields() []string {
+	return slices.Clone(p.fields)
+}
+
+// Mask masks the policy fields among fields(name -> pointer to value, see model.Order.PIIFields) in place
+func (p Policy) Mask(fields map[string]*string) {
+	for name, value := range fields {
+		if p.Contains(name) {
+			*value = MaskValue(name, *value)
+		}
+	}
+}
+
+var current atomic.Pointer[Policy]
+
+// SetPolicy makes p the policy used for masking in logs, HTML pages and for roles without access to personal data.
+// Called once at startup; until then DefaultPolicy is used
+func SetPolicy(p Policy) {
+	current.Store(&p)
+}
+
+// Current returns the policy set by SetPolicy
+func Current() Policy {
+	if p := current.Load(); p != nil {
+		return *p
+	}
+	return DefaultPolicy()
+}
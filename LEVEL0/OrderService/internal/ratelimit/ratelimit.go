@@ -0,0 +1,176 @@
+// Package ratelimit limits request rate of every client with token buckets
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets of idle clients are dropped
+const sweepInterval = time.Minute
+
+// Limit allows Rate requests per second on average and bursts of up to Burst requests; zero Rate means no limit
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether requests are not limited
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "0"
+	}
+	return strconv.FormatFloat(l.Rate, 'f', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// ParseLimit parses "RATE[:BURST]", RATE in requests per second; burst defaults to RATE rounded up. "0" disables the limit
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	var l Limit
+	var err error
+	if l.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil || l.Rate < 0 || math.IsInf(l.Rate, 0) {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected RATE[:BURST]", s)
+	}
+	if l.Rate == 0 {
+		return Limit{}, nil
+	}
+	l.Burst = int(math.Ceil(l.Rate))
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || l.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	}
+	return l, nil
+}
+
+// ParseRoutes parses comma-separated "METHOD /route/pattern=RATE[:BURST]" entries; patterns are chi route patterns
+func ParseRoutes(spec string) (map[string]Limit, error) {
+	routes := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		method, pattern, hasPattern := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPattern || !strings.HasPrefix(strings.TrimSpace(pattern), "/") {
+			return nil, fmt.Errorf("invalid route limit %q: expected METHOD /pattern=RATE[:BURST]", entry)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[RouteKey(method, strings.TrimSpace(pattern))] = l
+	}
+	return routes, nil
+}
+
+// RouteKey returns key of the route in Config.Routes
+func RouteKey(method, pattern string) string {
+	return strings.ToUpper(method) + " " + pattern
+}
+
+// Config of Limiter
+type Config struct {
+	Default Limit            // лимит клиента на все маршруты без собственного лимита вместе
+	Routes  map[string]Limit // собственные лимиты маршрутов по RouteKey, считаются отдельно от Default
+}
+
+type bucketKey struct {
+	route  string // пусто - общий бакет маршрутов с лимитом по умолчанию
+	client string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per client and route; it is safe for concurrent use
+type Limiter struct {
+	cfg       Config
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// New returns limiter with cfg
+func New(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg, now: time.Now, buckets: make(map[bucketKey]*bucket)}
+}
+
+// Limit returns limit applied to the route
+func (L *Limiter) Limit(route string) Limit {
+	if l, ok := L.cfg.Routes[route]; ok {
+		return l
+	}
+	return L.cfg.Default
+}
+
+// Allow takes a token from the client's bucket of the route; if there is none it returns false and how long to wait for the next one
+func (L *Limiter) Allow(route, client string) (bool, time.Duration) {
+	return L.acquire(route, client, true)
+}
+
+// Check reports like Allow whether the client's bucket of the route has a token, but does not take it.
+// Used when only some requests are counted and it is known after the request whether it was one of them
+func (L *Limiter) Check(route, client string) (bool, time.Duration) {
+	return L.acquire(route, client, false)
+}
+
+func (L *Limiter) acquire(route, client string, take bool) (bool, time.Duration) {
+	key := bucketKey{client: client}
+	limit, ok := L.cfg.Routes[route]
+	if ok {
+		key.route = route
+	} else {
+		limit = L.cfg.Default
+	}
+	if limit.Unlimited() {
+		return true, 0
+	}
+
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	now := L.now()
+	L.sweep(now)
+	b, ok := L.buckets[key]
+	if !ok {
+		if !take {
+			return true, 0 //новый бакет полон
+		}
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		L.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// sweep drops buckets which have been refilled completely: a new bucket of the client would be the same
+func (L *Limiter) sweep(now time.Time) {
+	if now.Sub(L.lastSweep) < sweepInterval {
+		return
+	}
+	L.lastSweep = now
+	for key, b := range L.buckets {
+		limit := L.Limit(key.route)
+		if limit.Unlimited() || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(L.buckets, key)
+		}
+	}
+}
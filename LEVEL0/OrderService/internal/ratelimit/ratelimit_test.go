@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	L := New(Config{
+		Default: Limit{Rate: 1, Burst: 2},
+		Routes:  map[string]Limit{"POST /api/v1/orders": {Rate: 10, Burst: 1}, "GET /metrics": {}},
+	})
+	L.now = func() time.Time { return now }
+
+	allow := func(route, client string) bool {
+		ok, _ := L.Allow(route, client)
+		return ok
+	}
+
+	// burst, затем отказ с временем до следующего токена
+	if !allow("GET /orders", "a") || !allow("GET /api/v1/orders", "a") {
+		t.Fatal("expected burst of 2 to be allowed")
+	}
+	ok, retryAfter := L.Allow("GET /orders", "a")
+	if ok || retryAfter != time.Second {
+		t.Fatalf("expected rejection with retry after 1s, got %v %v", ok, retryAfter)
+	}
+	if !allow("GET /orders", "b") {
+		t.Errorf("clients must have separate buckets")
+	}
+	if !allow("POST /api/v1/orders", "a") || allow("POST /api/v1/orders", "a") {
+		t.Errorf("route with own limit must have separate bucket of its burst")
+	}
+	for range 100 {
+		if !allow("GET /metrics", "a") {
+			t.Fatal("route with zero limit must not be limited")
+		}
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if allow("GET /orders", "a") {
+		t.Errorf("expected no token after 0.5s")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !allow("GET /orders", "a") {
+		t.Errorf("expected token after 1s")
+	}
+}
+
+func TestLimiter_Check(t *testing.T) {
+	L := New(Config{Default: Limit{Rate: 1, Burst: 1}})
+	now := time.Unix(0, 0)
+	L.now = func() time.Time { return now }
+	if ok, _ := L.Check("", "a"); !ok || len(L.buckets) != 0 {
+		t.Fatalf("expected check of a new client to pass without creating bucket")
+	}
+	L.Allow("", "a")
+	for range 2 {
+		if ok, wait := L.Check("", "a"); ok || wait != time.Second {
+			t.Fatalf("expected empty bucket, got %v %s", ok, wait)
+		}
+	}
+	now = now.Add(time.Second)
+	if ok, _ := L.Check("", "a"); !ok {
+		t.Errorf("expected refilled bucket")
+	}
+	if ok, _ := L.Allow("", "a"); !ok {
+		t.Errorf("check must not take the token")
+	}
+}
+
+func TestLimiter_SweepsIdleClients(t *testing.T) {
+	now := time.Unix(0, 0)
+	L := New(Config{Default: Limit{Rate: 1, Burst: 5}})
+	L.now = func() time.Time { return now }
+	for _, client := range []string{"a", "b", "c"} {
+		L.Allow("GET /orders", client)
+	}
+
+	now = now.Add(sweepInterval)
+	L.Allow("GET /orders", "d")
+	if len(L.buckets) != 1 {
+		t.Errorf("expected buckets of idle clients to be dropped, got %d", len(L.buckets))
+	}
+}
+
+func TestParse(t *testing.T) {
+	for spec, want := range map[string]Limit{
+		"20:40": {Rate: 20, Burst: 40},
+		"0.5":   {Rate: 0.5, Burst: 1},
+		"3":     {Rate: 3, Burst: 3},
+		"0":     {},
+	} {
+		if got, err := ParseLimit(spec); err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "-1", "fast", "1:0", "1:x"} {
+		if _, err := ParseLimit(spec); err == nil {
+			t.Errorf("ParseLimit(%q): expected error", spec)
+		}
+	}
+
+	routes, err := ParseRoutes("get /api/v1/orders=5:10, POST /api/v1/orders=0 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes["GET /api/v1/orders"] != (Limit{Rate: 5, Burst: 10}) || !routes["POST /api/v1/orders"].Unlimited() {
+		t.Errorf("unexpected routes %v", routes)
+	}
+	for _, spec := range []string{"/api/v1/orders=5", "GET api=5", "GET /api/v1/orders"} {
+		if _, err := ParseRoutes(spec); err == nil {
+			t.Errorf("ParseRoutes(%q): expected error", spec)
+		}
+	}
+}
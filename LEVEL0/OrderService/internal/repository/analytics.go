@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// analyticsOrders selects orders of AnalyticsFilter; every analytics query starts with it
+const analyticsOrders = `WITH o AS (
+	SELECT orders.order_uid, orders.delivery_service, ` + orderCreatedAt + ` AS created,
+		(SELECT count(*) FROM items i WHERE i.order_uid = orders.order_uid) AS items
+	FROM orders
+	WHERE orders.cancelled_at IS NULL AND ` + orderCreatedAt + ` >= @from AND ` + orderCreatedAt + ` < @to
+) `
+
+// topOrder maps AnalyticsFilter.TopBy to ORDER BY of top lists
+var topOrder = map[string]string{
+	model.TopByQuantity: "quantity DESC, revenue DESC",
+	model.TopByRevenue:  "revenue DESC, quantity DESC",
+}
+
+// GetAnalytics computes order aggregates with SQL; all queries run in one read-only transaction,
+// so the figures are consistent with each other
+func (OR *orderRepository) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	if filter.Interval != model.IntervalDay && filter.Interval != model.IntervalWeek {
+		return nil, fmt.Errorf("unsupported interval %q", filter.Interval)
+	}
+	order, ok := topOrder[filter.TopBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported top ordering %q", filter.TopBy)
+	}
+	args := map[string]any{"from": filter.DateFrom, "to": filter.DateTo, "interval": filter.Interval, "limit": filter.Limit}
+	//зашифрованный регион у каждого заказа свой, группировка по нему бессмысленна
+	region := "d.region"
+	if OR.PII.Covers(pii.FieldDeliveryRegion) {
+		region = "''"
+	}
+
+	var result *model.Analytics
+	err := OR.read(ctx, func(db *gorm.DB) error {
+		result = &model.Analytics{DateFrom: filter.DateFrom, DateTo: filter.DateTo, Interval: filter.Interval}
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			queries := []struct {
+				sql  string
+				dest any
+			}{
+				{`SELECT date_trunc(@interval, o.created AT TIME ZONE 'UTC') AS period, p.currency,
+					count(*) AS orders, sum(p.amount) AS revenue
+				FROM o JOIN payments p ON p.order_uid = o.order_uid
+				GROUP BY 1, 2 ORDER BY 1, 2`, &result.Revenue},
+				{`SELECT i.brand, p.currency, count(*) AS quantity, sum(i.total_price) AS revenue
+				FROM o JOIN items i ON i.order_uid = o.order_uid JOIN payments p ON p.order_uid = o.order_uid
+				GROUP BY 1, 2 ORDER BY ` + order + `, 1, 2 LIMIT @limit`, &result.TopBrands},
+				{`SELECT i.nm_id, max(i.name) AS name, max(i.brand) AS brand, p.currency,
+					count(*) AS quantity, sum(i.total_price) AS revenue
+				FROM o JOIN items i ON i.order_uid = o.order_uid JOIN payments p ON p.order_uid = o.order_uid
+				GROUP BY i.nm_id, p.currency ORDER BY ` + order + `, 1, 4 LIMIT @limit`, &result.TopProducts},
+				{`SELECT o.delivery_service, ` + region + ` AS region, count(*) AS orders
+				FROM o JOIN deliveries d ON d.order_uid = o.order_uid
+				GROUP BY 1, 2 ORDER BY orders DESC, 1, 2 LIMIT @limit`, &result.Deliveries},
+				{`SELECT p.currency, count(*) AS orders, sum(p.amount) AS revenue,
+					avg(p.amount)::float8 AS avg_amount, avg(o.items)::float8 AS avg_items
+				FROM o JOIN payments p ON p.order_uid = o.order_uid
+				GROUP BY 1 ORDER BY orders DESC, 1`, &result.Baskets},
+			}
+			for _, q := range queries {
+				if err := tx.Raw(analyticsOrders+q.sql, args).Scan(q.dest).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAnalytics computes the same aggregates as orderRepository over orders in memory
+func (MR *memoryRepository) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Interval != model.IntervalDay && filter.Interval != model.IntervalWeek {
+		return nil, fmt.Errorf("unsupported interval %q", filter.Interval)
+	}
+	if _, ok := topOrder[filter.TopBy]; !ok {
+		return nil, fmt.Errorf("unsupported top ordering %q", filter.TopBy)
+	}
+
+	type revenueKey struct {
+		period   time.Time
+		currency string
+	}
+	type productKey struct {
+		nmID     uint
+		currency string
+	}
+	revenue := make(map[revenueKey]*model.RevenuePoint)
+	brands := make(map[[2]string]*model.BrandStat)
+	products := make(map[productKey]*model.ProductStat)
+	deliveries := make(map[[2]string]*model.DeliveryStat)
+	baskets := make(map[string]*model.BasketStat)
+	items := make(map[string]int64)
+
+	MR.mu.RLock()
+	for _, o := range MR.orders {
+		created, err := time.Parse(time.RFC3339, o.DateCreated)
+		if err != nil || o.CancelledAt != nil || created.Before(filter.DateFrom) || !created.Before(filter.DateTo) {
+			continue
+		}
+		currency := o.Payment.Currency
+		rk := revenueKey{filter.PeriodStart(created), currency}
+		if revenue[rk] == nil {
+			revenue[rk] = &model.RevenuePoint{Period: rk.period, Currency: currency}
+		}
+		revenue[rk].Orders++
+		revenue[rk].Revenue += int64(o.Payment.Amount)
+
+		for _, item := range o.Items {
+			bk := [2]string{item.Brand, currency}
+			if brands[bk] == nil {
+				brands[bk] = &model.BrandStat{Brand: item.Brand, Currency: currency}
+			}
+			brands[bk].Quantity++
+			brands[bk].Revenue += int64(item.TotalPrice)
+
+			pk := productKey{item.NMID, currency}
+			if products[pk] == nil {
+				products[pk] = &model.ProductStat{NMID: item.NMID, Currency: currency}
+			}
+			p := products[pk]
+			p.Name, p.Brand = max(p.Name, item.Name), max(p.Brand, item.Brand)
+			p.Quantity++
+			p.Revenue += int64(item.TotalPrice)
+		}
+
+		dk := [2]string{o.DeliveryService, o.Delivery.Region}
+		if deliveries[dk] == nil {
+			deliveries[dk] = &model.DeliveryStat{DeliveryService: dk[0], Region: dk[1]}
+		}
+		deliveries[dk].Orders++
+
+		if baskets[currency] == nil {
+			baskets[currency] = &model.BasketStat{Currency: currency}
+		}
+		baskets[currency].Orders++
+		baskets[currency].Revenue += int64(o.Payment.Amount)
+		items[currency] += int64(len(o.Items))
+	}
+	MR.mu.RUnlock()
+
+	result := &model.Analytics{DateFrom: filter.DateFrom, DateTo: filter.DateTo, Interval: filter.Interval}
+	result.Revenue = derefSorted(revenue, func(a, b model.RevenuePoint) int {
+		return cmp.Or(a.Period.Compare(b.Period), cmp.Compare(a.Currency, b.Currency))
+	}, 0)
+	top := func(aQty, aRev, bQty, bRev int64) int {
+		if filter.TopBy == model.TopByRevenue {
+			return cmp.Or(cmp.Compare(bRev, aRev), cmp.Compare(bQty, aQty))
+		}
+		return cmp.Or(cmp.Compare(bQty, aQty), cmp.Compare(bRev, aRev))
+	}
+	result.TopBrands = derefSorted(brands, func(a, b model.BrandStat) int {
+		return cmp.Or(top(a.Quantity, a.Revenue, b.Quantity, b.Revenue), cmp.Compare(a.Brand, b.Brand), cmp.Compare(a.Currency, b.Currency))
+	}, filter.Limit)
+	result.TopProducts = derefSorted(products, func(a, b model.ProductStat) int {
+		return cmp.Or(top(a.Quantity, a.Revenue, b.Quantity, b.Revenue), cmp.Compare(a.NMID, b.NMID), cmp.Compare(a.Currency, b.Currency))
+	}, filter.Limit)
+	result.Deliveries = derefSorted(deliveries, func(a, b model.DeliveryStat) int {
+		return cmp.Or(cmp.Compare(b.Orders, a.Orders), cmp.Compare(a.DeliveryService, b.DeliveryService), cmp.Compare(a.Region, b.Region))
+	}, filter.Limit)
+	for currency, b := range baskets {
+		b.AvgAmount = float64(b.Revenue) / float64(b.Orders)
+		b.AvgItems = float64(items[currency]) / float64(b.Orders)
+	}
+	result.Baskets = derefSorted(baskets, func(a, b model.BasketStat) int {
+		return cmp.Or(cmp.Compare(b.Orders, a.Orders), cmp.Compare(a.Currency, b.Currency))
+	}, 0)
+	return result, nil
+}
+
+// derefSorted returns values of m sorted by compare, at most limit of them(all if limit is 0)
+func derefSorted[K comparable, V any](m map[K]*V, compare func(a, b V) int, limit int) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, *v)
+	}
+	slices.SortFunc(values, compare)
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+	return values
+}
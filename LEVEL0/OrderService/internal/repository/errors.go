@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUnavailable is returned without querying the DB while the circuit breaker is open
+var ErrUnavailable = errors.New("База данных временно недоступна")
+
+// ErrStatusChanged is returned by UpdateInvalidRequest when status of the rejected message is no longer the expected one
+var ErrStatusChanged = errors.New("status of the invalid request was changed concurrently")
+
+// ErrOrderExists is returned when an order with the same order_uid is already stored; wraps gorm.ErrDuplicatedKey
+var ErrOrderExists = errors.New("Заказ с таким номером уже существует")
+
+// SQLSTATE codes meaning the server is temporarily unable to process the query
+const (
+	sqlStateTooManyConnections  = "53300"
+	sqlStateAdminShutdown       = "57P01"
+	sqlStateCrashShutdown       = "57P02"
+	sqlStateCannotConnectNow    = "57P03"
+	sqlStateSerializationFailed = "40001"
+	sqlStateDeadlockDetected    = "40P01"
+)
+
+// IsTransient reports whether operation failed because of DB unavailability or timeout and may succeed if retried later
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case sqlStateTooManyConnections, sqlStateAdminShutdown, sqlStateCrashShutdown, sqlStateCannotConnectNow,
+			sqlStateSerializationFailed, sqlStateDeadlockDetected:
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") //класс connection exception
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || pgconn.Timeout(err)
+}
+
+// isUnavailable reports whether the DB did not answer at all; unlike IsTransient conflicts of concurrent transactions
+// are not counted, they do not mean the DB is down and must not open the circuit breaker
+func isUnavailable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == sqlStateSerializationFailed || pgErr.Code == sqlStateDeadlockDetected) {
+		return false
+	}
+	return IsTransient(err)
+}
+
+// safeToRetry reports whether a non-idempotent write may be repeated: the query surely did not reach the server
+// or the transaction was rolled back by the server
+func safeToRetry(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return IsTransient(err)
+	}
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr) || errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDuplicateEvent is returned when an event with the same EventID has already been applied
+	ErrDuplicateEvent = errors.New("event has already been applied")
+	// ErrStaleVersion is returned when event version is not greater than the stored order version
+	ErrStaleVersion = errors.New("event version is not newer than stored order version")
+)
+
+// ApplyOrderEvent applies created/updated/cancelled event to the order in a single transaction and records it in order history.
+// Returns the resulting order state; ErrDuplicateEvent and ErrStaleVersion mean the event was skipped
+func (OR *orderRepository) ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+	var result *model.Order
+	err := OR.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = OR.applyOrderEventTx(tx, event)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (OR *orderRepository) applyOrderEventTx(tx *gorm.DB, event *model.OrderEvent) (*model.Order, error) {
+	//события одного заказа, в том числе создающие его(строки для FOR UPDATE еще нет), применяются строго последовательно:
+	//повтор, пришедший одновременно с оригиналом, дождется его фиксации и увидит его запись в истории
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", event.OrderUID).Error; err != nil {
+		return nil, err
+	}
+	var seen int64
+	if err := tx.Model(&model.OrderHistory{}).Where("event_id = ?", event.EventID).Count(&seen).Error; err != nil {
+		return nil, err
+	}
+	if seen > 0 {
+		return nil, ErrDuplicateEvent
+	}
+
+	var current model.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_uid = ?", event.OrderUID).First(&current).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if exists && event.Version <= current.Version {
+		return nil, ErrStaleVersion
+	}
+
+	var result model.Order
+	switch event.EventType {
+	case model.EventOrderCreated, model.EventOrderUpdated:
+		result = *event.Order
+		result.OrderUID = event.OrderUID
+		result.Version = event.Version
+		result.CancelledAt = nil
+		if exists {
+			err = OR.replaceOrderTx(tx, &result)
+		} else {
+			err = OR.insertOrderTx(tx, &result)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case model.EventOrderCancelled:
+		if !exists {
+			return nil, gorm.ErrRecordNotFound
+		}
+		cancelledAt := event.OccurredAt.Time
+		if cancelledAt.IsZero() {
+			cancelledAt = time.Now().UTC()
+		}
+		if err := tx.Model(&model.Order{}).Where("order_uid = ?", event.OrderUID).
+			Updates(map[string]any{"version": event.Version, "cancelled_at": cancelledAt}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Preload("Delivery").Preload("Payment").Preload("Items").Where("order_uid = ?", event.OrderUID).First(&result).Error; err != nil {
+			return nil, err
+		}
+		if err := OR.open(&result); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown event type: " + event.EventType)
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	sealedPayload, err := OR.PII.Seal(pii.FieldHistoryPayload, string(payload))
+	if err != nil {
+		return nil, err
+	}
+	history := model.OrderHistory{
+		OrderUID:  event.OrderUID,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Version:   event.Version,
+		Payload:   sealedPayload,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			//единственный уникальный ключ истории - event_id: событие уже применено, возможно к другому заказу
+			return nil, fmt.Errorf("%w: %w", ErrDuplicateEvent, err)
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// insertOrderTx creates order, all its nested records and order.persisted outbox message inside transaction tx.
+// Personal data is encrypted in the stored copy only, order keeps plain values
+func (OR *orderRepository) insertOrderTx(tx *gorm.DB, order *model.Order) error {
+	clearDetailIDs(order)
+	stored, err := OR.sealed(order)
+	if err != nil {
+		return err
+	}
+	if err := tx.Omit(clause.Associations).Create(stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: %w", ErrOrderExists, err)
+		}
+		return err
+	}
+	if err := insertOrderDetailsTx(tx, stored); err != nil {
+		return err
+	}
+	return OR.insertOutboxTx(tx, order)
+}
+
+// replaceOrderTx overwrites order fields and recreates its delivery, payment and items inside transaction tx
+func (OR *orderRepository) replaceOrderTx(tx *gorm.DB, order *model.Order) error {
+	if err := tx.Model(&model.Order{}).Where("order_uid = ?", order.OrderUID).
+		Select("*").Omit("order_uid", clause.Associations).Updates(order).Error; err != nil {
+		return err
+	}
+	for _, m := range []any{&model.Delivery{}, &model.Payment{}, &model.Item{}} {
+		if err := tx.Where("order_uid = ?", order.OrderUID).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	clearDetailIDs(order)
+	stored, err := OR.sealed(order)
+	if err != nil {
+		return err
+	}
+	return insertOrderDetailsTx(tx, stored)
+}
+
+func insertOrderDetailsTx(tx *gorm.DB, order *model.Order) error {
+	if err := tx.Create(&order.Delivery).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&order.Payment).Error; err != nil {
+		return err
+	}
+	return tx.Create(&order.Items).Error
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"orderservice/internal/model"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// ExportRepository streams orders for bulk export; implemented by both Postgres and in-memory repositories.
+// It is used without the resilient decorator: a stream can not be repeated once part of it was written
+type ExportRepository interface {
+	// StreamOrders passes orders matching the filter to fn one by one, ordered by date_created and order_uid;
+	// sorting and pagination fields of the filter are ignored. Orders are read batchSize at a time,
+	// so memory use does not depend on the number of exported orders. An error of fn stops the stream and is returned as is
+	StreamOrders(ctx context.Context, filter model.OrderFilter, batchSize int, fn func(order *model.Order) error) error
+}
+
+// exportCursor is the name of the server-side cursor, it lives until the end of the export transaction
+const exportCursor = "export_orders"
+
+// StreamOrders implements ExportRepository with a server-side cursor over order_uid in a read-only REPEATABLE READ
+// transaction: the export is a consistent snapshot even if orders change while it is written.
+// Every FETCH is followed by a query loading the batch with delivery, payment and items
+func (OR *orderRepository) StreamOrders(ctx context.Context, filter model.OrderFilter, batchSize int, fn func(order *model.Order) error) error {
+	streamed := 0
+	var streamErr error
+	return OR.read(ctx, func(db *gorm.DB) error {
+		if streamed > 0 {
+			//реплика отказала посреди выгрузки: повтор на primary продублировал бы уже отданные заказы
+			return streamErr
+		}
+		streamErr = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			stmt := applyOrderFilter(tx.Session(&gorm.Session{DryRun: true}), filter).
+				Select("orders.order_uid").Order(orderCreatedAt + ", orders.order_uid").Find(&[]model.Order{}).Statement
+			declare := "DECLARE " + exportCursor + " NO SCROLL CURSOR FOR " + stmt.SQL.String()
+			if _, err := tx.Statement.ConnPool.ExecContext(ctx, declare, stmt.Vars...); err != nil {
+				return err
+			}
+
+			fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", batchSize, exportCursor)
+			for {
+				var uids []string
+				if err := tx.Raw(fetch).Scan(&uids).Error; err != nil {
+					return err
+				}
+				if len(uids) == 0 {
+					return nil
+				}
+				var orders []model.Order
+				err := tx.Preload("Delivery").Preload("Payment").Preload("Items").Where("order_uid IN ?", uids).Find(&orders).Error
+				if err != nil {
+					return err
+				}
+				//IN не сохраняет порядок курсора
+				position := make(map[string]int, len(uids))
+				for i, uid := range uids {
+					position[uid] = i
+				}
+				slices.SortFunc(orders, func(a, b model.Order) int {
+					return cmp.Compare(position[a.OrderUID], position[b.OrderUID])
+				})
+				for i := range orders {
+					if err := OR.open(&orders[i]); err != nil {
+						return err
+					}
+					if err := fn(&orders[i]); err != nil {
+						return err
+					}
+					streamed++
+				}
+			}
+		}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		return streamErr
+	})
+}
+
+// StreamOrders implements ExportRepository: matching orders are selected under the lock, then copied one by one,
+// so fn may take any time without blocking writers. batchSize is not used
+func (MR *memoryRepository) StreamOrders(ctx context.Context, filter model.OrderFilter, batchSize int, fn func(order *model.Order) error) error {
+	type key struct {
+		dateCreated string
+		orderUID    string
+	}
+	var keys []key
+	MR.mu.RLock()
+	for _, order := range MR.orders {
+		if filter.Matches(&order) {
+			keys = append(keys, key{createdKey(order.DateCreated), order.OrderUID})
+		}
+	}
+	MR.mu.RUnlock()
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.dateCreated, b.dateCreated), cmp.Compare(a.orderUID, b.orderUID))
+	})
+
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		MR.mu.RLock()
+		order, ok := MR.orders[k.orderUID]
+		if ok {
+			order = cloneOrder(order)
+		}
+		MR.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"time"
+)
+
+// instrumentedRepository measures latency of every OrderRepository call
+type instrumentedRepository struct {
+	next OrderRepository
+}
+
+// NewInstrumentedRepository wraps repo so that duration and outcome of each call are exported to Prometheus
+func NewInstrumentedRepository(repo OrderRepository) OrderRepository {
+	return &instrumentedRepository{next: repo}
+}
+
+func (IR *instrumentedRepository) AddNewOrder(ctx context.Context, neworder *model.Order) error {
+	start := time.Now()
+	err := IR.next.AddNewOrder(ctx, neworder)
+	metrics.ObserveQuery("AddNewOrder", start, err)
+	return err
+}
+
+func (IR *instrumentedRepository) AddNewOrders(ctx context.Context, orders []*model.Order) error {
+	start := time.Now()
+	err := IR.next.AddNewOrders(ctx, orders)
+	metrics.ObserveQuery("AddNewOrders", start, err)
+	return err
+}
+
+func (IR *instrumentedRepository) GetOrderByUID(ctx context.Context, uid string) (*model.Order, error) {
+	start := time.Now()
+	order, err := IR.next.GetOrderByUID(ctx, uid)
+	metrics.ObserveQuery("GetOrderByUID", start, err)
+	return order, err
+}
+
+func (IR *instrumentedRepository) PushOrderToRawTable(ctx context.Context, brokenOrder model.InvalidRequest) error {
+	start := time.Now()
+	err := IR.next.PushOrderToRawTable(ctx, brokenOrder)
+	metrics.ObserveQuery("PushOrderToRawTable", start, err)
+	return err
+}
+
+func (IR *instrumentedRepository) GetAllOrders(ctx context.Context) ([]model.Order, error) {
+	start := time.Now()
+	orders, err := IR.next.GetAllOrders(ctx)
+	metrics.ObserveQuery("GetAllOrders", start, err)
+	return orders, err
+}
+
+func (IR *instrumentedRepository) ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error) {
+	start := time.Now()
+	orders, err := IR.next.ListOrders(ctx, filter, after)
+	metrics.ObserveQuery("ListOrders", start, err)
+	return orders, err
+}
+
+func (IR *instrumentedRepository) ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error) {
+	start := time.Now()
+	requests, err := IR.next.ListInvalidRequests(ctx, status, limit, offset)
+	metrics.ObserveQuery("ListInvalidRequests", start, err)
+	return requests, err
+}
+
+func (IR *instrumentedRepository) GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	start := time.Now()
+	req, err := IR.next.GetInvalidRequest(ctx, id)
+	metrics.ObserveQuery("GetInvalidRequest", start, err)
+	return req, err
+}
+
+func (IR *instrumentedRepository) UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+	start := time.Now()
+	err := IR.next.UpdateInvalidRequest(ctx, req, fromStatus)
+	metrics.ObserveQuery("UpdateInvalidRequest", start, err)
+	return err
+}
+
+func (IR *instrumentedRepository) ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+	start := time.Now()
+	order, err := IR.next.ApplyOrderEvent(ctx, event)
+	metrics.ObserveQuery("ApplyOrderEvent", start, err)
+	return order, err
+}
+
+func (IR *instrumentedRepository) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	start := time.Now()
+	analytics, err := IR.next.GetAnalytics(ctx, filter)
+	metrics.ObserveQuery("GetAnalytics", start, err)
+	return analytics, err
+}
+
+func (IR *instrumentedRepository) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := IR.next.Ping(ctx)
+	metrics.ObserveQuery("Ping", start, err)
+	return err
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"orderservice/internal/model"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// getAllOrdersLimit mirrors LIMIT of GetAllOrders in the GORM implementation
+const getAllOrdersLimit = 1000
+
+// memoryRepository keeps everything in process memory; it follows the semantics of orderRepository:
+// gorm.ErrRecordNotFound for missing records, ErrOrderExists for existing order_uid, the same sort orders.
+// Strings are compared byte-wise, while Postgres may use locale collation
+type memoryRepository struct {
+	mu            sync.RWMutex
+	orders        map[string]model.Order
+	invalid       map[uint]model.InvalidRequest
+	nextInvalidID uint
+	events        map[string]struct{} //примененные EventID
+	history       []model.OrderHistory
+	outbox        []model.OutboxMessage
+	nextOutboxID  uint64
+}
+
+// NewMemoryRepository returns OrderRepository storing data in memory, used for tests and local runs without Postgres
+func NewMemoryRepository() OrderRepository {
+	return &memoryRepository{
+		orders:  make(map[string]model.Order),
+		invalid: make(map[uint]model.InvalidRequest),
+		events:  make(map[string]struct{}),
+	}
+}
+
+// AddNewOrder stores a copy of the order; returns ErrOrderExists if order_uid already exists
+func (MR *memoryRepository) AddNewOrder(ctx context.Context, neworder *model.Order) error {
+	return MR.AddNewOrders(ctx, []*model.Order{neworder})
+}
+
+// AddNewOrders stores all orders or none of them
+func (MR *memoryRepository) AddNewOrders(ctx context.Context, orders []*model.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+
+	batch := make(map[string]struct{}, len(orders))
+	for _, order := range orders {
+		_, stored := MR.orders[order.OrderUID]
+		_, inBatch := batch[order.OrderUID]
+		if stored || inBatch {
+			return fmt.Errorf("order %s: %w: %w", order.OrderUID, ErrOrderExists, gorm.ErrDuplicatedKey)
+		}
+		batch[order.OrderUID] = struct{}{}
+	}
+	for _, order := range orders {
+		clearDetailIDs(order)
+		MR.orders[order.OrderUID] = cloneOrder(*order)
+		if err := MR.appendOutbox(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrderByUID returns a copy of the stored order or gorm.ErrRecordNotFound
+func (MR *memoryRepository) GetOrderByUID(ctx context.Context, uid string) (*model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	MR.mu.RLock()
+	defer MR.mu.RUnlock()
+	order, ok := MR.orders[uid]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	order = cloneOrder(order)
+	return &order, nil
+}
+
+// GetAllOrders returns up to 1000 orders, newest(by date_created) first
+func (MR *memoryRepository) GetAllOrders(ctx context.Context) ([]model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	MR.mu.RLock()
+	orders := make([]model.Order, 0, len(MR.orders))
+	for _, order := range MR.orders {
+		orders = append(orders, cloneOrder(order))
+	}
+	MR.mu.RUnlock()
+
+	slices.SortFunc(orders, func(a, b model.Order) int {
+		return cmp.Or(cmp.Compare(createdKey(b.DateCreated), createdKey(a.DateCreated)), cmp.Compare(a.OrderUID, b.OrderUID))
+	})
+	if len(orders) > getAllOrdersLimit {
+		orders = orders[:getAllOrdersLimit]
+	}
+	return orders, nil
+}
+
+// ListOrders returns orders matching the filter, sorted by filter.SortBy and order_uid, starting after the cursor(if any)
+func (MR *memoryRepository) ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, ok := model.SortableOrderFields[filter.SortBy]; !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", filter.SortBy)
+	}
+	key := func(o *model.Order) string {
+		switch filter.SortBy {
+		case "order_uid":
+			return o.OrderUID
+		case "customer_id":
+			return o.CustomerID
+		case "track_number":
+			return o.TrackNumber
+		default:
+			return createdKey(o.DateCreated)
+		}
+	}
+	compare := func(a, b *model.Order) int {
+		c := cmp.Or(cmp.Compare(key(a), key(b)), cmp.Compare(a.OrderUID, b.OrderUID))
+		if filter.SortDesc {
+			return -c
+		}
+		return c
+	}
+
+	var afterKey string
+	if after != nil {
+		afterKey = after.SortValue
+		if filter.SortBy == "date_created" {
+			afterKey = createdKey(afterKey)
+		}
+	}
+
+	MR.mu.RLock()
+	var orders []model.Order
+	for _, order := range MR.orders {
+		if !filter.Matches(&order) {
+			continue
+		}
+		if after != nil {
+			//keyset-пагинация: берем только записи строго после курсора в порядке сортировки
+			c := cmp.Or(cmp.Compare(key(&order), afterKey), cmp.Compare(order.OrderUID, after.OrderUID))
+			if (!filter.SortDesc && c <= 0) || (filter.SortDesc && c >= 0) {
+				continue
+			}
+		}
+		orders = append(orders, cloneOrder(order))
+	}
+	MR.mu.RUnlock()
+
+	slices.SortFunc(orders, func(a, b model.Order) int { return compare(&a, &b) })
+	if filter.Limit > 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+	}
+	return orders, nil
+}
+
+// createdKey returns date_created in UTC with fixed-width fraction of a second: such keys compare as texts
+// in the same order as the times, like order_created_at of the Postgres repository
+func createdKey(dateCreated string) string {
+	t, err := time.Parse(time.RFC3339, dateCreated)
+	if err != nil {
+		return dateCreated
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// PushOrderToRawTable stores rejected message with a new sequential ID
+func (MR *memoryRepository) PushOrderToRawTable(ctx context.Context, brokenOrder model.InvalidRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+	MR.nextInvalidID++
+	id := MR.nextInvalidID
+	brokenOrder.ID = &id
+	MR.invalid[id] = brokenOrder
+	return nil
+}
+
+// ListInvalidRequests returns rejected messages with the given status(any status if empty), newest first
+func (MR *memoryRepository) ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	MR.mu.RLock()
+	var requests []model.InvalidRequest
+	for _, req := range MR.invalid {
+		if status == "" || req.Status == status {
+			requests = append(requests, cloneInvalidRequest(req))
+		}
+	}
+	MR.mu.RUnlock()
+
+	slices.SortFunc(requests, func(a, b model.InvalidRequest) int { return cmp.Compare(*b.ID, *a.ID) })
+	if offset >= len(requests) {
+		return nil, nil
+	}
+	requests = requests[offset:]
+	if limit > 0 && len(requests) > limit {
+		requests = requests[:limit]
+	}
+	return requests, nil
+}
+
+// GetInvalidRequest finds rejected message by its ID or returns gorm.ErrRecordNotFound
+func (MR *memoryRepository) GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	MR.mu.RLock()
+	defer MR.mu.RUnlock()
+	req, ok := MR.invalid[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	req = cloneInvalidRequest(req)
+	return &req, nil
+}
+
+// UpdateInvalidRequest saves payload, error, status and attempts counter of an existing rejected message if its status is still fromStatus
+func (MR *memoryRepository) UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+	if req.ID == nil {
+		return gorm.ErrMissingWhereClause
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+	stored, ok := MR.invalid[*req.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if stored.Status != fromStatus {
+		return ErrStatusChanged
+	}
+	stored.RawJSON = req.RawJSON
+	stored.ErrorMessage = req.ErrorMessage
+	stored.Status = req.Status
+	stored.Attempts = req.Attempts
+	stored.UpdatedAt = req.UpdatedAt
+	MR.invalid[*req.ID] = stored
+	return nil
+}
+
+// ApplyOrderEvent applies event atomically with the same rules as the GORM implementation
+func (MR *memoryRepository) ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+
+	if _, seen := MR.events[event.EventID]; seen {
+		return nil, ErrDuplicateEvent
+	}
+	current, exists := MR.orders[event.OrderUID]
+	if exists && event.Version <= current.Version {
+		return nil, ErrStaleVersion
+	}
+
+	var result model.Order
+	switch event.EventType {
+	case model.EventOrderCreated, model.EventOrderUpdated:
+		result = cloneOrder(*event.Order)
+		result.OrderUID = event.OrderUID
+		result.Version = event.Version
+		result.CancelledAt = nil
+		clearDetailIDs(&result)
+	case model.EventOrderCancelled:
+		if !exists {
+			return nil, gorm.ErrRecordNotFound
+		}
+		cancelledAt := event.OccurredAt.Time
+		if cancelledAt.IsZero() {
+			cancelledAt = time.Now().UTC()
+		}
+		result = cloneOrder(current)
+		result.Version = event.Version
+		result.CancelledAt = &cancelledAt
+	default:
+		return nil, errors.New("unknown event type: " + event.EventType)
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	id := uint(len(MR.history) + 1)
+	MR.history = append(MR.history, model.OrderHistory{
+		ID:        &id,
+		OrderUID:  event.OrderUID,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Version:   event.Version,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	})
+	MR.events[event.EventID] = struct{}{}
+	MR.orders[event.OrderUID] = result
+	if !exists {
+		if err := MR.appendOutbox(&result); err != nil {
+			return nil, err
+		}
+	}
+
+	result = cloneOrder(result)
+	return &result, nil
+}
+
+// Ping always succeeds
+func (MR *memoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// cloneOrder returns a deep copy so that callers cannot modify stored data
+func cloneOrder(o model.Order) model.Order {
+	o.Items = slices.Clone(o.Items)
+	if o.CancelledAt != nil {
+		t := *o.CancelledAt
+		o.CancelledAt = &t
+	}
+	return o
+}
+
+func cloneInvalidRequest(r model.InvalidRequest) model.InvalidRequest {
+	if r.ID != nil {
+		id := *r.ID
+		r.ID = &id
+	}
+	return r
+}
+
+// clearDetailIDs resets generated IDs and sets order_uid of nested records like insertOrderTx does
+func clearDetailIDs(o *model.Order) {
+	o.Delivery.DID = nil
+	o.Delivery.OrderUID = o.OrderUID
+	o.Payment.PID = nil
+	o.Payment.OrderUID = o.OrderUID
+	for i := range o.Items {
+		o.Items[i].IID = nil
+		o.Items[i].OrderUID = o.OrderUID
+	}
+}
@@ -0,0 +1,403 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orderservice/internal/model"
+	"orderservice/internal/resilience"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func testOrder(uid, created string) *model.Order {
+	return &model.Order{
+		OrderUID:    uid,
+		CustomerID:  "c-" + uid,
+		DateCreated: created,
+		Payment:     model.Payment{Provider: "wbpay", Bank: "alpha"},
+		Items:       []model.Item{{Brand: "Vivienne Sabo", NMID: 1}},
+	}
+}
+
+func TestMemoryRepository_Orders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	if _, err := repo.GetOrderByUID(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+	if err := repo.AddNewOrder(ctx, testOrder("a", "2021-11-26T06:22:19Z")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddNewOrder(ctx, testOrder("a", "2021-11-26T06:22:19Z")); !errors.Is(err, ErrOrderExists) || !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expected ErrOrderExists, got %v", err)
+	}
+
+	// пачка атомарна: дубликат внутри пачки отменяет всю вставку
+	err := repo.AddNewOrders(ctx, []*model.Order{testOrder("b", "2021-11-27T06:22:19Z"), testOrder("b", "2021-11-27T06:22:19Z")})
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expected ErrDuplicatedKey, got %v", err)
+	}
+	if _, err := repo.GetOrderByUID(ctx, "b"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("batch must be rolled back, got %v", err)
+	}
+
+	if err := repo.AddNewOrders(ctx, []*model.Order{testOrder("b", "2021-11-27T06:22:19Z"), testOrder("c", "2021-11-25T06:22:19Z")}); err != nil {
+		t.Fatal(err)
+	}
+	all, err := repo.GetAllOrders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uids(all); got != "[b a c]" {
+		t.Errorf("GetAllOrders must return newest first, got %s", got)
+	}
+
+	// возвращенные данные - копии
+	order, _ := repo.GetOrderByUID(ctx, "a")
+	order.Items[0].Brand = "changed"
+	if stored, _ := repo.GetOrderByUID(ctx, "a"); stored.Items[0].Brand != "Vivienne Sabo" {
+		t.Error("stored order must not be modified through returned copy")
+	}
+}
+
+func TestMemoryRepository_ListOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for i := range 5 {
+		o := testOrder(fmt.Sprintf("o%d", i), fmt.Sprintf("2021-11-2%dT00:00:00Z", i))
+		if i == 2 {
+			o.Items[0].Brand = "Other"
+		}
+		if err := repo.AddNewOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := model.OrderFilter{SortBy: "date_created", SortDesc: true, Limit: 2, Brand: "Vivienne Sabo"}
+	page, err := repo.ListOrders(ctx, filter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uids(page); got != "[o4 o3]" {
+		t.Fatalf("first page: got %s", got)
+	}
+	after := &model.OrderCursor{SortValue: page[1].DateCreated, OrderUID: page[1].OrderUID}
+	page, err = repo.ListOrders(ctx, filter, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uids(page); got != "[o1 o0]" {
+		t.Fatalf("second page must skip filtered o2: got %s", got)
+	}
+
+	if _, err := repo.ListOrders(ctx, model.OrderFilter{SortBy: "amount"}, nil); err == nil {
+		t.Error("expected error for unsupported sort field")
+	}
+
+	// даты с другим смещением и долями секунды сортируются по времени, а не как текст
+	for uid, created := range map[string]string{
+		"msk":   "2021-11-26T03:00:00+03:00", //2021-11-26T00:00:00Z
+		"frac":  "2021-11-25T23:59:59.5Z",
+		"whole": "2021-11-25T23:59:59Z",
+	} {
+		if err := repo.AddNewOrder(ctx, testOrder(uid, created)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filter = model.OrderFilter{SortBy: "date_created", Limit: 2, DateFrom: time.Date(2021, 11, 25, 12, 0, 0, 0, time.UTC)}
+	page, _ = repo.ListOrders(ctx, filter, nil)
+	after = &model.OrderCursor{SortValue: page[1].DateCreated, OrderUID: page[1].OrderUID}
+	next, _ := repo.ListOrders(ctx, filter, after)
+	if got := uids(append(page, next...)); got != "[whole frac msk]" {
+		t.Errorf("expected orders in time order across pages, got %s", got)
+	}
+}
+
+func TestMemoryRepository_InvalidRequests(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for _, status := range []string{model.InvalidStatusNew, model.InvalidStatusDiscarded, model.InvalidStatusNew} {
+		if err := repo.PushOrderToRawTable(ctx, model.InvalidRequest{Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := repo.ListInvalidRequests(ctx, model.InvalidStatusNew, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || *list[0].ID != 3 || *list[1].ID != 1 {
+		t.Fatalf("expected New requests 3 and 1, got %+v", list)
+	}
+
+	req := list[0]
+	req.Status = model.InvalidStatusResolved
+	if err := repo.UpdateInvalidRequest(ctx, &req, model.InvalidStatusNew); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.GetInvalidRequest(ctx, 3); got.Status != model.InvalidStatusResolved {
+		t.Errorf("expected Resolved, got %s", got.Status)
+	}
+	// статус уже изменен другим запросом
+	req.Status = model.InvalidStatusDiscarded
+	if err := repo.UpdateInvalidRequest(ctx, &req, model.InvalidStatusNew); !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("expected ErrStatusChanged, got %v", err)
+	}
+	missing := uint(42)
+	if err := repo.UpdateInvalidRequest(ctx, &model.InvalidRequest{ID: &missing}, model.InvalidStatusNew); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+	if _, err := repo.GetInvalidRequest(ctx, missing); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestMemoryRepository_ApplyOrderEvent(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	created := &model.OrderEvent{EventID: "e1", EventType: model.EventOrderCreated, OrderUID: "a", Version: 1, Order: testOrder("", "2021-11-26T06:22:19Z")}
+	order, err := repo.ApplyOrderEvent(ctx, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderUID != "a" || order.Version != 1 {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	if _, err := repo.ApplyOrderEvent(ctx, created); !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("expected ErrDuplicateEvent, got %v", err)
+	}
+	stale := &model.OrderEvent{EventID: "e2", EventType: model.EventOrderUpdated, OrderUID: "a", Version: 1, Order: testOrder("", "x")}
+	if _, err := repo.ApplyOrderEvent(ctx, stale); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("expected ErrStaleVersion, got %v", err)
+	}
+
+	cancelled, err := repo.ApplyOrderEvent(ctx, &model.OrderEvent{EventID: "e3", EventType: model.EventOrderCancelled, OrderUID: "a", Version: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.CancelledAt == nil || cancelled.Version != 2 || cancelled.DateCreated != "2021-11-26T06:22:19Z" {
+		t.Errorf("unexpected cancelled order: %+v", cancelled)
+	}
+	_, err = repo.ApplyOrderEvent(ctx, &model.OrderEvent{EventID: "e4", EventType: model.EventOrderCancelled, OrderUID: "b", Version: 1})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("cancelling unknown order: expected ErrRecordNotFound, got %v", err)
+	}
+}
+
+func uids(orders []model.Order) string {
+	res := make([]string, len(orders))
+	for i, o := range orders {
+		res[i] = o.OrderUID
+	}
+	return fmt.Sprint(res)
+}
+
+func TestMemoryRepository_Outbox(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	if err := repo.AddNewOrders(ctx, []*model.Order{testOrder("a", "2021-11-26T06:22:19Z"), testOrder("b", "2021-11-27T06:22:19Z")}); err != nil {
+		t.Fatal(err)
+	}
+	// отмененная пачка не оставляет сообщений
+	_ = repo.AddNewOrders(ctx, []*model.Order{testOrder("c", "2021-11-27T06:22:19Z"), testOrder("a", "2021-11-26T06:22:19Z")})
+
+	outbox := repo.(OutboxRepository)
+	claim := OutboxClaim{Limit: 10, Lease: time.Minute}
+	var claimed []model.OutboxMessage
+	published, failed, err := outbox.ClaimOutbox(ctx, claim, func(msgs []model.OutboxMessage) []error {
+		claimed = msgs
+		return []error{nil, errors.New("broker unavailable")}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 1 || failed != 1 || len(claimed) != 2 || claimed[0].AggregateID != "a" || claimed[1].AggregateID != "b" {
+		t.Fatalf("expected messages for a and b with one published, got %d of %+v", published, claimed)
+	}
+	if claimed[0].EventType != model.EventOrderPersisted {
+		t.Errorf("unexpected event type %q", claimed[0].EventType)
+	}
+
+	// неудачное сообщение возвращается в следующий раз
+	claimed = nil
+	published, _, _ = outbox.ClaimOutbox(ctx, claim, func(msgs []model.OutboxMessage) []error {
+		claimed = msgs
+		return nil
+	})
+	if published != 1 || len(claimed) != 1 || claimed[0].AggregateID != "b" || claimed[0].Attempts != 1 || claimed[0].LastError == "" {
+		t.Fatalf("expected retry of b, got %d of %+v", published, claimed)
+	}
+
+	if deleted, _ := outbox.DeleteOutboxPublished(ctx, time.Now().Add(-time.Hour)); deleted != 0 {
+		t.Errorf("recent messages must be kept, deleted %d", deleted)
+	}
+	if deleted, _ := outbox.DeleteOutboxPublished(ctx, time.Now().Add(time.Second)); deleted != 2 {
+		t.Errorf("expected 2 deleted messages, got %d", deleted)
+	}
+}
+
+func TestMemoryRepository_OutboxRetries(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	if err := repo.AddNewOrders(ctx, []*model.Order{testOrder("bad", "2021-11-26T06:22:19Z")}); err != nil {
+		t.Fatal(err)
+	}
+	outbox := repo.(OutboxRepository)
+	claim := OutboxClaim{Limit: 10, Lease: time.Minute, MaxAttempts: 2, Backoff: resilience.Backoff{Initial: time.Hour, Max: time.Hour}}
+	fail := func(msgs []model.OutboxMessage) []error { return []error{errors.New("message too large")} }
+	claimAll := func() (n int) {
+		_, _, err := outbox.ClaimOutbox(ctx, OutboxClaim{Limit: 10, Lease: time.Minute}, func(msgs []model.OutboxMessage) []error {
+			n = len(msgs)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// пока сообщение публикуется, другой вызов его не получает
+	_, _, _ = outbox.ClaimOutbox(ctx, claim, func(msgs []model.OutboxMessage) []error {
+		if n := claimAll(); n != 0 {
+			t.Errorf("leased message claimed again by %d call", n)
+		}
+		return fail(msgs)
+	})
+	// неудачное сообщение ждет паузы и не задерживает новые
+	if err := repo.AddNewOrders(ctx, []*model.Order{testOrder("good", "2021-11-26T06:22:19Z")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := claimAll(); n != 1 {
+		t.Fatalf("expected only the new message before backoff expires, got %d", n)
+	}
+
+	// после MaxAttempts неудач сообщение откладывается
+	memRepo := repo.(*memoryRepository)
+	memRepo.outbox[0].AvailableAt = nil
+	if _, failed, _ := outbox.ClaimOutbox(ctx, claim, fail); failed != 1 {
+		t.Fatalf("expected second failed attempt, got %d", failed)
+	}
+	if msg := memRepo.outbox[0]; msg.Attempts != 2 || msg.FailedAt == nil {
+		t.Fatalf("expected parked message, got %+v", msg)
+	}
+	memRepo.outbox[0].AvailableAt = nil
+	if n := claimAll(); n != 0 {
+		t.Errorf("parked message must not be published, got %d", n)
+	}
+}
+
+func TestMemoryRepository_GetAnalytics(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	order := func(uid, created, currency string, amount uint, region string, items ...model.Item) *model.Order {
+		o := testOrder(uid, created)
+		o.DeliveryService = "meest"
+		o.Delivery.Region = region
+		o.Payment.Currency, o.Payment.Amount = currency, amount
+		o.Items = items
+		return o
+	}
+	shoes := model.Item{Brand: "Nike", NMID: 1, Name: "Shoes", TotalPrice: 100}
+	hat := model.Item{Brand: "Adidas", NMID: 2, Name: "Cap", TotalPrice: 30}
+	err := repo.AddNewOrders(ctx, []*model.Order{
+		order("a", "2024-01-01T10:00:00Z", "USD", 130, "Kraiot", shoes, hat), //понедельник
+		order("b", "2024-01-03T10:00:00Z", "USD", 60, "Kraiot", hat, hat),
+		order("c", "2024-01-08T10:00:00Z", "RUB", 100, "Moscow", shoes),
+		order("d", "2024-01-09T10:00:00Z", "USD", 100, "Moscow", shoes),
+		order("old", "2023-12-01T10:00:00Z", "USD", 999, "Kraiot", shoes),
+		order("cancelled", "2024-01-02T10:00:00Z", "USD", 999, "Kraiot", shoes),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ApplyOrderEvent(ctx, &model.OrderEvent{EventID: "e1", EventType: model.EventOrderCancelled, OrderUID: "cancelled", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	analytics, err := repo.GetAnalytics(ctx, model.AnalyticsFilter{
+		DateFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Interval: model.IntervalWeek,
+		TopBy:    model.TopByQuantity,
+		Limit:    10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(analytics.Revenue); got != fmt.Sprint([]model.RevenuePoint{
+		{Period: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 2, Revenue: 190},
+		{Period: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Currency: "RUB", Orders: 1, Revenue: 100},
+		{Period: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 1, Revenue: 100},
+	}) {
+		t.Errorf("unexpected weekly revenue %s", got)
+	}
+	if got := fmt.Sprint(analytics.TopBrands); got != "[{Adidas USD 3 90} {Nike USD 2 200} {Nike RUB 1 100}]" {
+		t.Errorf("unexpected top brands by quantity %s", got)
+	}
+	if got := fmt.Sprint(analytics.Deliveries); got != "[{meest Kraiot 2} {meest Moscow 2}]" {
+		t.Errorf("unexpected deliveries %s", got)
+	}
+	if got := fmt.Sprint(analytics.Baskets); got != "[{USD 3 290 96.66666666666667 1.6666666666666667} {RUB 1 100 100 1}]" {
+		t.Errorf("unexpected baskets %s", got)
+	}
+
+	analytics, err = repo.GetAnalytics(ctx, model.AnalyticsFilter{
+		DateFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Interval: model.IntervalDay,
+		TopBy:    model.TopByRevenue,
+		Limit:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analytics.Revenue) != 4 || fmt.Sprint(analytics.TopProducts) != "[{1 Shoes Nike USD 2 200}]" {
+		t.Errorf("unexpected daily revenue %v or top product by revenue %v", analytics.Revenue, analytics.TopProducts)
+	}
+}
+
+func TestMemoryRepository_StreamOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for _, o := range []*model.Order{
+		testOrder("c", "2024-01-02T00:00:00Z"),
+		testOrder("b", "2024-01-01T00:00:00Z"),
+		testOrder("a", "2024-01-02T00:00:00Z"),
+		testOrder("old", "2023-12-31T00:00:00Z"),
+	} {
+		if err := repo.AddNewOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exportRepo := repo.(ExportRepository)
+	filter := model.OrderFilter{DateFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var uids []string
+	err := exportRepo.StreamOrders(ctx, filter, 2, func(order *model.Order) error {
+		order.Items[0].Brand = "changed" // копия, хранилище не меняется
+		uids = append(uids, order.OrderUID)
+		return nil
+	})
+	if err != nil || !slices.Equal(uids, []string{"b", "a", "c"}) {
+		t.Fatalf("streamed %v, %v; want [b a c] ordered by date_created and order_uid", uids, err)
+	}
+	if stored, _ := repo.GetOrderByUID(ctx, "a"); stored.Items[0].Brand != "Vivienne Sabo" {
+		t.Error("streamed order must be a copy")
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = exportRepo.StreamOrders(ctx, filter, 2, func(order *model.Order) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		t.Errorf("error of fn must stop the stream, got %v after %d orders", err, n)
+	}
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"orderservice/internal/resilience"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxRepository gives the outbox relay access to messages written together with orders;
+// implemented by both Postgres and in-memory repositories
+type OutboxRepository interface {
+	// ClaimOutbox leases up to claim.Limit due messages, oldest first(messages leased by another instance are skipped),
+	// and passes them to publish, which returns an error for every message(nil if it was delivered). Messages are not
+	// locked while publish runs: the lease is taken and the result is stored in separate short transactions.
+	// Delivered messages are marked published; failed ones and ones that can't be decrypted get attempts+1 and last_error
+	// and are retried after claim.Backoff, or parked(failed_at) after claim.MaxAttempts. If the process dies before the result
+	// is stored, the messages are published again when the lease expires. Returns the number of delivered and failed messages
+	ClaimOutbox(ctx context.Context, claim OutboxClaim, publish func(msgs []model.OutboxMessage) []error) (published, failed int, err error)
+	// DeleteOutboxPublished removes messages published before the given time; returns the number of removed rows
+	DeleteOutboxPublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OutboxClaim describes how ClaimOutbox leases and retries messages
+type OutboxClaim struct {
+	Limit       int
+	Lease       time.Duration      // на это время сообщения закрепляются за вызовом, чтобы другие экземпляры их не брали
+	MaxAttempts int                // после стольких неудач сообщение откладывается и больше не публикуется; 0 - без ограничения
+	Backoff     resilience.Backoff // пауза перед повтором неудачного сообщения, растет с числом попыток
+}
+
+// fail records a failed attempt to publish msg
+func (OC OutboxClaim) fail(msg *model.OutboxMessage, err error, now time.Time) {
+	msg.Attempts++
+	msg.LastError = err.Error()
+	next := now.Add(OC.Backoff.Delay(msg.Attempts - 1))
+	msg.AvailableAt = &next
+	if OC.MaxAttempts > 0 && msg.Attempts >= OC.MaxAttempts {
+		msg.FailedAt = &now
+	}
+}
+
+// logParked reports a message that ran out of attempts: it stays in the table until an operator resets failed_at
+func logParked(ctx context.Context, msg *model.OutboxMessage) {
+	if msg.FailedAt != nil {
+		slog.ErrorContext(ctx, "Outbox message parked after max attempts", "id", msg.ID, "aggregate_id", msg.AggregateID,
+			"attempts", msg.Attempts, "last_error", msg.LastError)
+	}
+}
+
+// newOutboxMessage builds order.persisted message for a newly stored order
+func newOutboxMessage(order *model.Order) (model.OutboxMessage, error) {
+	now := time.Now().UTC()
+	payload, err := json.Marshal(model.OrderPersistedEvent{
+		EventID:    order.OrderUID + ":persisted",
+		EventType:  model.EventOrderPersisted,
+		OrderUID:   order.OrderUID,
+		OccurredAt: now,
+		Order:      order,
+	})
+	if err != nil {
+		return model.OutboxMessage{}, err
+	}
+	return model.OutboxMessage{
+		EventType:   model.EventOrderPersisted,
+		AggregateID: order.OrderUID,
+		Payload:     string(payload),
+		CreatedAt:   now,
+	}, nil
+}
+
+// insertOutboxTx writes order.persisted message with encrypted payload inside transaction tx
+func (OR *orderRepository) insertOutboxTx(tx *gorm.DB, order *model.Order) error {
+	msg, err := newOutboxMessage(order)
+	if err != nil {
+		return err
+	}
+	if msg.Payload, err = OR.PII.Seal(pii.FieldOutboxPayload, msg.Payload); err != nil {
+		return err
+	}
+	return tx.Create(&msg).Error
+}
+
+// ClaimOutbox implements OutboxRepository; the lease is taken with SELECT ... FOR UPDATE SKIP LOCKED,
+// so several instances can run relays
+func (OR *orderRepository) ClaimOutbox(ctx context.Context, claim OutboxClaim, publish func(msgs []model.OutboxMessage) []error) (int, int, error) {
+	now := time.Now().UTC()
+	var msgs []model.OutboxMessage
+	err := OR.DB.WithContext(ctx).Raw(`UPDATE outbox_messages SET available_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE published_at IS NULL AND failed_at IS NULL AND (available_at IS NULL OR available_at <= ?)
+			ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED
+		) RETURNING *`, now.Add(claim.Lease), now, claim.Limit).Scan(&msgs).Error
+	if err != nil || len(msgs) == 0 {
+		return 0, 0, err
+	}
+	slices.SortFunc(msgs, func(a, b model.OutboxMessage) int { return cmp.Compare(a.ID, b.ID) }) //RETURNING не сохраняет порядок
+
+	//сообщение, которое не удалось расшифровать, считается неудачной попыткой и не мешает публикации остальных
+	errs := make([]error, len(msgs))
+	var ready []model.OutboxMessage
+	var readyIdx []int
+	for i := range msgs {
+		payload, err := OR.PII.Open(pii.FieldOutboxPayload, msgs[i].Payload)
+		if err != nil {
+			errs[i] = fmt.Errorf("decrypt payload: %w", err)
+			continue
+		}
+		msg := msgs[i]
+		msg.Payload = payload
+		ready = append(ready, msg)
+		readyIdx = append(readyIdx, i)
+	}
+	if len(ready) > 0 {
+		publishErrs := publish(ready)
+		for i, j := range readyIdx {
+			if publishErrs != nil {
+				errs[j] = publishErrs[i]
+			}
+		}
+	}
+
+	//результат сохраняется и при остановке: иначе доставленные сообщения будут опубликованы повторно
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	now = time.Now().UTC()
+	published, failed := 0, 0
+	err = OR.DB.WithContext(saveCtx).Transaction(func(tx *gorm.DB) error {
+		var delivered []uint64
+		for i := range msgs {
+			msg := &msgs[i]
+			if errs[i] == nil {
+				delivered = append(delivered, msg.ID)
+				continue
+			}
+			claim.fail(msg, errs[i], now)
+			logParked(ctx, msg)
+			err := tx.Model(&model.OutboxMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+				"attempts":     msg.Attempts,
+				"last_error":   msg.LastError,
+				"available_at": msg.AvailableAt,
+				"failed_at":    msg.FailedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(delivered) > 0 {
+			if err := tx.Model(&model.OutboxMessage{}).Where("id IN ?", delivered).Update("published_at", now).Error; err != nil {
+				return err
+			}
+		}
+		published, failed = len(delivered), len(msgs)-len(delivered)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return published, failed, nil
+}
+
+// DeleteOutboxPublished implements OutboxRepository
+func (OR *orderRepository) DeleteOutboxPublished(ctx context.Context, before time.Time) (int64, error) {
+	res := OR.DB.WithContext(ctx).Where("published_at IS NOT NULL AND published_at < ?", before).Delete(&model.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
+
+// ClaimOutbox implements OutboxRepository; the repository lock is released while publish runs
+func (MR *memoryRepository) ClaimOutbox(ctx context.Context, claim OutboxClaim, publish func(msgs []model.OutboxMessage) []error) (int, int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	msgs := MR.leaseOutbox(claim, time.Now().UTC())
+	if len(msgs) == 0 {
+		return 0, 0, nil
+	}
+	errs := publish(msgs)
+
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+	now := time.Now().UTC()
+	published, failed := 0, 0
+	for i, msg := range msgs {
+		//сообщение могли удалить, пока шла публикация; индексы тоже могли сдвинуться
+		j := slices.IndexFunc(MR.outbox, func(m model.OutboxMessage) bool { return m.ID == msg.ID })
+		if j < 0 {
+			continue
+		}
+		if errs == nil || errs[i] == nil {
+			MR.outbox[j].PublishedAt = &now
+			published++
+			continue
+		}
+		claim.fail(&MR.outbox[j], errs[i], now)
+		logParked(ctx, &MR.outbox[j])
+		failed++
+	}
+	return published, failed, nil
+}
+
+// leaseOutbox returns copies of up to claim.Limit due messages and leases them
+func (MR *memoryRepository) leaseOutbox(claim OutboxClaim, now time.Time) []model.OutboxMessage {
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+	until := now.Add(claim.Lease)
+	var msgs []model.OutboxMessage
+	for i := range MR.outbox {
+		msg := &MR.outbox[i]
+		if msg.PublishedAt != nil || msg.FailedAt != nil || (msg.AvailableAt != nil && msg.AvailableAt.After(now)) {
+			continue
+		}
+		msg.AvailableAt = &until
+		msgs = append(msgs, *msg)
+		if len(msgs) == claim.Limit {
+			break
+		}
+	}
+	return msgs
+}
+
+// DeleteOutboxPublished implements OutboxRepository
+func (MR *memoryRepository) DeleteOutboxPublished(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	MR.mu.Lock()
+	defer MR.mu.Unlock()
+	kept := MR.outbox[:0]
+	var deleted int64
+	for _, msg := range MR.outbox {
+		if msg.PublishedAt != nil && msg.PublishedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	MR.outbox = kept
+	return deleted, nil
+}
+
+// appendOutbox adds order.persisted message; caller holds MR.mu
+func (MR *memoryRepository) appendOutbox(order *model.Order) error {
+	msg, err := newOutboxMessage(order)
+	if err != nil {
+		return err
+	}
+	MR.nextOutboxID++
+	msg.ID = MR.nextOutboxID
+	MR.outbox = append(MR.outbox, msg)
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PIIRepository re-encrypts stored personal data; implemented by the Postgres repository only,
+// the in-memory one keeps nothing at rest
+type PIIRepository interface {
+	// ReencryptPII rewrites stored values which are plain, encrypted with an old key or no longer covered by the policy,
+	// batchSize rows per transaction. Rows locked by other transactions are skipped and picked up by the next run.
+	// Returns the number of rewritten rows
+	ReencryptPII(ctx context.Context, batchSize int) (int, error)
+}
+
+// piiTable lists columns with personal data of a table
+type piiTable struct {
+	name    string
+	key     string
+	columns []string
+	fields  []string // поле политики pii для каждой колонки
+}
+
+var piiTables = []piiTable{
+	{
+		name:    "deliveries",
+		key:     "d_id",
+		columns: []string{"name", "phone", "email", "address", "zip", "city", "region"},
+		fields: []string{pii.FieldDeliveryName, pii.FieldDeliveryPhone, pii.FieldDeliveryEmail, pii.FieldDeliveryAddress,
+			pii.FieldDeliveryZip, pii.FieldDeliveryCity, pii.FieldDeliveryRegion},
+	},
+	{name: "payments", key: "p_id", columns: []string{"transaction", "request_id"}, fields: []string{pii.FieldPaymentTx, pii.FieldPaymentRequest}},
+	{name: "invalid_requests", key: "id", columns: []string{"raw_json"}, fields: []string{pii.FieldRawJSON}},
+	{name: "order_histories", key: "id", columns: []string{"payload"}, fields: []string{pii.FieldHistoryPayload}},
+	{name: "outbox_messages", key: "id", columns: []string{"payload"}, fields: []string{pii.FieldOutboxPayload}},
+}
+
+// sealed returns copy of the order to be stored, with personal data encrypted; items are shared with order
+func (OR *orderRepository) sealed(order *model.Order) (*model.Order, error) {
+	stored := *order
+	if err := OR.PII.SealFields(stored.PIIFields()); err != nil {
+		return nil, fmt.Errorf("order %s: %w", order.OrderUID, err)
+	}
+	return &stored, nil
+}
+
+// open decrypts personal data of a stored order in place
+func (OR *orderRepository) open(order *model.Order) error {
+	if err := OR.PII.OpenFields(order.PIIFields()); err != nil {
+		return fmt.Errorf("order %s: %w", order.OrderUID, err)
+	}
+	return nil
+}
+
+func (OR *orderRepository) openAll(orders []model.Order) error {
+	for i := range orders {
+		if err := OR.open(&orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (OR *orderRepository) openRequest(req *model.InvalidRequest) error {
+	raw, err := OR.PII.Open(pii.FieldRawJSON, req.RawJSON)
+	if err != nil {
+		return fmt.Errorf("invalid request %d: %w", *req.ID, err)
+	}
+	req.RawJSON = raw
+	return nil
+}
+
+// ReencryptPII implements PIIRepository; tables are processed one by one with keyset pagination by primary key
+func (OR *orderRepository) ReencryptPII(ctx context.Context, batchSize int) (int, error) {
+	if OR.PII == nil {
+		return 0, errors.New("PII keys are not configured")
+	}
+	total := 0
+	for _, table := range piiTables {
+		n, err := OR.reencryptTable(ctx, table, batchSize)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", table.name, err)
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "PII re-encrypted", "table", table.name, "rows", n, "key", OR.PII.ActiveKey())
+		}
+	}
+	return total, nil
+}
+
+func (OR *orderRepository) reencryptTable(ctx context.Context, table piiTable, batchSize int) (int, error) {
+	where, args := OR.staleCondition(table)
+	var last uint64
+	total := 0
+	for {
+		n, next, err := OR.reencryptBatch(ctx, table, where, args, last, batchSize)
+		total += n
+		if err != nil || next == 0 {
+			return total, err
+		}
+		last = next
+	}
+}
+
+// staleCondition selects rows with at least one column to rewrite, see pii.Codec.Stale
+func (OR *orderRepository) staleCondition(table piiTable) (string, []any) {
+	active := pii.Prefix + OR.PII.ActiveKey() + ":"
+	var conds []string
+	var args []any
+	for i, column := range table.columns {
+		if OR.PII.Covers(table.fields[i]) {
+			conds = append(conds, fmt.Sprintf("(%s <> '' AND NOT starts_with(%[1]s, ?))", column))
+			args = append(args, active)
+		} else {
+			conds = append(conds, fmt.Sprintf("starts_with(%s, ?)", column))
+			args = append(args, pii.Prefix)
+		}
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// reencryptBatch rewrites up to batchSize stale rows with key greater than after in one transaction.
+// Returns the number of rewritten rows and the last selected key(0 if there are no more rows)
+func (OR *orderRepository) reencryptBatch(ctx context.Context, table piiTable, where string, args []any, after uint64, batchSize int) (int, uint64, error) {
+	rewritten := 0
+	var last uint64
+	err := OR.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []map[string]any
+		err := tx.Table(table.name).Select(append([]string{table.key}, table.columns...)).
+			Where(table.key+" > ?", after).Where(where, args...).
+			Order(table.key).Limit(batchSize).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		for _, row := range rows {
+			updates := make(map[string]any, len(table.columns))
+			for i, column := range table.columns {
+				value, _ := row[column].(string)
+				if !OR.PII.Stale(table.fields[i], value) {
+					continue
+				}
+				rewrittenValue, err := OR.PII.Rewrite(table.fields[i], value)
+				if err != nil {
+					return fmt.Errorf("%s %v: %w", table.key, row[table.key], err)
+				}
+				updates[column] = rewrittenValue
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Table(table.name).Where(table.key+" = ?", row[table.key]).Updates(updates).Error; err != nil {
+				return err
+			}
+			rewritten++
+		}
+		key, ok := rows[len(rows)-1][table.key].(int64)
+		if !ok {
+			return fmt.Errorf("unexpected type %T of %s", rows[len(rows)-1][table.key], table.key)
+		}
+		last = uint64(key)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	metrics.PIIReencrypted.WithLabelValues(table.name).Add(float64(rewritten))
+	return rewritten, last, nil
+}
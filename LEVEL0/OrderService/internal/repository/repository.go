@@ -3,213 +3,265 @@ package repository
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
 	"orderservice/internal/model"
-	"strings"
-	"sync"
+	"orderservice/internal/pii"
 	"sync/atomic"
-	"time"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type OrderRepository interface {
 	AddNewOrder(ctx context.Context, neworder *model.Order) error
+	AddNewOrders(ctx context.Context, orders []*model.Order) error
 	GetOrderByUID(ctx context.Context, uid string) (*model.Order, error)
 	PushOrderToRawTable(ctx context.Context, brokenOrder model.InvalidRequest) error
 	GetAllOrders(ctx context.Context) ([]model.Order, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error)
+	ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error)
+	GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error)
+	UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error
+	ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (*model.Order, error)
+	GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error)
+	Ping(ctx context.Context) error
 }
 
 type orderRepository struct {
-	DB           *gorm.DB
-	dsn          string      //для переподключения если отвалилась база
-	reconnecting atomic.Bool //флаг запущенного переподключения к БД
-	sync.Mutex               //для предотвращения множественного вызова connectWithRetry из других экземпляров хендлеров при отвале БД
+	DB       *gorm.DB   // primary: записи и чтения, которым нужно последнее состояние
+	Replicas []*gorm.DB // реплики для чтения заказов и списков; пусто - все запросы идут в primary
+	PII      *pii.Codec // nil - персональные данные хранятся открыто
+	next     atomic.Uint32
 }
 
-func NewOrderRepository(db *gorm.DB, dsnDB string) OrderRepository {
-	return &orderRepository{DB: db, dsn: dsnDB}
+// NewOrderRepository returns Postgres repository; every method makes a single attempt,
+// retries and circuit breaker are added by NewResilientRepository.
+// Personal data is encrypted with codec before writing and decrypted after reading.
+// GetOrderByUID and list queries are spread over read replicas(if any) round-robin unless ctx is of WithPrimary,
+// everything else goes to the primary.
+// Lost connections are restored by the sql.DB pool on the next query
+func NewOrderRepository(db *gorm.DB, codec *pii.Codec, replicas ...*gorm.DB) OrderRepository {
+	return &orderRepository{DB: db, PII: codec, Replicas: replicas}
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns ctx whose reads are not sent to replicas: for reads that must see the latest committed state,
+// such as existence checks before a write or orders to be cached
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// read runs query on the next replica; if the replica is unavailable the query is repeated on the primary,
+// so a single replica outage does not affect readers. Queries with ctx of WithPrimary go to the primary
+func (OR *orderRepository) read(ctx context.Context, query func(db *gorm.DB) error) error {
+	if len(OR.Replicas) == 0 || ctx.Value(primaryKey{}) != nil {
+		return query(OR.DB)
+	}
+	i := int(OR.next.Add(1)-1) % len(OR.Replicas)
+	err := query(OR.Replicas[i])
+	if err == nil || ctx.Err() != nil || !isUnavailable(err) {
+		return err
+	}
+	metrics.DBReplicaFallbacks.Inc()
+	slog.WarnContext(ctx, "Read replica is unavailable, querying primary", "replica", i+1, logger.Err(err))
+	return query(OR.DB)
 }
 
 // GetOrderByUID finds order by its UUID and provides it with error message(if any)
 func (OR *orderRepository) GetOrderByUID(ctx context.Context, uid string) (*model.Order, error) {
 	var order model.Order
-	for range 3 { //ограничимся тройным циклом вместо рекурсивного вызова всей AddNewOrder
-		err := OR.DB.WithContext(ctx).Preload("Delivery").Preload("Payment").Preload("Items").Where("order_uid = ?", uid).First(&order).Error
-		if err == nil { //если успешно - сразу выходим из цикла и функции
-			return &order, nil
-		}
-
-		if isConnectionError(err) {
-			switch OR.reconnecting.Load() {
-			case true: //если ошибка соединения и уже запущено переподключение - ждем и пробуем снова
-				time.Sleep(15 * time.Second)
-				continue
-			case false:
-				if conErr := OR.connectWithRetry(); conErr != nil { //если не получилось восстановить соединение с одной попытки - выход из функции
-					return nil, conErr
-				}
-				continue
-			}
-		}
+	err := OR.read(ctx, func(db *gorm.DB) error {
+		order = model.Order{}
+		return db.WithContext(ctx).Preload("Delivery").Preload("Payment").Preload("Items").Where("order_uid = ?", uid).First(&order).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := OR.open(&order); err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-// AddNewOrder creates a new record in DB using ctx and transaction
+// AddNewOrder creates order in a single transaction together with its order.persisted outbox message
 func (OR *orderRepository) AddNewOrder(ctx context.Context, neworder *model.Order) error {
-	var tx *gorm.DB
-	neworder.Delivery.DID = nil
-	neworder.Payment.PID = nil
-	for i := range neworder.Items {
-		neworder.Items[i].IID = nil
-	}
-
-	auxFunc := func() error {
-		//вспомогательная функция со всеми транзакциями
-		tx = OR.DB.WithContext(ctx).Begin()
-		if err := tx.Create(&neworder).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
-		neworder.Delivery.OrderUID = neworder.OrderUID
-		if err := tx.Create(&neworder.Delivery).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
-		neworder.Payment.OrderUID = neworder.OrderUID
-		if err := tx.Create(&neworder.Payment).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
-		for i := range neworder.Items {
-			neworder.Items[i].OrderUID = neworder.OrderUID
-		}
-		if err := tx.Create(&neworder.Items).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
-		if err := tx.Commit().Error; err != nil {
-			tx.Rollback()
-			return err
-		}
+	return OR.AddNewOrders(ctx, []*model.Order{neworder})
+}
+
+// AddNewOrders creates several orders with their outbox messages in a single transaction: either all of them are saved or none
+func (OR *orderRepository) AddNewOrders(ctx context.Context, orders []*model.Order) error {
+	if len(orders) == 0 {
 		return nil
 	}
-	for range 3 { //ограничимся тройным циклом вместо рекурсивного вызова всей AddNewOrder
-		err := auxFunc()
-		if err == nil { //если успешно - сразу выходим из цикла и функции
-			return nil
-		}
-
-		if isConnectionError(err) {
-			switch OR.reconnecting.Load() {
-			case true: //если ошибка соединения и уже запущено переподключение - ждем и пробуем снова
-				time.Sleep(15 * time.Second)
-				continue
-			case false:
-				if conErr := OR.connectWithRetry(); conErr != nil { //если не получилось восстановить соединение с одной попытки - выход из функции
-					return conErr
-				}
-				continue
+	return OR.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, order := range orders {
+			if err := OR.insertOrderTx(tx, order); err != nil {
+				return fmt.Errorf("order %s: %w", order.OrderUID, err)
 			}
 		}
-		return err
-	}
-	return nil
+		return nil
+	})
 }
 
-// GetAllOrders retreives existing orders from DB with limit=1000, used for warming up cache at app launch
+// GetAllOrders retreives the newest orders from DB with limit=1000
 func (OR *orderRepository) GetAllOrders(ctx context.Context) ([]model.Order, error) {
 	var orders []model.Order
+	err := OR.read(ctx, func(db *gorm.DB) error {
+		orders = nil
+		return db.WithContext(ctx).Preload("Delivery").Preload("Payment").Preload("Items").Order(orderCreatedAt + " DESC").Limit(1000).Find(&orders).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, OR.openAll(orders)
+}
 
-	for range 3 { //ограничимся тройным циклом вместо рекурсивного вызова всей GetAllOrders
-		err := OR.DB.WithContext(ctx).Preload("Delivery").Preload("Payment").Preload("Items").Order("date_created DESC").Limit(1000).Find(&orders).Error
-		if err == nil { //если успешно - сразу выходим из цикла и функции
-			return orders, nil
-		}
+// ListOrders returns up to filter.Limit orders matching the filter, sorted by filter.SortBy and order_uid, starting after the cursor(if any)
+func (OR *orderRepository) ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error) {
+	var orders []model.Order
 
-		if isConnectionError(err) {
-			switch OR.reconnecting.Load() {
-			case true: //если ошибка соединения и уже запущено переподключение - ждем и пробуем снова
-				time.Sleep(15 * time.Second)
-				continue
-			case false:
-				if conErr := OR.connectWithRetry(); conErr != nil { //если не получилось восстановить соединение с одной попытки - выход из функции
-					return nil, conErr
-				}
-				continue
-			}
+	sortColumn, ok := model.SortableOrderFields[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", filter.SortBy)
+	}
+	sortExpr := "orders." + sortColumn
+	if sortColumn == "date_created" {
+		sortExpr = orderCreatedAt
+	}
+	direction, cmp := "ASC", ">"
+	if filter.SortDesc {
+		direction, cmp = "DESC", "<"
+	}
+
+	err := OR.read(ctx, func(db *gorm.DB) error {
+		orders = nil //запрос мог быть повторен на primary
+		q := applyOrderFilter(db.WithContext(ctx), filter)
+		if after != nil {
+			//keyset-пагинация: order_uid добавлен для однозначности порядка при равных значениях сортировки
+			q = q.Where(fmt.Sprintf("(%s, orders.order_uid) %s (?, ?)", sortExpr, cmp), after.SortValue, after.OrderUID)
 		}
+		return q.Preload("Delivery").Preload("Payment").Preload("Items").
+			Order(fmt.Sprintf("%s %s, orders.order_uid %s", sortExpr, direction, direction)).
+			Limit(filter.Limit).Find(&orders).Error
+	})
+	if err != nil {
 		return nil, err
 	}
-	return orders, nil
+	return orders, OR.openAll(orders)
+}
+
+// orderCreatedAt is date_created as timestamptz(see migration 0006): the expression is indexed together with order_uid,
+// so filters, sorting and cursors by creation time use it instead of the text column
+const orderCreatedAt = "order_created_at(orders.date_created)"
+
+// applyOrderFilter adds WHERE-conditions for non-empty filter fields; payment and item criteria are checked via EXISTS-subqueries
+func applyOrderFilter(q *gorm.DB, filter model.OrderFilter) *gorm.DB {
+	q = q.Model(&model.Order{})
+	if filter.CustomerID != "" {
+		q = q.Where("orders.customer_id = ?", filter.CustomerID)
+	}
+	if filter.TrackNumber != "" {
+		q = q.Where("orders.track_number = ?", filter.TrackNumber)
+	}
+	if filter.DeliveryService != "" {
+		q = q.Where("orders.delivery_service = ?", filter.DeliveryService)
+	}
+	if !filter.DateFrom.IsZero() {
+		q = q.Where(orderCreatedAt+" >= ?", filter.DateFrom)
+	}
+	if !filter.DateTo.IsZero() {
+		q = q.Where(orderCreatedAt+" < ?", filter.DateTo)
+	}
+	if filter.Provider != "" {
+		q = q.Where("EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = orders.order_uid AND p.provider = ?)", filter.Provider)
+	}
+	if filter.Bank != "" {
+		q = q.Where("EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = orders.order_uid AND p.bank = ?)", filter.Bank)
+	}
+	if filter.Brand != "" {
+		q = q.Where("EXISTS (SELECT 1 FROM items i WHERE i.order_uid = orders.order_uid AND i.brand = ?)", filter.Brand)
+	}
+	if filter.NMID != 0 {
+		q = q.Where("EXISTS (SELECT 1 FROM items i WHERE i.order_uid = orders.order_uid AND i.nm_id = ?)", filter.NMID)
+	}
+	return q
 }
 
 // PushOrderToRawTable adds invalid JSONs into separate table for further investigation
 func (OR *orderRepository) PushOrderToRawTable(ctx context.Context, brokenOrder model.InvalidRequest) error {
 	brokenOrder.ID = nil
-	for range 3 { //ограничимся тройным циклом вместо рекурсивного вызова всей PushOrderToRawTable
-		err := OR.DB.Create(&brokenOrder).Error
-		if err == nil { //если успешно - сразу выходим из цикла и функции
-			return nil
-		}
-		if isConnectionError(err) {
-			switch OR.reconnecting.Load() {
-			case true: //если ошибка соединения и уже запущено переподключение - ждем и пробуем снова
-				time.Sleep(15 * time.Second)
-				continue
-			case false:
-				if conErr := OR.connectWithRetry(); conErr != nil { //если не получилось восстановить соединение с одной попытки - выход из функции
-					return conErr
-				}
-				continue
-			}
-		}
+	var err error
+	if brokenOrder.RawJSON, err = OR.PII.Seal(pii.FieldRawJSON, brokenOrder.RawJSON); err != nil {
 		return err
 	}
-	return nil
+	return OR.DB.WithContext(ctx).Create(&brokenOrder).Error
 }
 
-func (OR *orderRepository) connectWithRetry() error {
-	OR.reconnecting.Store(true)
-	defer OR.reconnecting.Store(false)
-
-	OR.Lock()
-	defer OR.Unlock()
-	var db *gorm.DB
-	var err error
-	maxRetries := 3
-	delay := 3 * time.Second
-
-	if sqlDB, err := OR.DB.DB(); err == nil {
-		if errPing := sqlDB.Ping(); errPing == nil {
-			return nil // соединение уже живое
+// ListInvalidRequests returns rejected messages with the given status(any status if empty), newest first
+func (OR *orderRepository) ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error) {
+	var requests []model.InvalidRequest
+	err := OR.read(ctx, func(db *gorm.DB) error {
+		requests = nil
+		q := db.WithContext(ctx).Order("id DESC").Limit(limit).Offset(offset)
+		if status != "" {
+			q = q.Where("status = ?", status)
 		}
+		return q.Find(&requests).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	for i := 0; i < maxRetries; i++ {
-		log.Printf("#%d attempt reconnecting to DB...", i+1)
-		db, err = gorm.Open(postgres.Open(OR.dsn), &gorm.Config{})
-		if err == nil {
-			sqlDB, _ := db.DB()
-			if pingErr := sqlDB.Ping(); pingErr == nil {
-				OR.DB = db
-				log.Println("Successfully reconnected!")
-				return nil
-			} else {
-				err = pingErr
-			}
+	for i := range requests {
+		if err := OR.openRequest(&requests[i]); err != nil {
+			return nil, err
 		}
-		time.Sleep(delay)
 	}
+	return requests, nil
+}
+
+// GetInvalidRequest finds rejected message by its ID
+func (OR *orderRepository) GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	var req model.InvalidRequest
+	if err := OR.DB.WithContext(ctx).Where("id = ?", id).First(&req).Error; err != nil {
+		return nil, err
+	}
+	if err := OR.openRequest(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
 
-	return fmt.Errorf("Could not reconnect after %d retries: %w", maxRetries, err)
+// UpdateInvalidRequest saves payload, error, status and attempts counter of an existing rejected message if its status is still
+// fromStatus, so concurrent replay and discard cannot both succeed; otherwise returns ErrStatusChanged
+func (OR *orderRepository) UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+	if req.ID == nil {
+		return gorm.ErrMissingWhereClause
+	}
+	rawJSON, err := OR.PII.Seal(pii.FieldRawJSON, req.RawJSON)
+	if err != nil {
+		return err
+	}
+	res := OR.DB.WithContext(ctx).Model(&model.InvalidRequest{}).Where("id = ? AND status = ?", *req.ID, fromStatus).Updates(map[string]any{
+		"raw_json":      rawJSON,
+		"error_message": req.ErrorMessage,
+		"status":        req.Status,
+		"attempts":      req.Attempts,
+		"updated_at":    req.UpdatedAt,
+	})
+	if res.Error == nil && res.RowsAffected == 0 {
+		//сообщения не удаляются, значит статус уже изменен другим запросом
+		return ErrStatusChanged
+	}
+	return res.Error
 }
 
-func isConnectionError(err error) bool {
-	return strings.Contains(err.Error(), "bad connection") ||
-		strings.Contains(err.Error(), "connection refused") ||
-		strings.Contains(err.Error(), "connection reset")
+// Ping checks that the primary DB is alive; used by readiness probe. Replicas are not checked: reads fall back to the primary
+func (OR *orderRepository) Ping(ctx context.Context) error {
+	sqlDB, err := OR.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestOrderRepository_ReadRouting(t *testing.T) {
+	ctx := context.Background()
+	primary, replica1, replica2 := &gorm.DB{}, &gorm.DB{}, &gorm.DB{}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	var used []*gorm.DB
+	query := func(failing *gorm.DB, err error) func(db *gorm.DB) error {
+		return func(db *gorm.DB) error {
+			used = append(used, db)
+			if db == failing {
+				return err
+			}
+			return nil
+		}
+	}
+
+	OR := NewOrderRepository(primary, nil).(*orderRepository)
+	if err := OR.read(ctx, query(nil, nil)); err != nil || used[0] != primary {
+		t.Fatalf("expected primary without replicas, err %v", err)
+	}
+
+	// реплики по очереди
+	used = nil
+	OR = NewOrderRepository(primary, nil, replica1, replica2).(*orderRepository)
+	for range 3 {
+		if err := OR.read(ctx, query(nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(used) != 3 || used[0] != replica1 || used[1] != replica2 || used[2] != replica1 {
+		t.Errorf("expected round-robin over replicas")
+	}
+
+	// недоступная реплика - повтор на primary
+	used = nil
+	if err := OR.read(ctx, query(replica2, refused)); err != nil || len(used) != 2 || used[1] != primary {
+		t.Errorf("expected fallback to primary, err %v, queries %d", err, len(used))
+	}
+
+	// чтения, которым нужно последнее состояние, идут в primary
+	used = nil
+	if err := OR.read(WithPrimary(ctx), query(nil, nil)); err != nil || len(used) != 1 || used[0] != primary {
+		t.Errorf("expected primary for WithPrimary context, err %v", err)
+	}
+
+	// прочие ошибки возвращаются как есть
+	used = nil
+	if err := OR.read(ctx, query(replica1, gorm.ErrRecordNotFound)); err != gorm.ErrRecordNotFound || len(used) != 1 {
+		t.Errorf("expected not found from replica without fallback, err %v, queries %d", err, len(used))
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"orderservice/internal/resilience"
+	"time"
+)
+
+// ResilienceConfig describes retry policy and circuit breaker of the repository
+type ResilienceConfig struct {
+	MaxAttempts      int // всего попыток одного вызова, включая первую
+	RetryBackoff     time.Duration
+	MaxRetryBackoff  time.Duration
+	RetryJitter      float64
+	FailureThreshold int           // вызовов подряд, завершившихся недоступностью БД, после которых цепь размыкается
+	OpenTimeout      time.Duration // через сколько после размыкания пробовать снова
+}
+
+// resilientRepository retries transient DB errors and fails fast with ErrUnavailable while the DB is down,
+// so callers are not blocked by a dead DB and can fall back to cache
+type resilientRepository struct {
+	next    OrderRepository
+	policy  resilience.Policy
+	breaker *resilience.Breaker
+}
+
+// NewResilientRepository wraps repo with retries and circuit breaker; Ping is passed through as is,
+// so readiness probe reflects actual DB state
+func NewResilientRepository(repo OrderRepository, cfg ResilienceConfig) OrderRepository {
+	return &resilientRepository{
+		next: repo,
+		policy: resilience.Policy{
+			MaxAttempts: max(cfg.MaxAttempts, 1),
+			Backoff:     resilience.Backoff{Initial: cfg.RetryBackoff, Max: cfg.MaxRetryBackoff, Jitter: cfg.RetryJitter},
+		},
+		breaker: resilience.NewBreaker(resilience.BreakerConfig{
+			FailureThreshold: cfg.FailureThreshold,
+			OpenTimeout:      cfg.OpenTimeout,
+			IsFailure:        isUnavailable,
+			OnStateChange: func(from, to resilience.State) {
+				metrics.RepositoryCircuitState.Set(float64(to))
+				if to == resilience.StateOpen {
+					slog.Error("DB circuit breaker opened, serving from cache only", "from", from.String())
+				} else {
+					slog.Info("DB circuit breaker state changed", "from", from.String(), "to", to.String())
+				}
+			},
+		}),
+	}
+}
+
+// call runs op through the circuit breaker and retry policy; idempotent operations are retried on any transient error,
+// others only if the query surely was not applied
+func (RR *resilientRepository) call(ctx context.Context, method string, idempotent bool, op func(ctx context.Context) error) error {
+	retryable := safeToRetry
+	if idempotent {
+		retryable = IsTransient
+	}
+	err := RR.breaker.DoContext(ctx, func() error {
+		return RR.policy.Retry(ctx, retryable, func(retry int, err error) {
+			metrics.RepositoryRetries.WithLabelValues(method).Inc()
+			slog.WarnContext(ctx, "Transient DB error, retrying", "method", method, "retry", retry+1, logger.Err(err))
+		}, op)
+	})
+	if errors.Is(err, resilience.ErrOpen) {
+		metrics.RepositoryCircuitRejections.Inc()
+		return ErrUnavailable
+	}
+	return err
+}
+
+func (RR *resilientRepository) AddNewOrder(ctx context.Context, neworder *model.Order) error {
+	return RR.call(ctx, "AddNewOrder", false, func(ctx context.Context) error {
+		return RR.next.AddNewOrder(ctx, neworder)
+	})
+}
+
+func (RR *resilientRepository) AddNewOrders(ctx context.Context, orders []*model.Order) error {
+	return RR.call(ctx, "AddNewOrders", false, func(ctx context.Context) error {
+		return RR.next.AddNewOrders(ctx, orders)
+	})
+}
+
+func (RR *resilientRepository) GetOrderByUID(ctx context.Context, uid string) (order *model.Order, err error) {
+	err = RR.call(ctx, "GetOrderByUID", true, func(ctx context.Context) error {
+		order, err = RR.next.GetOrderByUID(ctx, uid)
+		return err
+	})
+	return order, err
+}
+
+func (RR *resilientRepository) PushOrderToRawTable(ctx context.Context, brokenOrder model.InvalidRequest) error {
+	return RR.call(ctx, "PushOrderToRawTable", false, func(ctx context.Context) error {
+		return RR.next.PushOrderToRawTable(ctx, brokenOrder)
+	})
+}
+
+func (RR *resilientRepository) GetAllOrders(ctx context.Context) (orders []model.Order, err error) {
+	err = RR.call(ctx, "GetAllOrders", true, func(ctx context.Context) error {
+		orders, err = RR.next.GetAllOrders(ctx)
+		return err
+	})
+	return orders, err
+}
+
+func (RR *resilientRepository) ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) (orders []model.Order, err error) {
+	err = RR.call(ctx, "ListOrders", true, func(ctx context.Context) error {
+		orders, err = RR.next.ListOrders(ctx, filter, after)
+		return err
+	})
+	return orders, err
+}
+
+func (RR *resilientRepository) ListInvalidRequests(ctx context.Context, status string, limit, offset int) (requests []model.InvalidRequest, err error) {
+	err = RR.call(ctx, "ListInvalidRequests", true, func(ctx context.Context) error {
+		requests, err = RR.next.ListInvalidRequests(ctx, status, limit, offset)
+		return err
+	})
+	return requests, err
+}
+
+func (RR *resilientRepository) GetInvalidRequest(ctx context.Context, id uint) (req *model.InvalidRequest, err error) {
+	err = RR.call(ctx, "GetInvalidRequest", true, func(ctx context.Context) error {
+		req, err = RR.next.GetInvalidRequest(ctx, id)
+		return err
+	})
+	return req, err
+}
+
+// UpdateInvalidRequest is not idempotent: a repeated update of an applied one would find the status already changed
+func (RR *resilientRepository) UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+	return RR.call(ctx, "UpdateInvalidRequest", false, func(ctx context.Context) error {
+		return RR.next.UpdateInvalidRequest(ctx, req, fromStatus)
+	})
+}
+
+// ApplyOrderEvent is idempotent: repeated event with the same EventID is skipped by the repository
+func (RR *resilientRepository) ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (order *model.Order, err error) {
+	err = RR.call(ctx, "ApplyOrderEvent", true, func(ctx context.Context) error {
+		order, err = RR.next.ApplyOrderEvent(ctx, event)
+		return err
+	})
+	return order, err
+}
+
+func (RR *resilientRepository) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (analytics *model.Analytics, err error) {
+	err = RR.call(ctx, "GetAnalytics", true, func(ctx context.Context) error {
+		analytics, err = RR.next.GetAnalytics(ctx, filter)
+		return err
+	})
+	return analytics, err
+}
+
+func (RR *resilientRepository) Ping(ctx context.Context) error {
+	return RR.next.Ping(ctx)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"orderservice/internal/model"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestIsTransient(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	for _, tc := range []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{gorm.ErrRecordNotFound, false},
+		{gorm.ErrDuplicatedKey, false},
+		{context.Canceled, false},
+		{&pgconn.PgError{Code: "23502"}, false},
+		{context.DeadlineExceeded, true},
+		{ErrUnavailable, true},
+		{fmt.Errorf("order a: %w", refused), true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "57P03"}, true},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{errors.New("connection refused"), false}, //текст ошибки не анализируется
+	} {
+		if got := IsTransient(tc.err); got != tc.transient {
+			t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.transient)
+		}
+	}
+}
+
+// flakyRepo fails calls with the queued errors before delegating to the memory repository
+type flakyRepo struct {
+	OrderRepository
+	errs  []error
+	calls int
+}
+
+func (f *flakyRepo) fail() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyRepo) GetOrderByUID(ctx context.Context, uid string) (*model.Order, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.OrderRepository.GetOrderByUID(ctx, uid)
+}
+
+func (f *flakyRepo) AddNewOrder(ctx context.Context, order *model.Order) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.OrderRepository.AddNewOrder(ctx, order)
+}
+
+func testResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{MaxAttempts: 3, RetryBackoff: time.Millisecond, MaxRetryBackoff: time.Millisecond, FailureThreshold: 2, OpenTimeout: time.Hour}
+}
+
+func TestResilientRepository_Retries(t *testing.T) {
+	ctx := context.Background()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	flaky := &flakyRepo{OrderRepository: NewMemoryRepository()}
+	repo := NewResilientRepository(flaky, testResilienceConfig())
+
+	// запрос не дошел до сервера - запись можно повторить
+	flaky.errs = []error{&pgconn.ConnectError{}}
+	if err := repo.AddNewOrder(ctx, testOrder("a", "2021-11-26T06:22:19Z")); err != nil || flaky.calls != 2 {
+		t.Fatalf("expected write to succeed on retry, got %v after %d calls", err, flaky.calls)
+	}
+	// соединение оборвалось во время запроса - запись могла примениться, повторять нельзя
+	flaky.calls, flaky.errs = 0, []error{io.ErrUnexpectedEOF}
+	if err := repo.AddNewOrder(ctx, testOrder("b", "2021-11-26T06:22:19Z")); !errors.Is(err, io.ErrUnexpectedEOF) || flaky.calls != 1 {
+		t.Fatalf("expected write not to be retried, got %v after %d calls", err, flaky.calls)
+	}
+	// чтение повторяется при любой временной ошибке
+	flaky.calls, flaky.errs = 0, []error{io.ErrUnexpectedEOF, refused}
+	if order, err := repo.GetOrderByUID(ctx, "a"); err != nil || order.OrderUID != "a" || flaky.calls != 3 {
+		t.Fatalf("expected read to succeed on 3rd attempt, got %v after %d calls", err, flaky.calls)
+	}
+	// ошибки, не связанные с доступностью БД, не повторяются
+	flaky.calls = 0
+	if _, err := repo.GetOrderByUID(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) || flaky.calls != 1 {
+		t.Fatalf("expected ErrRecordNotFound without retries, got %v after %d calls", err, flaky.calls)
+	}
+}
+
+func TestResilientRepository_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	flaky := &flakyRepo{OrderRepository: NewMemoryRepository()}
+	repo := NewResilientRepository(flaky, testResilienceConfig())
+
+	flaky.errs = []error{refused, refused, refused, refused, refused, refused}
+	for range 2 {
+		if _, err := repo.GetOrderByUID(ctx, "a"); !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Fatalf("expected connection error, got %v", err)
+		}
+	}
+	flaky.calls = 0
+	if _, err := repo.GetOrderByUID(ctx, "a"); !errors.Is(err, ErrUnavailable) || flaky.calls != 0 {
+		t.Fatalf("expected fast ErrUnavailable, got %v after %d calls", err, flaky.calls)
+	}
+	if !IsTransient(ErrUnavailable) {
+		t.Error("ErrUnavailable must be transient so that consumer retries the batch")
+	}
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("Ping must bypass circuit breaker, got %v", err)
+	}
+}
+
+func TestResilientRepository_CallerDeadline(t *testing.T) {
+	flaky := &flakyRepo{OrderRepository: NewMemoryRepository()}
+	repo := NewResilientRepository(flaky, testResilienceConfig())
+
+	// медленные запросы, прерванные дедлайном клиента, не означают недоступность БД
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		flaky.errs = []error{fmt.Errorf("query: %w", context.DeadlineExceeded)}
+		if _, err := repo.GetOrderByUID(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected caller deadline error, got %v", err)
+		}
+		cancel()
+	}
+	flaky.calls = 0
+	if _, err := repo.GetOrderByUID(context.Background(), "a"); !errors.Is(err, gorm.ErrRecordNotFound) || flaky.calls != 1 {
+		t.Fatalf("caller deadlines must not open circuit breaker, got %v after %d calls", err, flaky.calls)
+	}
+
+	// тайм-аут без дедлайна вызывающего - недоступность БД
+	flaky.errs = []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded}
+	for range 2 {
+		_, _ = repo.GetOrderByUID(context.Background(), "a")
+	}
+	if _, err := repo.GetOrderByUID(context.Background(), "a"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected open circuit breaker after DB timeouts, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State of a circuit breaker
+type State int
+
+const (
+	StateClosed   State = iota // вызовы проходят, неудачи подсчитываются
+	StateHalfOpen              // пропускаются пробные вызовы, чтобы проверить, восстановилась ли зависимость
+	StateOpen                  // вызовы отклоняются сразу
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// ErrOpen is returned without calling the dependency while the breaker is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// BreakerConfig describes when the breaker opens and how it recovers
+type BreakerConfig struct {
+	FailureThreshold int           // неудачных вызовов подряд, после которых цепь размыкается
+	OpenTimeout      time.Duration // сколько цепь разомкнута до пробных вызовов
+	HalfOpenMaxCalls int           // одновременных пробных вызовов
+	IsFailure        func(error) bool
+	OnStateChange    func(from, to State) // вызывается под блокировкой, не должна обращаться к Breaker
+}
+
+// Breaker fails calls fast after FailureThreshold consecutive failures; after OpenTimeout a few probe calls are let through,
+// the first successful probe closes the circuit, a failed one opens it again.
+// Errors for which IsFailure is false(e.g. "not found") mean the dependency works; canceled calls and calls that ran out
+// of the caller's deadline are not counted at all
+type Breaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int //пробных вызовов в процессе
+}
+
+// NewBreaker returns closed breaker with defaults for zero config fields; every error is a failure if IsFailure is nil
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(error) bool { return true }
+	}
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// Do runs op if the breaker allows it, otherwise returns ErrOpen
+func (b *Breaker) Do(op func() error) error {
+	return b.DoContext(context.Background(), op)
+}
+
+// DoContext is Do for an operation bound to ctx: if ctx is done when op fails, the failure is not counted,
+// the caller's own deadline says nothing about the dependency
+func (b *Breaker) DoContext(ctx context.Context, op func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = op()
+	b.record(probe, err != nil && ctx.Err() != nil, err)
+	return err
+}
+
+// State returns current state; an open breaker whose timeout has passed is reported as half-open
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, ErrOpen
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenMaxCalls {
+			return false, ErrOpen
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *Breaker) record(probe, abandoned bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probes--
+	}
+	failure := err != nil && b.cfg.IsFailure(err)
+	switch {
+	case err != nil && (abandoned || errors.Is(err, context.Canceled)):
+		//вызов прерван клиентом или истек его дедлайн - о состоянии зависимости ничего не известно
+	case b.state == StateHalfOpen && probe:
+		if failure {
+			b.open()
+		} else {
+			b.failures = 0
+			b.setState(StateClosed)
+		}
+	case b.state == StateClosed:
+		if !failure {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open()
+		}
+	}
+	//результаты вызовов, начатых до размыкания, не меняют состояние
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.now()
+	b.setState(StateOpen)
+}
+
+func (b *Breaker) setState(to State) {
+	if b.state == to {
+		return
+	}
+	from := b.state
+	b.state = to
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,151 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	for retry, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := b.Delay(retry); got != want*time.Millisecond {
+			t.Errorf("retry %d: expected %v, got %v", retry, want*time.Millisecond, got)
+		}
+	}
+	// большое число повторов не переполняет задержку
+	if got := b.Delay(100); got != time.Second {
+		t.Errorf("expected max delay, got %v", got)
+	}
+
+	b.Jitter = 0.5
+	for range 100 {
+		if got := b.Delay(3); got < 400*time.Millisecond || got > 800*time.Millisecond {
+			t.Fatalf("jittered delay %v is out of [400ms, 800ms]", got)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if !Sleep(context.Background(), time.Millisecond) {
+		t.Error("expected completed sleep")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if Sleep(ctx, time.Hour) || time.Since(start) > time.Second {
+		t.Error("sleep must stop when ctx is done")
+	}
+}
+
+func TestPolicy_Retry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+	p := Policy{MaxAttempts: 3, Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond}}
+
+	calls, retries := 0, 0
+	err := p.Retry(context.Background(), retryable, func(int, error) { retries++ }, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 || retries != 2 {
+		t.Fatalf("expected success on 3rd attempt, got %v after %d calls and %d retries", err, calls, retries)
+	}
+
+	calls = 0
+	err = p.Retry(context.Background(), retryable, nil, func(context.Context) error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 3 {
+		t.Fatalf("expected last error after 3 attempts, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = p.Retry(context.Background(), retryable, nil, func(context.Context) error {
+		calls++
+		return errFatal
+	})
+	if !errors.Is(err, errFatal) || calls != 1 {
+		t.Fatalf("non-retryable error must not be retried, got %v after %d calls", err, calls)
+	}
+
+	// отмена контекста прерывает паузу
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Backoff = Backoff{Initial: time.Hour, Max: time.Hour}
+	calls = 0
+	err = p.Retry(ctx, retryable, func(int, error) { cancel() }, func(context.Context) error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 1 {
+		t.Fatalf("expected to stop on canceled context, got %v after %d calls", err, calls)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("db is down")
+	errNotFound := errors.New("not found")
+	var transitions []string
+	b := NewBreaker(BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		IsFailure:        func(err error) bool { return errors.Is(err, errDown) },
+		OnStateChange:    func(from, to State) { transitions = append(transitions, to.String()) },
+	})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	fail := func() error { return errDown }
+	ok := func() error { return nil }
+
+	// ошибки, не означающие недоступность, и успехи сбрасывают счетчик
+	_ = b.Do(fail)
+	_ = b.Do(func() error { return errNotFound })
+	_ = b.Do(fail)
+	_ = b.Do(func() error { return context.Canceled })
+	if b.State() != StateClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+	_ = b.Do(fail)
+	if b.State() != StateOpen {
+		t.Fatalf("expected open breaker after 2 failures in a row, got %s", b.State())
+	}
+
+	called := false
+	if err := b.Do(func() error { called = true; return nil }); !errors.Is(err, ErrOpen) || called {
+		t.Fatalf("open breaker must fail fast, got %v", err)
+	}
+
+	// пробный вызов неудачен - цепь снова разомкнута
+	now = now.Add(time.Minute)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected half-open breaker after timeout, got %s", b.State())
+	}
+	_ = b.Do(fail)
+	if err := b.Do(ok); !errors.Is(err, ErrOpen) {
+		t.Fatalf("failed probe must open breaker again, got %v", err)
+	}
+
+	// одновременно пропускается только один пробный вызов, успешный замыкает цепь
+	now = now.Add(time.Minute)
+	err := b.Do(func() error {
+		if err := b.Do(ok); !errors.Is(err, ErrOpen) {
+			t.Errorf("expected concurrent probe to be rejected, got %v", err)
+		}
+		return nil
+	})
+	if err != nil || b.State() != StateClosed {
+		t.Fatalf("successful probe must close breaker, got %v, %s", err, b.State())
+	}
+
+	want := "[open half_open open half_open closed]"
+	if got := fmt.Sprint(transitions); got != want {
+		t.Errorf("expected transitions %s, got %s", want, got)
+	}
+}
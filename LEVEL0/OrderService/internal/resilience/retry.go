@@ -0,0 +1,67 @@
+// Package resilience provides retry with backoff and a circuit breaker for calls to external dependencies
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff describes exponentially growing delays between attempts
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  float64 // доля задержки от 0 до 1, на которую она случайно уменьшается, чтобы клиенты не повторяли запросы одновременно
+}
+
+// Delay returns pause before the given retry(0 - before the first retry): Initial*2^retry capped by Max, reduced by random jitter
+func (b Backoff) Delay(retry int) time.Duration {
+	d := b.Initial
+	for range retry {
+		if d >= b.Max/2 {
+			d = b.Max
+			break
+		}
+		d *= 2
+	}
+	d = min(d, b.Max)
+	if b.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * b.Jitter * float64(d))
+	}
+	return d
+}
+
+// Policy describes how many times and with what pauses an operation is attempted
+type Policy struct {
+	MaxAttempts int // всего попыток, включая первую; 1 - без повторов
+	Backoff     Backoff
+}
+
+// Retry runs op until it succeeds, returns an error for which retryable is false, attempts are exhausted or ctx is done.
+// onRetry(may be nil) is called before every pause. Returns the last error of op
+func (p Policy) Retry(ctx context.Context, retryable func(error) bool, onRetry func(retry int, err error), op func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = op(ctx); err == nil || !retryable(err) || attempt+1 >= p.MaxAttempts {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+		if !Sleep(ctx, p.Backoff.Delay(attempt)) {
+			return err
+		}
+	}
+}
+
+// Sleep waits for d or until ctx is done; returns false if ctx is done
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
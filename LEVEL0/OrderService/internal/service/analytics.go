@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"time"
+)
+
+const (
+	defaultAnalyticsPeriod = 30 * 24 * time.Hour
+	//индекс idx_orders_created_at сужает выборку до периода, но агрегаты считаются по всем заказам и товарам периода,
+	//поэтому время запроса растет с его длиной; год ограничивает стоимость агрегации
+	maxAnalyticsPeriod = 366 * 24 * time.Hour
+	defaultTopLimit    = 10
+	maxTopLimit        = 100
+)
+
+// AnalyticsService provides aggregated order statistics
+type AnalyticsService interface {
+	GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error)
+}
+
+// NewAnalyticsService - returns AnalyticsService; aggregates are always computed by DB, cache is not used
+func NewAnalyticsService(repo repository.OrderRepository) AnalyticsService {
+	return &orderService{Repo: repo}
+}
+
+// GetAnalytics returns order aggregates over the filter; by default - daily revenue and top 10 by quantity for the last 30 days
+func (OS *orderService) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	if err := normalizeAnalyticsFilter(&filter, time.Now()); err != nil {
+		return nil, err
+	}
+	analytics, err := OS.Repo.GetAnalytics(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	//пустые списки вместо null в JSON
+	analytics.Revenue = nonNil(analytics.Revenue)
+	analytics.TopBrands = nonNil(analytics.TopBrands)
+	analytics.TopProducts = nonNil(analytics.TopProducts)
+	analytics.Deliveries = nonNil(analytics.Deliveries)
+	analytics.Baskets = nonNil(analytics.Baskets)
+	return analytics, nil
+}
+
+// normalizeAnalyticsFilter applies defaults relative to now and validates the filter
+func normalizeAnalyticsFilter(filter *model.AnalyticsFilter, now time.Time) error {
+	if filter.DateTo.IsZero() {
+		//сегодняшний день целиком
+		filter.DateTo = model.AnalyticsFilter{}.PeriodStart(now).AddDate(0, 0, 1)
+	}
+	if filter.DateFrom.IsZero() {
+		filter.DateFrom = filter.DateTo.Add(-defaultAnalyticsPeriod)
+	}
+	if !filter.DateFrom.Before(filter.DateTo) {
+		return fmt.Errorf("%w: date_from должна быть раньше date_to", ErrInvalidFilter)
+	}
+	if filter.DateTo.Sub(filter.DateFrom) > maxAnalyticsPeriod {
+		return fmt.Errorf("%w: период не может быть длиннее %d дней", ErrInvalidFilter, int(maxAnalyticsPeriod.Hours()/24))
+	}
+
+	switch filter.Interval {
+	case "":
+		filter.Interval = model.IntervalDay
+	case model.IntervalDay, model.IntervalWeek:
+	default:
+		return fmt.Errorf("%w: interval может быть %q или %q", ErrInvalidFilter, model.IntervalDay, model.IntervalWeek)
+	}
+	switch filter.TopBy {
+	case "":
+		filter.TopBy = model.TopByQuantity
+	case model.TopByQuantity, model.TopByRevenue:
+	default:
+		return fmt.Errorf("%w: top_by может быть %q или %q", ErrInvalidFilter, model.TopByQuantity, model.TopByRevenue)
+	}
+	switch {
+	case filter.Limit < 0:
+		return fmt.Errorf("%w: limit не может быть отрицательным", ErrInvalidFilter)
+	case filter.Limit == 0:
+		filter.Limit = defaultTopLimit
+	case filter.Limit > maxTopLimit:
+		filter.Limit = maxTopLimit
+	}
+	return nil
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
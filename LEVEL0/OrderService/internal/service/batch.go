@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+)
+
+// ProcessBatch handles messages in their original order: plain orders are inserted in one transaction,
+// events are applied one by one between them. Invalid and poison messages go to InvalidRequests and DLQ,
+// duplicates are skipped. Returns error only on transient failure - then nothing may be committed and the whole batch is retried
+func (OS *orderService) ProcessBatch(ctx context.Context, msgs []ingest.Message) error {
+	var (
+		pending     []*model.Order
+		pendingMsgs []*ingest.Message
+		seen        = make(map[string]bool)
+	)
+	flush := func() error {
+		err := OS.persistOrders(ctx, pendingMsgs, pending)
+		pending, pendingMsgs = nil, nil
+		return err
+	}
+
+	for i := range msgs {
+		msg := &msgs[i]
+		msgCtx := msg.Context(ctx)
+		if isEvent(msg.Value) {
+			//события применяются только после уже накопленных заказов - порядок сообщений сохраняется
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := OS.handleResult(msgCtx, msg, OS.applyEvent(msgCtx, msg.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		order, err := decodeOrder(msg.Value)
+		if err != nil {
+			if err := OS.handleResult(msgCtx, msg, err); err != nil {
+				return err
+			}
+			continue
+		}
+		msgCtx = logger.With(msgCtx, logger.KeyOrderUID, order.OrderUID)
+		if seen[order.OrderUID] {
+			slog.InfoContext(msgCtx, "Order is duplicated in the batch, skipped")
+			continue
+		}
+		exists, err := OS.orderExists(msgCtx, order.OrderUID)
+		if err != nil && repository.IsTransient(err) {
+			return err
+		}
+		if exists {
+			slog.InfoContext(msgCtx, "Order already exists, skipped")
+			continue
+		}
+		seen[order.OrderUID] = true
+		pending = append(pending, &order)
+		pendingMsgs = append(pendingMsgs, msg)
+	}
+	return flush()
+}
+
+// persistOrders inserts orders in one transaction; if the transaction fails not because of DB unavailability,
+// orders are inserted one by one so that a single poison message does not block the others
+func (OS *orderService) persistOrders(ctx context.Context, msgs []*ingest.Message, orders []*model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	err := OS.Repo.AddNewOrders(ctx, orders)
+	if err == nil {
+		for _, order := range orders {
+			OS.Cache.Set(*order)
+			OS.watch.publish(model.OrderUpdate{Type: model.EventOrderCreated, Order: *order})
+		}
+		metrics.KafkaMessagesPersisted.WithLabelValues("order").Add(float64(len(orders)))
+		slog.InfoContext(ctx, "Orders created and cached", "count", len(orders))
+		return nil
+	}
+	if repository.IsTransient(err) {
+		return err
+	}
+
+	slog.WarnContext(ctx, "Batch insert failed, retrying one by one", "count", len(orders), logger.Err(err))
+	for i, order := range orders {
+		msgCtx := logger.With(msgs[i].Context(ctx), logger.KeyOrderUID, order.OrderUID)
+		err := OS.Repo.AddNewOrders(msgCtx, []*model.Order{order})
+		if err == nil {
+			OS.Cache.Set(*order)
+			OS.watch.publish(model.OrderUpdate{Type: model.EventOrderCreated, Order: *order})
+			metrics.KafkaMessagesPersisted.WithLabelValues("order").Inc()
+			slog.InfoContext(msgCtx, "Order created and cached")
+			continue
+		}
+		if err := OS.handleResult(msgCtx, msgs[i], err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleResult decides what to do with a message after processing error: transient errors are returned for retry,
+// duplicates are skipped, everything else is rejected into InvalidRequests and DLQ
+func (OS *orderService) handleResult(ctx context.Context, msg *ingest.Message, err error) error {
+	var reason string
+	switch {
+	case err == nil:
+		return nil
+	case repository.IsTransient(err):
+		return err
+	case errors.Is(err, ErrOrderExists):
+		slog.InfoContext(ctx, "Order already exists, skipped", logger.Err(err))
+		return nil
+	case errors.Is(err, ErrJSONDecode):
+		slog.WarnContext(ctx, "Message is not valid JSON, rejecting", logger.Err(err))
+		reason = "decode"
+	case errors.Is(err, ErrIncompleteJson):
+		slog.WarnContext(ctx, "Message failed validation, rejecting", logger.Err(err))
+		reason = "validation"
+	default:
+		slog.ErrorContext(ctx, "Poison message, sending to DLQ", logger.Err(err))
+		reason = "poison"
+	}
+	if err := OS.pushToInvalidRequests(ctx, msg, err); err != nil {
+		return err
+	}
+	//сообщение учитывается один раз, когда отклонено окончательно, а не при каждом повторе пакета
+	metrics.KafkaMessagesRejected.WithLabelValues(reason).Inc()
+	return nil
+}
+
+func isEvent(raw []byte) bool {
+	var probe struct {
+		EventType string `json:"event_type"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.EventType != ""
+}
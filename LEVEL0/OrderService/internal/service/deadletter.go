@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRequestNotFound = errors.New("Отклоненное сообщение не найдено")
+	ErrStatusTransition       = errors.New("Недопустимая смена статуса отклоненного сообщения")
+	ErrUnknownStatus          = errors.New("Неизвестный статус отклоненного сообщения")
+)
+
+const maxInvalidRequestsPage = 100
+
+// DeadLetterService manages rejected messages stored in InvalidRequests: listing, inspection, replay and discarding
+type DeadLetterService interface {
+	ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error)
+	GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error)
+	ReplayInvalidRequest(ctx context.Context, id uint, fixedJSON []byte) (*model.InvalidRequest, error)
+	DiscardInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error)
+}
+
+// NewDeadLetterService - returns DeadLetterService of svc created by NewOrderService: replayed messages go through
+// the same pipeline as svc.Ingest and reach the same cache, WatchOrders subscribers and DLQ
+func NewDeadLetterService(svc OrderService) DeadLetterService {
+	return svc.(*orderService)
+}
+
+// ListInvalidRequests returns rejected messages filtered by status(all statuses if empty)
+func (OS *orderService) ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error) {
+	switch status {
+	case "", model.InvalidStatusNew, model.InvalidStatusRetried, model.InvalidStatusResolved, model.InvalidStatusDiscarded:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+	}
+	if limit <= 0 || limit > maxInvalidRequestsPage {
+		limit = maxInvalidRequestsPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	requests, err := OS.Repo.ListInvalidRequests(ctx, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []model.InvalidRequest{}
+	}
+	return requests, nil
+}
+
+// GetInvalidRequest returns rejected message by its ID
+func (OS *orderService) GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	req, err := OS.Repo.GetInvalidRequest(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRequestNotFound
+	}
+	return req, err
+}
+
+// ReplayInvalidRequest processes rejected message again, optionally replacing its payload with fixedJSON.
+// On success the message becomes Resolved, otherwise Retried with the new error; the processing error is returned as well.
+// An order that already exists(e.g. saved by a concurrent replay) also resolves the message
+func (OS *orderService) ReplayInvalidRequest(ctx context.Context, id uint, fixedJSON []byte) (*model.InvalidRequest, error) {
+	req, err := OS.GetInvalidRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	from := req.Status
+	if isFinalStatus(from) {
+		return req, fmt.Errorf("%w: %s -> %s", ErrStatusTransition, from, model.InvalidStatusResolved)
+	}
+
+	if len(fixedJSON) > 0 {
+		req.RawJSON = string(fixedJSON)
+	}
+	req.Attempts++
+	req.UpdatedAt = time.Now()
+
+	replayErr := OS.processMessage(ctx, []byte(req.RawJSON))
+	if errors.Is(replayErr, ErrOrderExists) {
+		slog.InfoContext(ctx, "Replayed order already exists, resolving", "invalid_request_id", id, logger.Err(replayErr))
+		replayErr = nil
+	}
+	if replayErr == nil {
+		req.Status = model.InvalidStatusResolved
+	} else {
+		req.Status = model.InvalidStatusRetried
+		req.ErrorMessage = errorDetails(replayErr)
+	}
+
+	if err := OS.updateInvalidRequest(ctx, req, from); err != nil {
+		return nil, err
+	}
+	if replayErr != nil && !repository.IsTransient(replayErr) {
+		//сообщение снова отклонено - как и при первичной обработке, оно уходит в DLQ
+		OS.publishDeadLetter(ctx, &ingest.Message{Source: ingest.SourceReplay, Value: []byte(req.RawJSON)}, replayErr)
+	}
+	slog.InfoContext(ctx, "Invalid request replayed", "invalid_request_id", id, "status", req.Status)
+	return req, replayErr
+}
+
+// DiscardInvalidRequest marks rejected message as Discarded so it is not replayed anymore
+func (OS *orderService) DiscardInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	req, err := OS.GetInvalidRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if isFinalStatus(req.Status) {
+		return req, fmt.Errorf("%w: %s -> %s", ErrStatusTransition, req.Status, model.InvalidStatusDiscarded)
+	}
+
+	from := req.Status
+	req.Status = model.InvalidStatusDiscarded
+	req.UpdatedAt = time.Now()
+	if err := OS.updateInvalidRequest(ctx, req, from); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Invalid request discarded", "invalid_request_id", id)
+	return req, nil
+}
+
+// updateInvalidRequest saves req if its status is still from; a concurrent change of the status is ErrStatusTransition
+func (OS *orderService) updateInvalidRequest(ctx context.Context, req *model.InvalidRequest, from string) error {
+	err := OS.Repo.UpdateInvalidRequest(ctx, req, from)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return fmt.Errorf("%w: %s -> %s: статус уже изменен другим запросом", ErrStatusTransition, from, req.Status)
+	}
+	return err
+}
+
+func isFinalStatus(status string) bool {
+	return status == model.InvalidStatusResolved || status == model.InvalidStatusDiscarded
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"orderservice/internal/logger"
+	"orderservice/internal/metrics"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"orderservice/internal/validation"
+)
+
+// processMessage dispatches raw message: versioned order event if it contains "event_type", plain order otherwise
+func (OS *orderService) processMessage(ctx context.Context, raw []byte) error {
+	if isEvent(raw) {
+		return OS.applyEvent(ctx, raw)
+	}
+	return OS.saveOrder(ctx, raw)
+}
+
+// applyEvent decodes, validates and applies versioned order event, then refreshes the cache entry.
+// Idempotency: an event is skipped if its ID(event_id or "<order_uid>:<version>" if absent) has already been applied,
+// or if its version is not greater than the stored order version. Message key is not used as event ID:
+// producers key messages by order_uid to keep events of one order in one partition
+func (OS *orderService) applyEvent(ctx context.Context, raw []byte) error {
+	var event model.OrderEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return fmt.Errorf("%w%v", ErrJSONDecode, err)
+	}
+	if violations := validation.ValidateEvent(&event); len(violations) > 0 {
+		return fmt.Errorf("%w: %w", ErrIncompleteJson, violations)
+	}
+	if event.EventID == "" {
+		event.EventID = fmt.Sprintf("%s:%d", event.OrderUID, event.Version)
+	}
+	ctx = logger.With(ctx,
+		logger.KeyOrderUID, event.OrderUID,
+		"event_id", event.EventID,
+		"event_type", event.EventType,
+		"version", event.Version,
+	)
+
+	order, err := OS.Repo.ApplyOrderEvent(ctx, &event)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrDuplicateEvent), errors.Is(err, repository.ErrStaleVersion):
+		slog.InfoContext(ctx, "Event skipped", "reason", err.Error())
+		return nil
+	default:
+		//состояние заказа в БД неизвестно - убираем его из кеша, чтобы следующее чтение пошло в БД
+		OS.Cache.Delete(event.OrderUID)
+		return fmt.Errorf("event %s for order %s: %w", event.EventID, event.OrderUID, err)
+	}
+
+	OS.Cache.Set(*order)
+	OS.watch.publish(model.OrderUpdate{Type: event.EventType, Order: *order})
+	metrics.KafkaMessagesPersisted.WithLabelValues("event").Inc()
+	slog.InfoContext(ctx, "Event applied")
+	return nil
+}
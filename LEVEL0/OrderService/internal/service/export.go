@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/export"
+	"orderservice/internal/model"
+	"orderservice/internal/repository"
+)
+
+// ExportService writes all orders matching a filter to an export file
+type ExportService interface {
+	// ExportOrders streams orders to w, ordered by date_created and order_uid, and closes w after the last one;
+	// if export fails w is left unclosed, so the file is not finished. Returns the number of written orders
+	ExportOrders(ctx context.Context, filter model.OrderFilter, w export.Writer) (int, error)
+}
+
+type exportService struct {
+	Repo      repository.ExportRepository
+	BatchSize int // заказов за одно чтение из БД
+}
+
+// NewExportService - returns ExportService; orders are read from repo directly, without retries and cache
+func NewExportService(repo repository.ExportRepository, batchSize int) ExportService {
+	return &exportService{Repo: repo, BatchSize: batchSize}
+}
+
+// ExportOrders validates the filter and streams matching orders to w; sorting and pagination fields are ignored
+func (ES *exportService) ExportOrders(ctx context.Context, filter model.OrderFilter, w export.Writer) (int, error) {
+	if !filter.DateFrom.IsZero() && !filter.DateTo.IsZero() && !filter.DateFrom.Before(filter.DateTo) {
+		return 0, fmt.Errorf("%w: date_from должна быть раньше date_to", ErrInvalidFilter)
+	}
+
+	n := 0
+	err := ES.Repo.StreamOrders(ctx, filter, ES.BatchSize, func(order *model.Order) error {
+		if err := w.Write(order); err != nil {
+			return fmt.Errorf("write order %s: %w", order.OrderUID, err)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
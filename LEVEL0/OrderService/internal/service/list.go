@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"orderservice/internal/model"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	defaultSortBy   = "date_created"
+)
+
+// ListOrders returns a page of orders matching the filter; orders are always read from DB, cache is not used,
+// so while DB is unavailable ErrStorageUnavailable is returned
+func (OS *orderService) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	if err := normalizeFilter(&filter); err != nil {
+		return nil, err
+	}
+
+	var after *model.OrderCursor
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != filter.SortBy || cursor.SortDesc != filter.SortDesc {
+			return nil, fmt.Errorf("%w: курсор получен для другой сортировки", ErrInvalidCursor)
+		}
+		after = cursor
+	}
+
+	limit := filter.Limit
+	filter.Limit++ // запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	orders, err := OS.Repo.ListOrders(ctx, filter, after)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = encodeCursor(model.OrderCursor{
+			SortBy:    filter.SortBy,
+			SortDesc:  filter.SortDesc,
+			SortValue: sortValue(&last, filter.SortBy),
+			OrderUID:  last.OrderUID,
+		})
+	}
+	if page.Orders == nil {
+		page.Orders = []model.Order{}
+	}
+	return page, nil
+}
+
+// normalizeFilter applies default sorting and page size and validates the filter
+func normalizeFilter(filter *model.OrderFilter) error {
+	if filter.SortBy == "" {
+		filter.SortBy = defaultSortBy
+		filter.SortDesc = true
+	}
+	if _, ok := model.SortableOrderFields[filter.SortBy]; !ok {
+		return fmt.Errorf("%w: сортировка по полю %q не поддерживается", ErrInvalidFilter, filter.SortBy)
+	}
+	switch {
+	case filter.Limit < 0:
+		return fmt.Errorf("%w: limit не может быть отрицательным", ErrInvalidFilter)
+	case filter.Limit == 0:
+		filter.Limit = defaultPageSize
+	case filter.Limit > maxPageSize:
+		filter.Limit = maxPageSize
+	}
+	if !filter.DateFrom.IsZero() && !filter.DateTo.IsZero() && !filter.DateFrom.Before(filter.DateTo) {
+		return fmt.Errorf("%w: date_from должна быть раньше date_to", ErrInvalidFilter)
+	}
+	return nil
+}
+
+func sortValue(order *model.Order, sortBy string) string {
+	switch sortBy {
+	case "order_uid":
+		return order.OrderUID
+	case "customer_id":
+		return order.CustomerID
+	case "track_number":
+		return order.TrackNumber
+	default:
+		return order.DateCreated
+	}
+}
+
+func encodeCursor(cursor model.OrderCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*model.OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var cursor model.OrderCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if cursor.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
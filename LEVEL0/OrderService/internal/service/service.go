@@ -4,100 +4,140 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"orderservice/internal/cache"
+	"orderservice/internal/ingest"
+	"orderservice/internal/logger"
 	"orderservice/internal/model"
 	"orderservice/internal/repository"
+	"orderservice/internal/validation"
 	"time"
 
-	"github.com/segmentio/kafka-go"
 	"gorm.io/gorm"
 )
 
+// OrderService is the business layer of orders; it does not depend on the transport orders are received from
 type OrderService interface {
-	AddNewOrder(msg *kafka.Message)
+	ingest.Handler
+	Ingest(ctx context.Context, msg *ingest.Message) error
 	GetOrderInfo(ctx context.Context, uid string) (*model.Order, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	WatchOrders(ctx context.Context, filter model.OrderFilter, send func(update *model.OrderUpdate) error) error
 }
 
-// OrderService provides access to repo - DB operations, and contains a Map - cached orders
+// DeadLetterPublisher forwards rejected messages to a dead-letter queue
+type DeadLetterPublisher interface {
+	PublishDeadLetter(ctx context.Context, msg *ingest.Message, reason error) error
+}
+
+// OrderService provides access to repo - DB operations, and contains a Cache - cached orders
 type orderService struct {
-	Repo repository.OrderRepository
-	Map  *cache.OrderMap
+	Repo  repository.OrderRepository
+	Cache cache.OrderCache
+	DLQ   DeadLetterPublisher //может быть nil - тогда отклоненные сообщения сохраняются только в БД
+	watch *watchHub           //подписчики WatchOrders
 }
 
 var (
 	ErrRecordNotFound = errors.New("Запрошенный номер заказа не найдет в базе!")
 	ErrJSONDecode     = errors.New("Ошибка декодирования JSON-сообщения: ")
 	ErrIncompleteJson = errors.New("Json содержит неполные данные")
+	ErrInvalidFilter  = errors.New("Некорректные параметры поиска")
+	ErrInvalidCursor  = errors.New("Некорректный курсор пагинации")
+	ErrOrderExists    = repository.ErrOrderExists
+	// ErrStorageUnavailable means DB is down and circuit breaker is open: only orders from cache are served
+	ErrStorageUnavailable = repository.ErrUnavailable
 )
 
 // NewOrderService - returns *orderService
-func NewOrderService(repo repository.OrderRepository, mapa *cache.OrderMap) OrderService {
-	return &orderService{Repo: repo, Map: mapa}
+func NewOrderService(repo repository.OrderRepository, orderCache cache.OrderCache, dlq DeadLetterPublisher) OrderService {
+	return &orderService{Repo: repo, Cache: orderCache, DLQ: dlq, watch: newWatchHub()}
 }
 
-// AddNewOrder receives rawJson from Kafka consumer and creates new order in DB if rawJSON is valid, otherwise adds broken JSON into table InvalidRequests
-func (OS *orderService) AddNewOrder(msg *kafka.Message) {
-	var order model.Order
-	//Обработка ошибки декодирования
-	if err := json.Unmarshal(msg.Value, &order); err != nil {
-		log.Printf(ErrJSONDecode.Error(), err)
-		OS.pushToInvalidRequests(msg.Value, ErrJSONDecode)
-		return
+// Ingest processes a single message synchronously: unlike ProcessBatch, processing errors(invalid JSON, failed validation,
+// existing order) are returned to the caller instead of being stored in InvalidRequests and DLQ
+func (OS *orderService) Ingest(ctx context.Context, msg *ingest.Message) error {
+	ctx = msg.Context(ctx)
+	if err := OS.processMessage(ctx, msg.Value); err != nil {
+		slog.WarnContext(ctx, "Failed to process message", logger.Err(err))
+		return err
 	}
+	return nil
+}
 
-	//Обработка ошибок валидации данных
-	if !isValidOrderJSON(&order) {
-		log.Println("JSON is incomplete")
-		OS.pushToInvalidRequests(msg.Value, ErrJSONDecode)
-		return
+// saveOrder decodes, validates and saves a single order into DB and cache, every failure is returned to the caller
+func (OS *orderService) saveOrder(ctx context.Context, raw []byte) error {
+	order, err := decodeOrder(raw)
+	if err != nil {
+		return err
 	}
 
-	//Проверка на существование в кеше
-	OS.Map.RLock()
-	_, exists := OS.Map.CacheMap[order.OrderUID]
-	OS.Map.RUnlock()
-	if exists {
-		log.Printf("Заказ с номером '%s' уже существует!", order.OrderUID)
-		return
+	exists, err := OS.orderExists(ctx, order.OrderUID)
+	if err != nil && repository.IsTransient(err) {
+		return err
 	}
-	//Проверка на существование в БД
-	if _, err := OS.GetOrderInfo(context.Background(), order.OrderUID); err == nil {
-		log.Printf("Заказ с номером '%s' уже существует!", order.OrderUID)
-		return
+	if exists {
+		return fmt.Errorf("%w: '%s'", ErrOrderExists, order.OrderUID)
 	}
 
 	// Записываем заказ в базу
-	if err := OS.Repo.AddNewOrder(context.Background(), &order); err != nil {
-		log.Printf("Failed to save order %s to DB: %v", order.OrderUID, err)
-		return
+	if err := OS.Repo.AddNewOrder(ctx, &order); err != nil {
+		return fmt.Errorf("order %s: %w", order.OrderUID, err)
+	}
+	// Обновление кеша
+	OS.Cache.Set(order)
+	OS.watch.publish(model.OrderUpdate{Type: model.EventOrderCreated, Order: order})
+
+	slog.InfoContext(ctx, "Order created and cached", logger.KeyOrderUID, order.OrderUID)
+	return nil
+}
+
+// decodeOrder decodes and validates plain order JSON
+func decodeOrder(raw []byte) (model.Order, error) {
+	var order model.Order
+	//Обработка ошибки декодирования
+	if err := json.Unmarshal(raw, &order); err != nil {
+		return order, fmt.Errorf("%w%v", ErrJSONDecode, err)
+	}
+
+	//Обработка ошибок валидации данных
+	if violations := validation.ValidateOrder(&order); len(violations) > 0 {
+		return order, fmt.Errorf("%w: %w", ErrIncompleteJson, violations)
 	}
-	// Обновление кеша - можно вынести в отдельную функцию
-	OS.Map.Lock()
-	OS.Map.CacheMap[order.OrderUID] = order
-	OS.Map.Unlock()
+	return order, nil
+}
 
-	log.Printf("Order '%s' created and cached", order.OrderUID)
+// orderExists checks cache first, then the primary DB; "not found" is not an error
+func (OS *orderService) orderExists(ctx context.Context, uid string) (bool, error) {
+	if _, exists := OS.Cache.Get(uid); exists {
+		return true, nil
+	}
+	_, err := OS.GetOrderInfo(ctx, uid)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrRecordNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
 }
 
-// GetOrderInfo used only for API-calls, returns model.Order by its uuid from DB if there is any, or nil and error
+// GetOrderInfo used only for API-calls, returns model.Order by its uuid from DB if there is any, or nil and error.
+// While DB is unavailable only cached orders are returned, for others ErrStorageUnavailable is returned without waiting for DB
 func (OS *orderService) GetOrderInfo(ctx context.Context, uid string) (*model.Order, error) {
 	//Проверяем сначала кэш
-	OS.Map.RLock()
-	order, ok := OS.Map.CacheMap[uid]
-	OS.Map.RUnlock()
-
-	if ok {
+	if order, ok := OS.Cache.Get(uid); ok {
 		return &order, nil
 	}
 
-	// В кеше нет, идем в бд:
-	orderFromDB, err := OS.Repo.GetOrderByUID(ctx, uid)
+	// В кеше нет, идем в бд. Кешируемый заказ читается с primary: отстающая реплика вернула бы старую версию
+	// или "не найден", а уведомление о новой версии для некешированного заказа уже пропущено
+	orderFromDB, err := OS.Repo.GetOrderByUID(repository.WithPrimary(ctx), uid)
 	if err == nil {
 		// Обновление кеша
-		OS.Map.Lock()
-		OS.Map.CacheMap[uid] = *orderFromDB
-		OS.Map.Unlock()
+		OS.Cache.Set(*orderFromDB)
 		return orderFromDB, nil
 	}
 
@@ -108,74 +148,51 @@ func (OS *orderService) GetOrderInfo(ctx context.Context, uid string) (*model.Or
 	return nil, err
 }
 
-func (OS *orderService) pushToInvalidRequests(brokenJSON []byte, origErr error) {
-	if err := OS.Repo.PushOrderToRawTable(context.Background(), model.InvalidRequest{
-		ReceivedAt:   time.Now(),
-		RawJSON:      string(brokenJSON),
-		ErrorMessage: origErr.Error(),
-		Status:       "New", //потом можно вынести в отдельный тип
-	}); err != nil {
-		log.Printf("Failed to safe order to table InvalidRequests: %v", err)
+// pushToInvalidRequests saves rejected message into InvalidRequests and publishes it to DLQ;
+// returns error only if the message could not be stored in DB because of a transient failure,
+// then it is not published either: the batch is retried and the message is rejected again
+func (OS *orderService) pushToInvalidRequests(ctx context.Context, msg *ingest.Message, origErr error) error {
+	now := time.Now()
+	err := OS.Repo.PushOrderToRawTable(ctx, model.InvalidRequest{
+		ReceivedAt:   now,
+		UpdatedAt:    now,
+		RawJSON:      string(msg.Value),
+		ErrorMessage: errorDetails(origErr),
+		Status:       model.InvalidStatusNew,
+	})
+	switch {
+	case repository.IsTransient(err):
+		slog.WarnContext(ctx, "Failed to save message to InvalidRequests, batch will be retried", logger.Err(err))
+		return err
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to save message to InvalidRequests", logger.Err(err))
+	default:
+		slog.InfoContext(ctx, "Message saved to InvalidRequests")
+	}
+
+	OS.publishDeadLetter(ctx, msg, origErr)
+	return nil
+}
+
+// publishDeadLetter forwards rejected message to DLQ if it is configured; failures are only logged
+func (OS *orderService) publishDeadLetter(ctx context.Context, msg *ingest.Message, reason error) {
+	if OS.DLQ == nil {
 		return
 	}
-	log.Printf("Invalid JSON saved to InvalidRequests.")
+	if err := OS.DLQ.PublishDeadLetter(ctx, msg, reason); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish message to DLQ", logger.Err(err))
+	}
 }
 
-func isValidOrderJSON(order *model.Order) bool {
-	// Проверяем top-level поля Order
-	if order.OrderUID == "" ||
-		order.TrackNumber == "" ||
-		order.Entry == "" ||
-		order.Locale == "" ||
-		order.CustomerID == "" ||
-		order.DeliveryService == "" ||
-		order.ShardKey == "" ||
-		order.OofShard == "" ||
-		order.DateCreated == "" {
-		return false
-	}
-
-	// Проверяем Delivery
-	d := order.Delivery
-	if d.Name == "" ||
-		d.Phone == "" ||
-		d.Zip == "" ||
-		d.City == "" ||
-		d.Address == "" ||
-		d.Region == "" ||
-		d.Email == "" {
-		return false
-	}
-
-	// Проверяем Payment
-	p := order.Payment
-	if p.Transaction == "" ||
-		p.Currency == "" ||
-		p.Provider == "" ||
-		p.Amount == 0 ||
-		p.PaymentDT == 0 ||
-		p.Bank == "" ||
-		p.GoodsTotal == 0 {
-		return false
-	}
-
-	// Проверяем Items — массив не может быть пустым
-	if len(order.Items) == 0 {
-		return false
-	}
-	for _, item := range order.Items {
-		if item.ChrtID == 0 ||
-			item.TrackNumber == "" ||
-			item.Price == 0 ||
-			item.RID == "" ||
-			item.Name == "" ||
-			item.Size == "" ||
-			item.TotalPrice == 0 ||
-			item.NMID == 0 ||
-			item.Brand == "" {
-			return false
-		}
-	}
-
-	return true
+// errorDetails converts processing error into JSON list of violations stored in InvalidRequest.ErrorMessage
+func errorDetails(err error) string {
+	var violations validation.Violations
+	switch {
+	case errors.As(err, &violations):
+	case errors.Is(err, ErrJSONDecode):
+		violations = validation.Violations{{Path: "$", Rule: validation.RuleJSONSyntax, Value: err.Error()}}
+	default:
+		violations = validation.Violations{{Path: "$", Rule: validation.RuleProcessingFailure, Value: err.Error()}}
+	}
+	return violations.JSON()
 }
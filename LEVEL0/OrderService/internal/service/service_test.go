@@ -4,13 +4,19 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"syscall"
 	"testing"
+	"time"
 
 	"orderservice/internal/cache"
+	"orderservice/internal/ingest"
 	"orderservice/internal/model"
+	"orderservice/internal/repository"
+	"orderservice/internal/validation"
 
-	"github.com/segmentio/kafka-go"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +26,12 @@ type fakeRepo struct {
 	GetOrderInfoFunc        func(ctx context.Context, uid string) (*model.Order, error)
 	GetAllOrdersFunc        func(ctx context.Context) ([]model.Order, error)
 	PushOrderToRawTableFunc func(ctx context.Context, brokenOrder model.InvalidRequest) error
+	ListOrdersFunc          func(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error)
+	GetInvalidRequestFunc   func(ctx context.Context, id uint) (*model.InvalidRequest, error)
+	UpdateInvalidRequestFn  func(ctx context.Context, req *model.InvalidRequest, fromStatus string) error
+	ApplyOrderEventFunc     func(ctx context.Context, event *model.OrderEvent) (*model.Order, error)
+	AddNewOrdersFunc        func(ctx context.Context, orders []*model.Order) error
+	GetAnalyticsFunc        func(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error)
 }
 
 func (f *fakeRepo) AddNewOrder(ctx context.Context, o *model.Order) error {
@@ -28,6 +40,17 @@ func (f *fakeRepo) AddNewOrder(ctx context.Context, o *model.Order) error {
 	}
 	return nil
 }
+func (f *fakeRepo) AddNewOrders(ctx context.Context, orders []*model.Order) error {
+	if f.AddNewOrdersFunc != nil {
+		return f.AddNewOrdersFunc(ctx, orders)
+	}
+	for _, o := range orders {
+		if err := f.AddNewOrder(ctx, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (f *fakeRepo) GetOrderByUID(ctx context.Context, uid string) (*model.Order, error) {
 	if f.GetOrderInfoFunc != nil {
 		return f.GetOrderInfoFunc(ctx, uid)
@@ -47,6 +70,51 @@ func (f *fakeRepo) PushOrderToRawTable(ctx context.Context, broken model.Invalid
 	return nil
 }
 
+func (f *fakeRepo) ListOrders(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error) {
+	if f.ListOrdersFunc != nil {
+		return f.ListOrdersFunc(ctx, filter, after)
+	}
+	return nil, nil
+}
+
+func (f *fakeRepo) ListInvalidRequests(ctx context.Context, status string, limit, offset int) ([]model.InvalidRequest, error) {
+	return nil, nil
+}
+func (f *fakeRepo) GetInvalidRequest(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+	if f.GetInvalidRequestFunc != nil {
+		return f.GetInvalidRequestFunc(ctx, id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+func (f *fakeRepo) UpdateInvalidRequest(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+	if f.UpdateInvalidRequestFn != nil {
+		return f.UpdateInvalidRequestFn(ctx, req, fromStatus)
+	}
+	return nil
+}
+
+func (f *fakeRepo) ApplyOrderEvent(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+	if f.ApplyOrderEventFunc != nil {
+		return f.ApplyOrderEventFunc(ctx, event)
+	}
+	return event.Order, nil
+}
+func (f *fakeRepo) GetAnalytics(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+	if f.GetAnalyticsFunc != nil {
+		return f.GetAnalyticsFunc(ctx, filter)
+	}
+	return &model.Analytics{}, nil
+}
+func (f *fakeRepo) Ping(ctx context.Context) error { return nil }
+
+func newTestCache(t *testing.T) cache.OrderCache {
+	orderCache, err := cache.New(cache.Config{MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	return orderCache
+}
+
 func TestProcessKafkaMessage_OK(t *testing.T) {
 	repo := &fakeRepo{
 		AddNewOrderFunc: func(ctx context.Context, o *model.Order) error {
@@ -58,21 +126,18 @@ func TestProcessKafkaMessage_OK(t *testing.T) {
 			return nil, nil
 		},
 	}
-	mapa := cache.OrderMap{
-		CacheMap: make(map[string]model.Order),
-		Repo:     repo,
-	}
-	svc := NewOrderService(repo, &mapa)
-	msg := kafka.Message{
-		Value: []byte(`{"order_uid":"u1","track_number":"T","entry":"WBIL","delivery":{"name":"A","phone":"1","zip":"1","city":"C","address":"A","region":"R","email":"e@e"},"payment":{"transaction":"u1","request_id":"","currency":"USD","provider":"p","amount":1,"payment_dt":1637907727,"bank":"b","delivery_cost":1,"goods_total":1,"custom_fee":500},"items":[{"chrt_id":1,"track_number":"T","price":1,"rid":"r","name":"n","sale":0,"size":"s","total_price":1,"nm_id":1,"brand":"b","status":1}],"locale":"en","internal_signature":"","customer_id":"c","delivery_service":"d","shardkey":"1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`),
+	orderCache := newTestCache(t)
+	svc := NewOrderService(repo, orderCache, nil)
+	msg := ingest.Message{
+		Value: []byte(`{"order_uid":"u1","track_number":"T","entry":"WBIL","delivery":{"name":"A","phone":"+79040000000","zip":"1","city":"C","address":"A","region":"R","email":"e@example.com"},"payment":{"transaction":"u1","request_id":"","currency":"USD","provider":"p","amount":1,"payment_dt":1637907727,"bank":"b","delivery_cost":1,"goods_total":1,"custom_fee":500},"items":[{"chrt_id":1,"track_number":"T","price":1,"rid":"r","name":"n","sale":0,"size":"s","total_price":1,"nm_id":1,"brand":"b","status":1}],"locale":"en","internal_signature":"","customer_id":"c","delivery_service":"d","shardkey":"1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`),
 	}
 	var testOrder model.Order
 	if err := json.Unmarshal(msg.Value, &testOrder); err != nil {
 	}
 	rawTestOrder, _ := json.Marshal(testOrder)
 
-	svc.AddNewOrder(&msg)
-	svcOrder, ok := mapa.CacheMap["u1"]
+	processOne(t, svc, msg)
+	svcOrder, ok := orderCache.Get("u1")
 	if !ok {
 		t.Fatalf("expected order created and in cache")
 	}
@@ -84,3 +149,624 @@ func TestProcessKafkaMessage_OK(t *testing.T) {
 		t.Fatalf("expected input and output order data to be equal")
 	}
 }
+
+func TestListOrders_Pagination(t *testing.T) {
+	all := []model.Order{
+		{OrderUID: "a", DateCreated: "2024-01-03T00:00:00Z"},
+		{OrderUID: "b", DateCreated: "2024-01-02T00:00:00Z"},
+		{OrderUID: "c", DateCreated: "2024-01-01T00:00:00Z"},
+	}
+	repo := &fakeRepo{
+		ListOrdersFunc: func(ctx context.Context, filter model.OrderFilter, after *model.OrderCursor) ([]model.Order, error) {
+			if filter.SortBy != "date_created" || !filter.SortDesc {
+				t.Fatalf("expected default sort by date_created desc, got %q desc=%v", filter.SortBy, filter.SortDesc)
+			}
+			start := 0
+			if after != nil {
+				for i, o := range all {
+					if o.OrderUID == after.OrderUID {
+						start = i + 1
+					}
+				}
+			}
+			end := min(start+filter.Limit, len(all))
+			return all[start:end], nil
+		},
+	}
+	svc := NewOrderService(repo, newTestCache(t), nil)
+
+	page, err := svc.ListOrders(context.Background(), model.OrderFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Orders) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 orders and next cursor, got %d orders, cursor %q", len(page.Orders), page.NextCursor)
+	}
+
+	page, err = svc.ListOrders(context.Background(), model.OrderFilter{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].OrderUID != "c" || page.NextCursor != "" {
+		t.Fatalf("expected last page with order c, got %+v", page)
+	}
+}
+
+func TestListOrders_InvalidInput(t *testing.T) {
+	svc := NewOrderService(&fakeRepo{}, newTestCache(t), nil)
+
+	if _, err := svc.ListOrders(context.Background(), model.OrderFilter{SortBy: "phone"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+	if _, err := svc.ListOrders(context.Background(), model.OrderFilter{Cursor: "!!!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	cursor := encodeCursor(model.OrderCursor{SortBy: "order_uid", OrderUID: "a"})
+	if _, err := svc.ListOrders(context.Background(), model.OrderFilter{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor for cursor of another sort, got %v", err)
+	}
+}
+
+// processOne passes a single message through the batch pipeline like the Kafka consumer does
+func processOne(t *testing.T, svc OrderService, msg ingest.Message) {
+	t.Helper()
+	if err := svc.ProcessBatch(context.Background(), []ingest.Message{msg}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type fakeDLQ struct {
+	reasons []error
+}
+
+func (f *fakeDLQ) PublishDeadLetter(ctx context.Context, msg *ingest.Message, reason error) error {
+	f.reasons = append(f.reasons, reason)
+	return nil
+}
+
+func TestProcessBatch_InvalidGoesToDLQ(t *testing.T) {
+	var saved []model.InvalidRequest
+	repo := &fakeRepo{PushOrderToRawTableFunc: func(ctx context.Context, broken model.InvalidRequest) error {
+		saved = append(saved, broken)
+		return nil
+	}}
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+
+	processOne(t, svc, ingest.Message{Value: []byte(`{"order_uid":`)})
+	processOne(t, svc, ingest.Message{Value: []byte(`{"order_uid":"u1"}`)})
+
+	if len(saved) != 2 || saved[0].Status != model.InvalidStatusNew {
+		t.Fatalf("expected 2 invalid requests with status New, got %+v", saved)
+	}
+	if len(dlq.reasons) != 2 || !errors.Is(dlq.reasons[0], ErrJSONDecode) || !errors.Is(dlq.reasons[1], ErrIncompleteJson) {
+		t.Fatalf("unexpected DLQ reasons: %v", dlq.reasons)
+	}
+
+	var violations validation.Violations
+	if err := json.Unmarshal([]byte(saved[1].ErrorMessage), &violations); err != nil {
+		t.Fatalf("ErrorMessage is not a JSON list of violations: %q", saved[1].ErrorMessage)
+	}
+	if len(violations) == 0 || violations[0].Path != "$.track_number" || violations[0].Rule != validation.RuleRequired {
+		t.Fatalf("unexpected violations: %+v", violations)
+	}
+}
+
+func TestReplayInvalidRequest(t *testing.T) {
+	const validOrder = `{"order_uid":"u1","track_number":"T","entry":"WBIL","delivery":{"name":"A","phone":"+79040000000","zip":"1","city":"C","address":"A","region":"R","email":"e@example.com"},"payment":{"transaction":"u1","currency":"USD","provider":"p","amount":1,"payment_dt":1637907727,"bank":"b","goods_total":1},"items":[{"chrt_id":1,"track_number":"T","price":1,"rid":"r","name":"n","size":"s","total_price":1,"nm_id":1,"brand":"b"}],"locale":"en","customer_id":"c","delivery_service":"d","shardkey":"1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`
+	id := uint(7)
+	stored := model.InvalidRequest{ID: &id, RawJSON: `{"order_uid":"u1"}`, Status: model.InvalidStatusNew}
+	var added int
+	repo := &fakeRepo{
+		GetInvalidRequestFunc: func(ctx context.Context, got uint) (*model.InvalidRequest, error) {
+			req := stored
+			return &req, nil
+		},
+		UpdateInvalidRequestFn: func(ctx context.Context, req *model.InvalidRequest, fromStatus string) error {
+			if stored.Status != fromStatus {
+				return repository.ErrStatusChanged
+			}
+			stored = *req
+			return nil
+		},
+		AddNewOrderFunc: func(ctx context.Context, o *model.Order) error {
+			added++
+			return nil
+		},
+	}
+	dlq := &fakeDLQ{}
+	orders := NewOrderService(repo, newTestCache(t), dlq)
+	updates := orders.(*orderService).watch.subscribe()
+	svc := NewDeadLetterService(orders)
+
+	// повтор без исправления - снова ошибка валидации
+	if _, err := svc.ReplayInvalidRequest(context.Background(), id, nil); !errors.Is(err, ErrIncompleteJson) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if stored.Status != model.InvalidStatusRetried || stored.Attempts != 1 || added != 0 {
+		t.Fatalf("expected Retried after failed replay, got %+v", stored)
+	}
+	if len(dlq.reasons) != 1 || !errors.Is(dlq.reasons[0], ErrIncompleteJson) {
+		t.Fatalf("expected rejected replay to go to DLQ, got %v", dlq.reasons)
+	}
+
+	// повтор с исправленным JSON
+	if _, err := svc.ReplayInvalidRequest(context.Background(), id, []byte(validOrder)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Status != model.InvalidStatusResolved || stored.Attempts != 2 || added != 1 || stored.RawJSON != validOrder {
+		t.Fatalf("expected Resolved after successful replay, got %+v", stored)
+	}
+	select {
+	case update := <-updates:
+		if update.Type != model.EventOrderCreated || update.Order.OrderUID != "u1" {
+			t.Fatalf("unexpected update: %+v", update)
+		}
+	default:
+		t.Fatalf("expected replayed order to reach WatchOrders subscribers")
+	}
+
+	// из финального статуса переходы запрещены
+	if _, err := svc.DiscardInvalidRequest(context.Background(), id); !errors.Is(err, ErrStatusTransition) {
+		t.Fatalf("expected ErrStatusTransition, got %v", err)
+	}
+}
+
+func TestReplayInvalidRequest_ConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	if err := repo.PushOrderToRawTable(ctx, model.InvalidRequest{RawJSON: string(orderJSON("a")), Status: model.InvalidStatusNew}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewDeadLetterService(NewOrderService(repo, newTestCache(t), nil))
+
+	// заказ уже сохранен, например параллельным повтором - сообщение обработано
+	if err := repo.AddNewOrder(ctx, &model.Order{OrderUID: "a", DateCreated: "2021-11-26T06:22:19Z"}); err != nil {
+		t.Fatal(err)
+	}
+	req, err := svc.ReplayInvalidRequest(ctx, 1, nil)
+	if err != nil || req.Status != model.InvalidStatusResolved {
+		t.Fatalf("expected existing order to resolve the message, got %+v, %v", req, err)
+	}
+
+	// статус прочитан до того, как сообщение было отброшено другим запросом
+	stale := *req
+	stale.Status = model.InvalidStatusNew
+	staleRepo := &fakeRepo{
+		GetInvalidRequestFunc: func(ctx context.Context, id uint) (*model.InvalidRequest, error) {
+			req := stale
+			return &req, nil
+		},
+		UpdateInvalidRequestFn: repo.UpdateInvalidRequest,
+	}
+	if _, err := NewDeadLetterService(NewOrderService(staleRepo, newTestCache(t), nil)).DiscardInvalidRequest(ctx, 1); !errors.Is(err, ErrStatusTransition) {
+		t.Fatalf("expected ErrStatusTransition for concurrently changed status, got %v", err)
+	}
+	if got, _ := repo.GetInvalidRequest(ctx, 1); got.Status != model.InvalidStatusResolved {
+		t.Errorf("resolved message must stay resolved, got %s", got.Status)
+	}
+}
+
+func TestDiscardInvalidRequest_NotFound(t *testing.T) {
+	svc := NewDeadLetterService(NewOrderService(&fakeRepo{}, newTestCache(t), nil))
+	if _, err := svc.DiscardInvalidRequest(context.Background(), 1); !errors.Is(err, ErrInvalidRequestNotFound) {
+		t.Fatalf("expected ErrInvalidRequestNotFound, got %v", err)
+	}
+}
+
+const eventOrder = `{"track_number":"T","entry":"WBIL","delivery":{"name":"A","phone":"+79040000000","zip":"1","city":"C","address":"A","region":"R","email":"e@example.com"},"payment":{"transaction":"u1","currency":"USD","provider":"p","amount":1,"payment_dt":1637907727,"bank":"b","goods_total":1},"items":[{"chrt_id":1,"track_number":"T","price":1,"rid":"r","name":"n","size":"s","total_price":1,"nm_id":1,"brand":"b","status":2}],"locale":"en","customer_id":"c","delivery_service":"d","shardkey":"1","sm_id":1,"date_created":"2021-11-26T06:22:19Z","oof_shard":"1"}`
+
+func TestProcessBatch_UpdateEvent(t *testing.T) {
+	var applied []*model.OrderEvent
+	repo := &fakeRepo{ApplyOrderEventFunc: func(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+		applied = append(applied, event)
+		if event.Version <= 1 {
+			return nil, repository.ErrStaleVersion
+		}
+		order := *event.Order
+		order.OrderUID, order.Version = event.OrderUID, event.Version
+		return &order, nil
+	}}
+	orderCache := newTestCache(t)
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, orderCache, dlq)
+
+	processOne(t, svc, ingest.Message{Value: []byte(`{"event_type":"order.updated","order_uid":"u1","version":2,"order":` + eventOrder + `}`)})
+	cached, ok := orderCache.Get("u1")
+	if !ok || cached.Version != 2 || cached.Items[0].Status != 2 {
+		t.Fatalf("expected cache refreshed with version 2, got %+v (found=%v)", cached, ok)
+	}
+	if applied[0].EventID != "u1:2" {
+		t.Fatalf("expected event ID derived from uid and version, got %q", applied[0].EventID)
+	}
+
+	// устаревшая версия пропускается без записи в DLQ
+	processOne(t, svc, ingest.Message{Value: []byte(`{"event_id":"e1","event_type":"order.cancelled","order_uid":"u1","version":1}`)})
+	if len(applied) != 2 || applied[1].EventID != "e1" || len(dlq.reasons) != 0 {
+		t.Fatalf("expected stale event to be skipped silently, applied=%d dlq=%v", len(applied), dlq.reasons)
+	}
+
+	// событие без версии отклоняется валидацией
+	processOne(t, svc, ingest.Message{Value: []byte(`{"event_type":"order.cancelled","order_uid":"u1"}`)})
+	if len(applied) != 2 || len(dlq.reasons) != 1 || !errors.Is(dlq.reasons[0], ErrIncompleteJson) {
+		t.Fatalf("expected invalid event in DLQ, applied=%d dlq=%v", len(applied), dlq.reasons)
+	}
+}
+
+func TestProcessBatch_EventFailureInvalidatesCache(t *testing.T) {
+	repo := &fakeRepo{ApplyOrderEventFunc: func(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+		return nil, errors.New("db is down")
+	}}
+	orderCache := newTestCache(t)
+	orderCache.Set(model.Order{OrderUID: "u1"})
+	svc := NewOrderService(repo, orderCache, nil)
+
+	processOne(t, svc, ingest.Message{Value: []byte(`{"event_type":"order.cancelled","order_uid":"u1","version":3}`)})
+	if _, ok := orderCache.Get("u1"); ok {
+		t.Fatalf("expected cache entry to be invalidated after failed event")
+	}
+}
+
+func TestProcessBatch_ConcurrentDuplicateEventIsSkipped(t *testing.T) {
+	//повтор события зафиксирован другим экземпляром после проверки истории
+	repo := &fakeRepo{ApplyOrderEventFunc: func(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+		return nil, fmt.Errorf("%w: %w", repository.ErrDuplicateEvent, gorm.ErrDuplicatedKey)
+	}}
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+
+	err := svc.ProcessBatch(context.Background(), []ingest.Message{{Value: []byte(`{"event_id":"e1","event_type":"order.cancelled","order_uid":"u1","version":2}`)}})
+	if err != nil || len(dlq.reasons) != 0 {
+		t.Fatalf("duplicate event must be skipped, not retried or sent to DLQ: %v, %v", err, dlq.reasons)
+	}
+}
+
+func orderJSON(uid string) []byte {
+	return []byte(`{"order_uid":"` + uid + `",` + eventOrder[1:])
+}
+
+func TestProcessBatch_PoisonIsolated(t *testing.T) {
+	var batches [][]string
+	repo := &fakeRepo{AddNewOrdersFunc: func(ctx context.Context, orders []*model.Order) error {
+		var uids []string
+		for _, o := range orders {
+			uids = append(uids, o.OrderUID)
+			if o.OrderUID == "poison" {
+				return errors.New("value too long for type character varying")
+			}
+		}
+		batches = append(batches, uids)
+		return nil
+	}}
+	orderCache := newTestCache(t)
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, orderCache, dlq)
+
+	msgs := []ingest.Message{{Value: orderJSON("a")}, {Value: orderJSON("poison")}, {Value: orderJSON("a")}, {Value: orderJSON("b")}}
+	if err := svc.ProcessBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("poison message must not fail the batch: %v", err)
+	}
+	if len(batches) != 2 || batches[0][0] != "a" || batches[1][0] != "b" {
+		t.Fatalf("expected a and b saved one by one after failed batch, got %v", batches)
+	}
+	if len(dlq.reasons) != 1 {
+		t.Fatalf("expected poison message in DLQ, got %v", dlq.reasons)
+	}
+	for _, uid := range []string{"a", "b"} {
+		if _, ok := orderCache.Get(uid); !ok {
+			t.Fatalf("expected %s in cache", uid)
+		}
+	}
+}
+
+func TestProcessBatch_DuplicateKeyIsSkipped(t *testing.T) {
+	//заказ вставлен другим экземпляром после проверки существования
+	repo := &fakeRepo{AddNewOrdersFunc: func(ctx context.Context, orders []*model.Order) error {
+		return fmt.Errorf("order %s: %w", orders[0].OrderUID, repository.ErrOrderExists)
+	}}
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+
+	processOne(t, svc, ingest.Message{Value: orderJSON("a")})
+	if len(dlq.reasons) != 0 {
+		t.Fatalf("existing order must be skipped, not sent to DLQ: %v", dlq.reasons)
+	}
+}
+
+func TestProcessBatch_TransientError(t *testing.T) {
+	repo := &fakeRepo{AddNewOrdersFunc: func(ctx context.Context, orders []*model.Order) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}}
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+
+	err := svc.ProcessBatch(context.Background(), []ingest.Message{{Value: orderJSON("a")}, {Value: []byte(`{`)}})
+	if err == nil {
+		t.Fatalf("expected transient error to be returned for retry")
+	}
+	if len(dlq.reasons) != 1 {
+		t.Fatalf("only the invalid message should reach DLQ, got %v", dlq.reasons)
+	}
+}
+
+func TestProcessBatch_RetryPublishesDeadLetterOnce(t *testing.T) {
+	var saved int
+	failures := 1
+	repo := &fakeRepo{PushOrderToRawTableFunc: func(ctx context.Context, broken model.InvalidRequest) error {
+		if failures > 0 {
+			failures--
+			return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+		saved++
+		return nil
+	}}
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+	batch := []ingest.Message{{Value: []byte(`{`)}}
+
+	if err := svc.ProcessBatch(context.Background(), batch); err == nil {
+		t.Fatalf("expected transient error to be returned for retry")
+	}
+	if len(dlq.reasons) != 0 {
+		t.Fatalf("message not saved to InvalidRequests must not reach DLQ, got %v", dlq.reasons)
+	}
+	processOne(t, svc, batch[0])
+	if saved != 1 || len(dlq.reasons) != 1 {
+		t.Fatalf("expected exactly one invalid request and DLQ record after retry, got %d and %v", saved, dlq.reasons)
+	}
+}
+
+func TestProcessBatch_KeepsOrderAroundEvents(t *testing.T) {
+	var calls []string
+	repo := &fakeRepo{
+		AddNewOrdersFunc: func(ctx context.Context, orders []*model.Order) error {
+			for _, o := range orders {
+				calls = append(calls, "insert:"+o.OrderUID)
+			}
+			return nil
+		},
+		ApplyOrderEventFunc: func(ctx context.Context, event *model.OrderEvent) (*model.Order, error) {
+			calls = append(calls, "event:"+event.OrderUID)
+			return &model.Order{OrderUID: event.OrderUID}, nil
+		},
+	}
+	svc := NewOrderService(repo, newTestCache(t), nil)
+
+	msgs := []ingest.Message{
+		{Value: orderJSON("a")},
+		{Value: []byte(`{"event_type":"order.cancelled","order_uid":"a","version":2}`)},
+		{Value: orderJSON("b")},
+	}
+	if err := svc.ProcessBatch(context.Background(), msgs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(calls) != "[insert:a event:a insert:b]" {
+		t.Fatalf("messages processed out of order: %v", calls)
+	}
+}
+
+// Сквозной сценарий на репозитории в памяти: пачка сообщений, дубликат, некорректный JSON, событие и список заказов
+func TestProcessBatch_MemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	svc := NewOrderService(repo, newTestCache(t), nil).(*orderService)
+
+	err := svc.ProcessBatch(ctx, []ingest.Message{
+		{Value: orderJSON("m1")},
+		{Value: orderJSON("m2")},
+		{Value: orderJSON("m1")},
+		{Value: []byte(`{broken`)},
+		{Value: []byte(`{"event_type":"order.cancelled","order_uid":"m2","version":1}`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := svc.GetOrderInfo(ctx, "m1"); err != nil {
+		t.Errorf("m1: %v", err)
+	}
+	m2, err := repo.GetOrderByUID(ctx, "m2")
+	if err != nil || m2.CancelledAt == nil {
+		t.Errorf("m2 must be stored and cancelled, got %+v, %v", m2, err)
+	}
+	invalid, _ := repo.ListInvalidRequests(ctx, "", 10, 0)
+	if len(invalid) != 1 || invalid[0].RawJSON != `{broken` {
+		t.Errorf("expected broken JSON in InvalidRequests, got %+v", invalid)
+	}
+
+	page, err := svc.ListOrders(ctx, model.OrderFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Orders) != 1 || page.NextCursor == "" {
+		t.Fatalf("expected a page with next cursor, got %+v", page)
+	}
+	next, err := svc.ListOrders(ctx, model.OrderFilter{Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Orders) != 1 || next.Orders[0].OrderUID == page.Orders[0].OrderUID || next.NextCursor != "" {
+		t.Errorf("unexpected second page: %+v", next)
+	}
+}
+
+func TestIngest_ReturnsErrorsToCaller(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	dlq := &fakeDLQ{}
+	svc := NewOrderService(repo, newTestCache(t), dlq)
+
+	if err := svc.Ingest(ctx, &ingest.Message{Source: ingest.SourceHTTP, Value: orderJSON("h1")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Ingest(ctx, &ingest.Message{Value: orderJSON("h1")}); !errors.Is(err, ErrOrderExists) {
+		t.Errorf("expected ErrOrderExists, got %v", err)
+	}
+	if err := svc.Ingest(ctx, &ingest.Message{Value: []byte(`{"order_uid":"h2"}`)}); !errors.Is(err, ErrIncompleteJson) {
+		t.Errorf("expected ErrIncompleteJson, got %v", err)
+	}
+
+	// отклоненные сообщения возвращаются вызывающему и не сохраняются для повторной обработки
+	invalid, _ := repo.ListInvalidRequests(ctx, "", 10, 0)
+	if len(invalid) != 0 || len(dlq.reasons) != 0 {
+		t.Errorf("expected nothing in InvalidRequests and DLQ, got %d and %d", len(invalid), len(dlq.reasons))
+	}
+}
+
+func TestGetOrderInfo_ServesCacheWhileStorageUnavailable(t *testing.T) {
+	calls := 0
+	repo := &fakeRepo{GetOrderInfoFunc: func(ctx context.Context, uid string) (*model.Order, error) {
+		calls++
+		return nil, repository.ErrUnavailable
+	}}
+	orderCache := newTestCache(t)
+	orderCache.Set(model.Order{OrderUID: "cached"})
+	svc := NewOrderService(repo, orderCache, nil)
+
+	if order, err := svc.GetOrderInfo(context.Background(), "cached"); err != nil || order.OrderUID != "cached" {
+		t.Fatalf("expected cached order, got %v, %v", order, err)
+	}
+	if calls != 0 {
+		t.Errorf("cached order must not be read from DB")
+	}
+	if _, err := svc.GetOrderInfo(context.Background(), "missing"); !errors.Is(err, ErrStorageUnavailable) {
+		t.Fatalf("expected ErrStorageUnavailable, got %v", err)
+	}
+	// сохранение заказа откладывается: консьюмер повторит пачку позже, а не отправит ее в DLQ
+	if err := svc.ProcessBatch(context.Background(), []ingest.Message{{Value: orderJSON("b")}}); !repository.IsTransient(err) {
+		t.Fatalf("expected transient error, got %v", err)
+	}
+}
+
+func TestWatchOrders(t *testing.T) {
+	svc := NewOrderService(&fakeRepo{}, newTestCache(t), nil).(*orderService)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan model.OrderUpdate)
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.WatchOrders(ctx, model.OrderFilter{CustomerID: "c1"}, func(update *model.OrderUpdate) error {
+			received <- *update
+			return nil
+		})
+	}()
+	// ждем подписки, иначе обновления уйдут в пустоту
+	for {
+		svc.watch.mu.Lock()
+		n := len(svc.watch.subs)
+		svc.watch.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	svc.watch.publish(model.OrderUpdate{Type: model.EventOrderCreated, Order: model.Order{OrderUID: "other", CustomerID: "c2"}})
+	svc.watch.publish(model.OrderUpdate{Type: model.EventOrderUpdated, Order: model.Order{OrderUID: "mine", CustomerID: "c1"}})
+	if update := <-received; update.Order.OrderUID != "mine" || update.Type != model.EventOrderUpdated {
+		t.Fatalf("expected only matching update, got %+v", update)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(svc.watch.subs) != 0 {
+		t.Errorf("expected subscriber removed")
+	}
+}
+
+func TestWatchOrders_SlowSubscriberIsDisconnected(t *testing.T) {
+	hub := newWatchHub()
+	updates := hub.subscribe()
+	for range watchBuffer + 1 {
+		hub.publish(model.OrderUpdate{Order: model.Order{OrderUID: "u1"}})
+	}
+	n := 0
+	for range updates {
+		n++
+	}
+	if n != watchBuffer {
+		t.Errorf("expected %d buffered updates before disconnect, got %d", watchBuffer, n)
+	}
+	hub.unsubscribe(updates) // повторное отключение безопасно
+}
+
+func TestGetAnalytics_Filter(t *testing.T) {
+	var got model.AnalyticsFilter
+	svc := NewAnalyticsService(&fakeRepo{GetAnalyticsFunc: func(ctx context.Context, filter model.AnalyticsFilter) (*model.Analytics, error) {
+		got = filter
+		return &model.Analytics{}, nil
+	}})
+
+	analytics, err := svc.GetAnalytics(context.Background(), model.AnalyticsFilter{Limit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Interval != model.IntervalDay || got.TopBy != model.TopByQuantity || got.Limit != maxTopLimit ||
+		got.DateTo.Sub(got.DateFrom) != defaultAnalyticsPeriod || !got.DateTo.After(time.Now()) {
+		t.Errorf("unexpected defaults %+v", got)
+	}
+	if analytics.Revenue == nil || analytics.TopBrands == nil || analytics.Baskets == nil {
+		t.Errorf("expected empty lists instead of nil")
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, filter := range map[string]model.AnalyticsFilter{
+		"reversed range": {DateFrom: from, DateTo: from.AddDate(0, 0, -1)},
+		"too long range": {DateFrom: from, DateTo: from.AddDate(2, 0, 0)},
+		"interval":       {Interval: "month"},
+		"top_by":         {TopBy: "price"},
+		"limit":          {Limit: -1},
+	} {
+		if _, err := svc.GetAnalytics(context.Background(), filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: expected ErrInvalidFilter, got %v", name, err)
+		}
+	}
+}
+
+// recordingWriter запоминает записанные заказы вместо файла выгрузки
+type recordingWriter struct {
+	uids   []string
+	closed bool
+	err    error
+}
+
+func (w *recordingWriter) Write(order *model.Order) error {
+	w.uids = append(w.uids, order.OrderUID)
+	return w.err
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestExportOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	for _, uid := range []string{"b", "a"} {
+		if err := repo.AddNewOrder(ctx, &model.Order{OrderUID: uid, DateCreated: "2024-01-01T00:00:00Z"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := NewExportService(repo.(repository.ExportRepository), 100)
+
+	w := &recordingWriter{}
+	n, err := svc.ExportOrders(ctx, model.OrderFilter{}, w)
+	if err != nil || n != 2 || !w.closed || fmt.Sprint(w.uids) != "[a b]" {
+		t.Fatalf("ExportOrders = %d, %v; written %v, closed %v", n, err, w.uids, w.closed)
+	}
+
+	// незавершенный файл не закрывается, чтобы не дописать футер к неполной выгрузке
+	w = &recordingWriter{err: errors.New("disk full")}
+	if _, err := svc.ExportOrders(ctx, model.OrderFilter{}, w); err == nil || w.closed {
+		t.Errorf("write error must stop export without closing the writer, got %v, closed %v", err, w.closed)
+	}
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := svc.ExportOrders(ctx, model.OrderFilter{DateFrom: day, DateTo: day}, &recordingWriter{}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter, got %v", err)
+	}
+}
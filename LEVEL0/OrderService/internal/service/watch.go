@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/model"
+	"sync"
+)
+
+// watchBuffer is how many updates may wait for a subscriber; a subscriber that falls further behind is disconnected
+const watchBuffer = 64
+
+// ErrWatchLagged is returned by WatchOrders when the subscriber does not keep up with the updates
+var ErrWatchLagged = errors.New("Подписчик не успевает получать изменения заказов")
+
+// watchHub delivers order updates to all current subscribers without blocking the publisher
+type watchHub struct {
+	mu   sync.Mutex
+	subs map[chan model.OrderUpdate]struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{subs: make(map[chan model.OrderUpdate]struct{})}
+}
+
+func (h *watchHub) subscribe() chan model.OrderUpdate {
+	ch := make(chan model.OrderUpdate, watchBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *watchHub) unsubscribe(ch chan model.OrderUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// publish sends update to every subscriber; a subscriber with full buffer is removed and its channel is closed.
+// Safe to call on nil hub(services created without watch support)
+func (h *watchHub) publish(update model.OrderUpdate) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- update:
+		default:
+			//медленный подписчик не должен задерживать обработку заказов
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// WatchOrders calls send for every order created or changed by this service instance after the call, if the order matches
+// the filter(sorting and pagination fields are ignored). It returns when ctx is done, send fails or the subscriber lags behind
+func (OS *orderService) WatchOrders(ctx context.Context, filter model.OrderFilter, send func(update *model.OrderUpdate) error) error {
+	updates := OS.watch.subscribe()
+	defer OS.watch.unsubscribe(updates)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-updates:
+			if !ok {
+				return ErrWatchLagged
+			}
+			if !filter.Matches(&update.Order) {
+				continue
+			}
+			if err := send(&update); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package validation checks decoded orders and reports every violated rule with its JSON path
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/mail"
+	"orderservice/internal/model"
+	"orderservice/internal/pii"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rules reported in Violation.Rule
+const (
+	RuleRequired          = "required"
+	RuleEmail             = "email"
+	RulePhone             = "phone"
+	RuleCurrency          = "currency_iso4217"
+	RuleLocale            = "locale"
+	RuleRFC3339           = "rfc3339"
+	RuleNotEmpty          = "not_empty"
+	RuleGoodsTotalSum     = "goods_total_sum"
+	RuleTrackNumberMatch  = "track_number_match"
+	RuleEventType         = "event_type"
+	RuleOrderUIDMatch     = "order_uid_match"
+	RuleReservedPrefix    = "reserved_prefix"
+	RuleJSONSyntax        = "json_syntax" // используется сервисом для ошибок декодирования
+	RuleProcessingFailure = "processing"  // используется сервисом для прочих ошибок обработки
+)
+
+// Violation describes a single failed check: where, which rule and the offending value
+type Violation struct {
+	Path  string `json:"path"`
+	Rule  string `json:"rule"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Violations is a list of failed checks, implements error
+type Violations []Violation
+
+func (v Violations) Error() string {
+	parts := make([]string, len(v))
+	for i, violation := range v {
+		parts[i] = fmt.Sprintf("%s: %s", violation.Path, violation.Rule)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// JSON returns violations as JSON array, used for InvalidRequest.ErrorMessage
+func (v Violations) JSON() string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v.Error()
+	}
+	return string(raw)
+}
+
+var (
+	phoneRe  = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,20}$`)
+	localeRe = regexp.MustCompile(`^[a-z]{2,3}([-_][A-Za-z]{2,4})?$`)
+)
+
+// ValidateOrder checks required fields, field formats and cross-field consistency; returns nil if order is valid
+func ValidateOrder(order *model.Order) Violations {
+	var v Violations
+	add := func(path, rule string, value any) {
+		//нарушения сохраняются в InvalidRequests открыто, поэтому персональные данные в них маскируются
+		if s, ok := value.(string); ok && pii.Current().Contains(path) {
+			value = pii.MaskValue(path, s)
+		}
+		v = append(v, Violation{Path: path, Rule: rule, Value: value})
+	}
+	required := func(path, value string) bool {
+		if strings.TrimSpace(value) == "" {
+			add(path, RuleRequired, nil)
+			return false
+		}
+		return true
+	}
+	positive := func(path string, value uint) {
+		if value == 0 {
+			add(path, RuleRequired, nil)
+		}
+	}
+
+	// Проверяем top-level поля Order
+	required("$.order_uid", order.OrderUID)
+	required("$.track_number", order.TrackNumber)
+	required("$.entry", order.Entry)
+	required("$.customer_id", order.CustomerID)
+	required("$.delivery_service", order.DeliveryService)
+	required("$.shardkey", order.ShardKey)
+	required("$.oof_shard", order.OofShard)
+	if required("$.locale", order.Locale) && !localeRe.MatchString(order.Locale) {
+		add("$.locale", RuleLocale, order.Locale)
+	}
+	if required("$.date_created", order.DateCreated) {
+		if _, err := time.Parse(time.RFC3339, order.DateCreated); err != nil {
+			add("$.date_created", RuleRFC3339, order.DateCreated)
+		}
+	}
+
+	// Проверяем Delivery
+	d := order.Delivery
+	required("$.delivery.name", d.Name)
+	required("$.delivery.zip", d.Zip)
+	required("$.delivery.city", d.City)
+	required("$.delivery.address", d.Address)
+	required("$.delivery.region", d.Region)
+	if required("$.delivery.phone", d.Phone) && !isPhone(d.Phone) {
+		add("$.delivery.phone", RulePhone, d.Phone)
+	}
+	if required("$.delivery.email", d.Email) && !isEmail(d.Email) {
+		add("$.delivery.email", RuleEmail, d.Email)
+	}
+
+	// Персональные данные с префиксом шифротекста не прочитать после сохранения без шифрования
+	fields := order.PIIFields()
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		if value := *fields[field]; pii.IsEncrypted(value) {
+			add("$."+field, RuleReservedPrefix, value)
+		}
+	}
+
+	// Проверяем Payment
+	p := order.Payment
+	required("$.payment.transaction", p.Transaction)
+	required("$.payment.provider", p.Provider)
+	required("$.payment.bank", p.Bank)
+	if required("$.payment.currency", p.Currency) && !isCurrency(p.Currency) {
+		add("$.payment.currency", RuleCurrency, p.Currency)
+	}
+	positive("$.payment.amount", p.Amount)
+	positive("$.payment.payment_dt", p.PaymentDT)
+	positive("$.payment.goods_total", p.GoodsTotal)
+
+	// Проверяем Items — массив не может быть пустым
+	if len(order.Items) == 0 {
+		add("$.items", RuleNotEmpty, nil)
+		return v
+	}
+	var itemsTotal uint
+	for i, item := range order.Items {
+		path := fmt.Sprintf("$.items[%d]", i)
+		positive(path+".chrt_id", item.ChrtID)
+		positive(path+".price", item.Price)
+		positive(path+".total_price", item.TotalPrice)
+		positive(path+".nm_id", item.NMID)
+		required(path+".rid", item.RID)
+		required(path+".name", item.Name)
+		required(path+".size", item.Size)
+		required(path+".brand", item.Brand)
+		if required(path+".track_number", item.TrackNumber) && order.TrackNumber != "" && item.TrackNumber != order.TrackNumber {
+			add(path+".track_number", RuleTrackNumberMatch, item.TrackNumber)
+		}
+		itemsTotal += item.TotalPrice
+	}
+
+	// Сверяем итоговую сумму товаров
+	if p.GoodsTotal != 0 && p.GoodsTotal != itemsTotal {
+		add("$.payment.goods_total", RuleGoodsTotalSum, p.GoodsTotal)
+	}
+	return v
+}
+
+// ValidateEvent checks event envelope and, for created/updated events, the order it carries(paths are prefixed with "$.order")
+func ValidateEvent(event *model.OrderEvent) Violations {
+	var v Violations
+	switch event.EventType {
+	case model.EventOrderCreated, model.EventOrderUpdated, model.EventOrderCancelled:
+	default:
+		v = append(v, Violation{Path: "$.event_type", Rule: RuleEventType, Value: event.EventType})
+	}
+	if strings.TrimSpace(event.OrderUID) == "" {
+		v = append(v, Violation{Path: "$.order_uid", Rule: RuleRequired})
+	}
+	if event.Version == 0 {
+		v = append(v, Violation{Path: "$.version", Rule: RuleRequired})
+	}
+	if event.EventType != model.EventOrderCreated && event.EventType != model.EventOrderUpdated {
+		return v
+	}
+
+	if event.Order == nil {
+		return append(v, Violation{Path: "$.order", Rule: RuleRequired})
+	}
+	if event.Order.OrderUID != "" && event.Order.OrderUID != event.OrderUID {
+		v = append(v, Violation{Path: "$.order.order_uid", Rule: RuleOrderUIDMatch, Value: event.Order.OrderUID})
+	}
+	order := *event.Order
+	order.OrderUID = event.OrderUID
+	for _, violation := range ValidateOrder(&order) {
+		violation.Path = "$.order" + strings.TrimPrefix(violation.Path, "$")
+		v = append(v, violation)
+	}
+	return v
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	// ParseAddress допускает "Имя <addr>", нам нужен только сам адрес; домен должен содержать точку
+	return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
+
+func isPhone(s string) bool {
+	if !phoneRe.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 7 && digits <= 15 // ограничение E.164
+}
+
+func isCurrency(s string) bool {
+	_, ok := currencies[s]
+	return ok
+}
@@ -0,0 +1,132 @@
+package validation
+
+import (
+	"encoding/json"
+	"orderservice/internal/model"
+	"os"
+	"testing"
+)
+
+func validOrder() model.Order {
+	return model.Order{
+		OrderUID: "b563feb7b2b84b6test", TrackNumber: "WBILMTESTTRACK", Entry: "WBIL",
+		Delivery: model.Delivery{Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com"},
+		Payment: model.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317},
+		Items: []model.Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202}},
+		Locale: "en", CustomerID: "test", DeliveryService: "meest", ShardKey: "9", SMID: 99,
+		DateCreated: "2021-11-26T06:22:19Z", OofShard: "1",
+	}
+}
+
+func TestValidateOrder_Valid(t *testing.T) {
+	order := validOrder()
+	if v := ValidateOrder(&order); v != nil {
+		t.Fatalf("expected no violations, got %v", v)
+	}
+}
+
+func TestValidateOrder_ModelJSON(t *testing.T) {
+	raw, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Skipf("model.json not available: %v", err)
+	}
+	var order model.Order
+	if err := json.Unmarshal(raw, &order); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+	if v := ValidateOrder(&order); v != nil {
+		t.Fatalf("reference order should be valid, got %v", v)
+	}
+}
+
+func TestValidateOrder_Violations(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(o *model.Order)
+		wantPath string
+		wantRule string
+	}{
+		{"missing uid", func(o *model.Order) { o.OrderUID = "" }, "$.order_uid", RuleRequired},
+		{"bad email", func(o *model.Order) { o.Delivery.Email = "test@" }, "$.delivery.email", RuleEmail},
+		{"bad phone", func(o *model.Order) { o.Delivery.Phone = "call me" }, "$.delivery.phone", RulePhone},
+		{"short phone", func(o *model.Order) { o.Delivery.Phone = "+12" }, "$.delivery.phone", RulePhone},
+		{"bad currency", func(o *model.Order) { o.Payment.Currency = "usd" }, "$.payment.currency", RuleCurrency},
+		{"bad locale", func(o *model.Order) { o.Locale = "english" }, "$.locale", RuleLocale},
+		{"bad date", func(o *model.Order) { o.DateCreated = "26.11.2021" }, "$.date_created", RuleRFC3339},
+		{"no items", func(o *model.Order) { o.Items = nil }, "$.items", RuleNotEmpty},
+		{"goods total", func(o *model.Order) { o.Payment.GoodsTotal = 1 }, "$.payment.goods_total", RuleGoodsTotalSum},
+		{"item track", func(o *model.Order) { o.Items[0].TrackNumber = "OTHER" }, "$.items[0].track_number", RuleTrackNumberMatch},
+		{"item zero price", func(o *model.Order) { o.Items[0].Price = 0 }, "$.items[0].price", RuleRequired},
+		{"reserved prefix", func(o *model.Order) { o.Delivery.City = "pii:v1:k1:Moscow" }, "$.delivery.city", RuleReservedPrefix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.mutate(&order)
+			v := ValidateOrder(&order)
+			if len(v) != 1 || v[0].Path != tt.wantPath || v[0].Rule != tt.wantRule {
+				t.Fatalf("want single violation %s/%s, got %v", tt.wantPath, tt.wantRule, v)
+			}
+		})
+	}
+}
+
+func TestValidateOrder_MasksPII(t *testing.T) {
+	order := validOrder()
+	order.Delivery.Email = "test@"
+	order.Delivery.Phone = "call me"
+	order.Payment.Currency = "usd"
+	values := map[string]any{}
+	for _, violation := range ValidateOrder(&order) {
+		values[violation.Path] = violation.Value
+	}
+	if values["$.delivery.email"] != "t***@" || values["$.delivery.phone"] != "ca***me" {
+		t.Errorf("personal data must be masked, got %v", values)
+	}
+	if values["$.payment.currency"] != "usd" {
+		t.Errorf("other values must be kept, got %v", values["$.payment.currency"])
+	}
+}
+
+func TestViolations_JSON(t *testing.T) {
+	v := Violations{{Path: "$.delivery.email", Rule: RuleEmail, Value: "test@"}}
+	var decoded []Violation
+	if err := json.Unmarshal([]byte(v.JSON()), &decoded); err != nil {
+		t.Fatalf("violations are not valid JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Path != "$.delivery.email" || decoded[0].Value != "test@" {
+		t.Fatalf("unexpected decoded violations: %+v", decoded)
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	order := validOrder()
+	order.OrderUID = ""
+	ok := model.OrderEvent{EventType: model.EventOrderUpdated, OrderUID: "u1", Version: 2, Order: &order}
+	if v := ValidateEvent(&ok); v != nil {
+		t.Fatalf("expected no violations, got %v", v)
+	}
+	if v := ValidateEvent(&model.OrderEvent{EventType: model.EventOrderCancelled, OrderUID: "u1", Version: 3}); v != nil {
+		t.Fatalf("cancel event needs no order, got %v", v)
+	}
+
+	broken := validOrder()
+	broken.Delivery.Email = "nope"
+	v := ValidateEvent(&model.OrderEvent{EventType: model.EventOrderUpdated, OrderUID: "u1", Version: 2, Order: &broken})
+	paths := map[string]string{}
+	for _, violation := range v {
+		paths[violation.Path] = violation.Rule
+	}
+	if paths["$.order.order_uid"] != RuleOrderUIDMatch || paths["$.order.delivery.email"] != RuleEmail {
+		t.Fatalf("unexpected violations: %v", v)
+	}
+
+	v = ValidateEvent(&model.OrderEvent{EventType: "order.deleted"})
+	if len(v) != 3 {
+		t.Fatalf("expected event_type, order_uid and version violations, got %v", v)
+	}
+}
@@ -1,12 +1,15 @@
 package web
 
 import (
+	"embed"
 	"html/template"
 	"net/http"
-	"path/filepath"
 	"sync"
 )
 
+//go:embed *.gohtml
+var templatesFS embed.FS
+
 var (
 	tplCache *template.Template
 	once     sync.Once
@@ -15,7 +18,7 @@ var (
 // LoadTemplates инициализирует шаблоны один раз при старте
 func LoadTemplates() {
 	once.Do(func() {
-		tplCache = template.Must(template.ParseGlob(filepath.Join("internal", "web", "*.gohtml")))
+		tplCache = template.Must(template.ParseFS(templatesFS, "*.gohtml"))
 	})
 }
 